@@ -1,8 +1,14 @@
 package argparser
 
 import (
+	"fmt"
 	"testing"
 
+	"github.com/peng225/oval/internal/object"
+	"github.com/peng225/oval/internal/pattern"
+	"github.com/peng225/oval/internal/runner"
+	"github.com/peng225/oval/internal/s3client"
+
 	"github.com/stretchr/testify/assert"
 )
 
@@ -54,3 +60,270 @@ func TestParseMultipartThresh(t *testing.T) {
 		assert.Equal(t, tc.expectedMultipartThresh, multipartThresh)
 	}
 }
+
+func TestParseOpeRatio(t *testing.T) {
+	type testCase struct {
+		opeRatioStr      string
+		expectedOpeRatio []float64
+		expectedErr      bool
+	}
+	testCases := []testCase{
+		{
+			opeRatioStr:      "1,1,1,1,0",
+			expectedOpeRatio: []float64{0.25, 0.25, 0.25, 0.25, 0},
+			expectedErr:      false,
+		},
+		{
+			opeRatioStr:      "1,1,2",
+			expectedOpeRatio: []float64{0.25, 0.25, 0.5, 0, 0},
+			expectedErr:      false,
+		},
+		{
+			opeRatioStr:      "1,1,a",
+			expectedOpeRatio: nil,
+			expectedErr:      true,
+		},
+		{
+			opeRatioStr:      "1,1,1,1,1,1,1,1,1,1,1,1,1,1,1,1,1,1,1,1,1,1,1,1,1,1,1,1,1,1,1,1",
+			expectedOpeRatio: nil,
+			expectedErr:      true,
+		},
+	}
+
+	for _, tc := range testCases {
+		opeRatio, err := ParseOpeRatio(tc.opeRatioStr)
+		if tc.expectedErr {
+			assert.Errorf(t, err, "tc.opeRatioStr: %s", tc.opeRatioStr)
+			continue
+		}
+		assert.NoError(t, err)
+		expected := make([]float64, int(runner.NumOperation))
+		copy(expected, tc.expectedOpeRatio)
+		assert.Equal(t, expected, opeRatio)
+	}
+}
+
+func TestParsePartSize(t *testing.T) {
+	type testCase struct {
+		partSizeStr      string
+		maxObjSize       int
+		expectedPartSize s3client.PartSize
+		expectedErr      bool
+	}
+	testCases := []testCase{
+		{
+			partSizeStr: "8m",
+			maxObjSize:  1024 * 1024 * 1024,
+			expectedPartSize: s3client.PartSize{
+				Min:  8 * 1024 * 1024,
+				Max:  8 * 1024 * 1024,
+				Unit: pattern.DataUnitSize,
+			},
+			expectedErr: false,
+		},
+		{
+			partSizeStr: "5m-16m",
+			maxObjSize:  1024 * 1024 * 1024,
+			expectedPartSize: s3client.PartSize{
+				Min:  5 * 1024 * 1024,
+				Max:  16 * 1024 * 1024,
+				Unit: pattern.DataUnitSize,
+			},
+			expectedErr: false,
+		},
+		{
+			partSizeStr: "5m,7m,9m",
+			maxObjSize:  1024 * 1024 * 1024,
+			expectedPartSize: s3client.PartSize{
+				Candidates: []int{5 * 1024 * 1024, 7 * 1024 * 1024, 9 * 1024 * 1024},
+			},
+			expectedErr: false,
+		},
+		{
+			// Just the maximum number of the parts.
+			partSizeStr: "5m",
+			maxObjSize:  maxPartCount * 5 * 1024 * 1024,
+			expectedPartSize: s3client.PartSize{
+				Min:  5 * 1024 * 1024,
+				Max:  5 * 1024 * 1024,
+				Unit: pattern.DataUnitSize,
+			},
+			expectedErr: false,
+		},
+		{
+			partSizeStr: "16m-5m",
+			maxObjSize:  1024 * 1024 * 1024,
+			expectedErr: true,
+		},
+		{
+			partSizeStr: "5m,,9m",
+			maxObjSize:  1024 * 1024 * 1024,
+			expectedErr: true,
+		},
+	}
+	// The part sizes which are rejected.
+	for _, partSizeStr := range []string{
+		"4m", "4m-16m", "5m,4m,9m",
+		fmt.Sprintf("%d", 5*1024*1024+1), fmt.Sprintf("5m-%d", 16*1024*1024-1), fmt.Sprintf("5m,%d", 7*1024*1024+128),
+	} {
+		testCases = append(testCases, testCase{
+			partSizeStr: partSizeStr,
+			maxObjSize:  1024 * 1024 * 1024,
+			expectedErr: true,
+		})
+	}
+	// The part sizes with which the object of the max size exceeds the maximum number of the parts.
+	for _, partSizeStr := range []string{"5m", "5m-16m", "9m,5m,7m"} {
+		testCases = append(testCases, testCase{
+			partSizeStr: partSizeStr,
+			maxObjSize:  maxPartCount*5*1024*1024 + 1,
+			expectedErr: true,
+		})
+	}
+
+	for _, tc := range testCases {
+		partSize, err := ParsePartSize(tc.partSizeStr, tc.maxObjSize)
+		if tc.expectedErr {
+			assert.Errorf(t, err, "tc.partSizeStr: %s, tc.maxObjSize: %d", tc.partSizeStr, tc.maxObjSize)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, tc.expectedPartSize, partSize)
+	}
+}
+
+func TestParseChecksum(t *testing.T) {
+	type testCase struct {
+		algorithm              string
+		checksumType           string
+		expectedChecksumConfig s3client.ChecksumConfig
+		expectedErr            bool
+	}
+	testCases := []testCase{
+		{
+			algorithm:              "",
+			checksumType:           "",
+			expectedChecksumConfig: s3client.ChecksumConfig{},
+			expectedErr:            false,
+		},
+		{
+			algorithm:    "crc32c",
+			checksumType: "",
+			expectedChecksumConfig: s3client.ChecksumConfig{
+				Algorithm: s3client.ChecksumCRC32C,
+				Type:      s3client.ChecksumTypeComposite,
+			},
+			expectedErr: false,
+		},
+		{
+			algorithm:    "crc32",
+			checksumType: "full_object",
+			expectedChecksumConfig: s3client.ChecksumConfig{
+				Algorithm: s3client.ChecksumCRC32,
+				Type:      s3client.ChecksumTypeFullObject,
+			},
+			expectedErr: false,
+		},
+		{
+			algorithm:    "crc64nvme",
+			checksumType: "",
+			expectedChecksumConfig: s3client.ChecksumConfig{
+				Algorithm: s3client.ChecksumCRC64NVME,
+				Type:      s3client.ChecksumTypeFullObject,
+			},
+			expectedErr: false,
+		},
+		{
+			algorithm:              "crc64nvme",
+			checksumType:           "composite",
+			expectedChecksumConfig: s3client.ChecksumConfig{},
+			expectedErr:            true,
+		},
+		{
+			algorithm:              "sha256",
+			checksumType:           "full_object",
+			expectedChecksumConfig: s3client.ChecksumConfig{},
+			expectedErr:            true,
+		},
+		{
+			algorithm:              "md5",
+			checksumType:           "",
+			expectedChecksumConfig: s3client.ChecksumConfig{},
+			expectedErr:            true,
+		},
+		{
+			algorithm:              "",
+			checksumType:           "composite",
+			expectedChecksumConfig: s3client.ChecksumConfig{},
+			expectedErr:            true,
+		},
+	}
+
+	for _, tc := range testCases {
+		checksumConfig, err := ParseChecksum(tc.algorithm, tc.checksumType)
+		if tc.expectedErr {
+			assert.Errorf(t, err, "tc.algorithm: %s, tc.checksumType: %s", tc.algorithm, tc.checksumType)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, tc.expectedChecksumConfig, checksumConfig)
+	}
+}
+
+func TestParseKeyScheme(t *testing.T) {
+	type testCase struct {
+		keyScheme         string
+		expectedKeyScheme object.KeyScheme
+		expectedErr       bool
+	}
+	testCases := []testCase{
+		{
+			keyScheme:         "flat",
+			expectedKeyScheme: object.KeySchemeFlat,
+			expectedErr:       false,
+		},
+		{
+			keyScheme:         "Nested",
+			expectedKeyScheme: object.KeySchemeNested,
+			expectedErr:       false,
+		},
+		{
+			keyScheme:         "mixed",
+			expectedKeyScheme: object.KeySchemeMixed,
+			expectedErr:       false,
+		},
+		{
+			keyScheme:   "",
+			expectedErr: true,
+		},
+		{
+			keyScheme:   "random",
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		keyScheme, err := ParseKeyScheme(tc.keyScheme)
+		if tc.expectedErr {
+			assert.Errorf(t, err, "tc.keyScheme: %s", tc.keyScheme)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, tc.expectedKeyScheme, keyScheme)
+	}
+}
+
+func TestValidateFollowerList(t *testing.T) {
+	assert.NoError(t, ValidateFollowerList([]string{"http://localhost:8080", "http://localhost:8081"}))
+	assert.Error(t, ValidateFollowerList([]string{}))
+	assert.Error(t, ValidateFollowerList([]string{"http://localhost:8080", ""}))
+
+	followerList := make([]string, object.MaxNumRunners)
+	for i := range followerList {
+		followerList[i] = fmt.Sprintf("http://localhost:%d", 8080+i)
+	}
+	assert.NoError(t, ValidateFollowerList(followerList))
+	// The runner ID of the next follower is reserved for the shared keys.
+	followerList = append(followerList, "http://localhost:9000")
+	assert.Error(t, ValidateFollowerList(followerList))
+}
@@ -0,0 +1,51 @@
+package argparser
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/peng225/oval/internal/s3client"
+)
+
+// ParseChecksum parses the checksum algorithm and the checksum type of the multipart uploads.
+func ParseChecksum(algorithm, checksumType string) (s3client.ChecksumConfig, error) {
+	if algorithm == "" {
+		if checksumType != "" {
+			return s3client.ChecksumConfig{}, fmt.Errorf("checksum type %v is specified without checksum algorithm", checksumType)
+		}
+		return s3client.ChecksumConfig{}, nil
+	}
+
+	algorithm = strings.ToUpper(algorithm)
+	if !slices.Contains([]string{
+		s3client.ChecksumCRC32, s3client.ChecksumCRC32C, s3client.ChecksumCRC64NVME,
+		s3client.ChecksumSHA1, s3client.ChecksumSHA256,
+	}, algorithm) {
+		return s3client.ChecksumConfig{}, fmt.Errorf("invalid checksum algorithm: %v", algorithm)
+	}
+
+	checksumType = strings.ToUpper(checksumType)
+	switch checksumType {
+	case "":
+		checksumType = s3client.ChecksumTypeComposite
+		if algorithm == s3client.ChecksumCRC64NVME {
+			checksumType = s3client.ChecksumTypeFullObject
+		}
+	case s3client.ChecksumTypeFullObject:
+		if algorithm == s3client.ChecksumSHA1 || algorithm == s3client.ChecksumSHA256 {
+			return s3client.ChecksumConfig{}, fmt.Errorf("checksum algorithm %v does not support the full object checksum", algorithm)
+		}
+	case s3client.ChecksumTypeComposite:
+		if algorithm == s3client.ChecksumCRC64NVME {
+			return s3client.ChecksumConfig{}, fmt.Errorf("checksum algorithm %v does not support the composite checksum", algorithm)
+		}
+	default:
+		return s3client.ChecksumConfig{}, fmt.Errorf("invalid checksum type: %v", checksumType)
+	}
+
+	return s3client.ChecksumConfig{
+		Algorithm: algorithm,
+		Type:      checksumType,
+	}, nil
+}
@@ -3,11 +3,17 @@ package argparser
 import (
 	"fmt"
 	"slices"
+
+	"github.com/peng225/oval/internal/object"
 )
 
 func ValidateFollowerList(followerList []string) error {
 	if len(followerList) == 0 || slices.Contains(followerList, "") {
 		return fmt.Errorf("invalid follower list format %v", followerList)
 	}
+	// The index of each follower is embedded in the keys as the runner ID.
+	if len(followerList) > object.MaxNumRunners {
+		return fmt.Errorf("too many followers. (max = %d, actual = %d)", object.MaxNumRunners, len(followerList))
+	}
 	return nil
 }
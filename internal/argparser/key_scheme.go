@@ -0,0 +1,18 @@
+package argparser
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/peng225/oval/internal/object"
+)
+
+// ParseKeyScheme parses the scheme of the key names.
+func ParseKeyScheme(s string) (object.KeyScheme, error) {
+	keyScheme := object.KeyScheme(strings.ToLower(s))
+	if !slices.Contains(object.KeySchemes, keyScheme) {
+		return "", fmt.Errorf("invalid key scheme: %v", s)
+	}
+	return keyScheme, nil
+}
@@ -10,7 +10,8 @@ import (
 
 func ParseOpeRatio(opeRatioStr string) ([]float64, error) {
 	opeRatioStrs := strings.Split(opeRatioStr, ",")
-	if len(opeRatioStrs) != int(runner.NumOperation) {
+	// The ratio of the omitted trailing operations is treated as 0.
+	if len(opeRatioStrs) > int(runner.NumOperation) {
 		return nil, fmt.Errorf("invalid ope ratio format %v", opeRatioStr)
 	}
 
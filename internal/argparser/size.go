@@ -4,8 +4,19 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+
+	"github.com/peng225/oval/internal/pattern"
+	"github.com/peng225/oval/internal/s3client"
+)
+
+const (
+	// The minimum size of the parts of the multipart upload except for the last part.
+	minPartSize = 5 * 1024 * 1024
+	// The maximum number of the parts of the multipart upload.
+	maxPartCount = 10000
 )
 
 func ParseSize(s string) (int, int, error) {
@@ -59,3 +70,68 @@ func ParseMultipartThresh(s string) (int, error) {
 	mpThresh, err := parseSizeUnit(s)
 	return mpThresh, err
 }
+
+// ParsePartSize parses the part size in the form like "8m", "5m-16m" or "5m,7m,9m".
+// The objects up to `maxObjSize` bytes should be uploaded within the maximum number of the parts.
+func ParsePartSize(s string, maxObjSize int) (s3client.PartSize, error) {
+	s = strings.ToLower(s)
+	if strings.Contains(s, ",") {
+		candidates := make([]int, 0)
+		for _, sizeStr := range strings.Split(s, ",") {
+			size, err := parseSizeUnit(sizeStr)
+			if err != nil {
+				return s3client.PartSize{}, err
+			}
+			err = validPartSize(size)
+			if err != nil {
+				return s3client.PartSize{}, err
+			}
+			candidates = append(candidates, size)
+		}
+		err := validPartCount(slices.Min(candidates), maxObjSize)
+		if err != nil {
+			return s3client.PartSize{}, err
+		}
+		return s3client.PartSize{
+			Candidates: candidates,
+		}, nil
+	}
+	minSize, maxSize, err := ParseSize(s)
+	if err != nil {
+		return s3client.PartSize{}, err
+	}
+	for _, size := range []int{minSize, maxSize} {
+		err = validPartSize(size)
+		if err != nil {
+			return s3client.PartSize{}, err
+		}
+	}
+	err = validPartCount(minSize, maxObjSize)
+	if err != nil {
+		return s3client.PartSize{}, err
+	}
+	return s3client.PartSize{
+		Min:  minSize,
+		Max:  maxSize,
+		Unit: pattern.DataUnitSize,
+	}, nil
+}
+
+func validPartSize(size int) error {
+	if size < minPartSize {
+		return fmt.Errorf("the part size should be larger than or equal to %d bytes (5 MiB): %d", minPartSize, size)
+	}
+	if size%pattern.DataUnitSize != 0 {
+		return fmt.Errorf("the part size should be a multiple of %d bytes: %d", pattern.DataUnitSize, size)
+	}
+	return nil
+}
+
+func validPartCount(minSize, maxObjSize int) error {
+	partCount := (maxObjSize + minSize - 1) / minSize
+	if partCount > maxPartCount {
+		return fmt.Errorf("the object of the max size %d bytes may be split into %d parts of %d bytes, which exceeds the limit of %d parts",
+			maxObjSize, partCount, minSize, maxPartCount)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/peng225/oval/internal/pattern"
+	"github.com/peng225/oval/internal/s3client"
+	"github.com/spf13/cobra"
+)
+
+var (
+	inspectFileName            string
+	inspectHexDumpFileName     string
+	inspectBucketName          string
+	inspectKey                 string
+	inspectVersionID           string
+	inspectFormat              string
+	inspectOnlyDiscontinuities bool
+)
+
+// inspectCmd represents the inspect command
+var inspectCmd = &cobra.Command{
+	Use:   "inspect",
+	Short: "Decode the data units of an object written by oval",
+	Long: `Decode the data units of an object written by oval.
+The data is read from a local file, a hexdump as printed in the validation error messages, or an object in a bucket.
+The header of every data unit is printed as a table or JSON, and the discontinuities between the data units are highlighted.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		handleCommonFlags()
+
+		if inspectFormat != "table" && inspectFormat != "json" {
+			slog.Error("Invalid format.", "format", inspectFormat)
+			os.Exit(1)
+		}
+		if inspectBucketName != "" && endpoint == "" {
+			slog.Error(`"endpoint" parameter is required to inspect an object in a bucket.`)
+			os.Exit(1)
+		}
+		if inspectFileName == "" && inspectHexDumpFileName == "" && inspectBucketName == "" {
+			slog.Error(`One of "file", "hexdump" and "bucket" parameters is required.`)
+			os.Exit(1)
+		}
+
+		var reader io.Reader
+		switch {
+		case inspectFileName != "":
+			f, err := os.Open(inspectFileName)
+			if err != nil {
+				slog.Error(err.Error())
+				os.Exit(1)
+			}
+			defer f.Close()
+			reader = f
+		case inspectHexDumpFileName != "":
+			var hexDump io.Reader = os.Stdin
+			if inspectHexDumpFileName != "-" {
+				f, err := os.Open(inspectHexDumpFileName)
+				if err != nil {
+					slog.Error(err.Error())
+					os.Exit(1)
+				}
+				defer f.Close()
+				hexDump = f
+			}
+			data, err := pattern.ParseHexDump(hexDump)
+			if err != nil {
+				slog.Error(err.Error())
+				os.Exit(1)
+			}
+			reader = bytes.NewReader(data)
+		default:
+			if caCertFileName != "" {
+				// Check if a file with the name "caCertFileName" exists.
+				_, err := os.Stat(caCertFileName)
+				if err != nil {
+					slog.Error(err.Error())
+					os.Exit(1)
+				}
+			}
+			client := s3client.NewS3Client(endpoint, caCertFileName, s3client.MultipartConfig{}, s3client.ChecksumConfig{})
+			ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+			defer stop()
+			var body io.ReadCloser
+			var err error
+			if inspectVersionID != "" {
+				body, err = client.GetObjectVersion(ctx, inspectBucketName, inspectKey, inspectVersionID)
+			} else {
+				body, err = client.GetObject(ctx, inspectBucketName, inspectKey)
+			}
+			if err != nil {
+				slog.Error("Failed to get the object.", "err", err)
+				os.Exit(1)
+			}
+			defer body.Close()
+			reader = body
+		}
+
+		units, err := pattern.Inspect(reader)
+		if err != nil {
+			slog.Error("pattern.Inspect() failed.", "err", err)
+			os.Exit(1)
+		}
+		if inspectFormat == "json" {
+			if inspectOnlyDiscontinuities {
+				filtered := make([]pattern.InspectedUnit, 0)
+				for _, u := range units {
+					if len(u.Discontinuities) != 0 {
+						filtered = append(filtered, u)
+					}
+				}
+				units = filtered
+			}
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			err = encoder.Encode(units)
+		} else {
+			err = pattern.WriteUnitTable(os.Stdout, units, inspectOnlyDiscontinuities)
+		}
+		if err != nil {
+			slog.Error(err.Error())
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(inspectCmd)
+
+	defineCommonFlags(inspectCmd)
+	inspectCmd.Flags().StringVar(&inspectFileName, "file", "", "File name of the raw data to inspect, such as the dump file of a corrupted object.")
+	inspectCmd.Flags().StringVar(&inspectHexDumpFileName, "hexdump", "", `File name of the hexdump to inspect. "-" means the standard input.`)
+	inspectCmd.Flags().StringVar(&inspectBucketName, "bucket", "", "The name of the bucket of the object to inspect.")
+	inspectCmd.Flags().StringVar(&inspectKey, "key", "", "The key of the object to inspect.")
+	inspectCmd.Flags().StringVar(&inspectVersionID, "version_id", "", "The version ID of the object to inspect. If omitted, the current version is inspected.")
+	inspectCmd.Flags().StringVar(&endpoint, "endpoint", "", "The endpoint URL and TCP port number. e.g. \"http://127.0.0.1:9000\"")
+	inspectCmd.Flags().StringVar(&caCertFileName, "cacert", "", "File name of CA certificate.")
+	inspectCmd.Flags().StringVar(&inspectFormat, "format", "table", `Output format ("table" or "json").`)
+	inspectCmd.Flags().BoolVar(&inspectOnlyDiscontinuities, "only_discontinuities", false, "Print only the data units with the discontinuities.")
+
+	inspectCmd.MarkFlagsMutuallyExclusive("file", "hexdump", "bucket")
+	inspectCmd.MarkFlagsRequiredTogether("bucket", "key")
+}
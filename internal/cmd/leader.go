@@ -61,7 +61,7 @@ var leaderCmd = &cobra.Command{
 		}
 
 		err = multiprocess.StartFollower(followerList, execContext,
-			opeRatio, execTime.Milliseconds(), multipartThresh)
+			opeRatio, execTime.Milliseconds(), multipartConfig, checksumConfig, continueOnError, maxErrors, corruptionDir, corruptionReread)
 		if err != nil {
 			slog.Error("StartFollower failed.", "err", err)
 			cancelErr := multiprocess.CancelFollowerWorkload(followerList)
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
@@ -11,7 +12,9 @@ import (
 
 	"github.com/peng225/oval/internal/argparser"
 	"github.com/peng225/oval/internal/logger"
+	"github.com/peng225/oval/internal/object"
 	"github.com/peng225/oval/internal/runner"
+	"github.com/peng225/oval/internal/s3client"
 	"github.com/spf13/cobra"
 )
 
@@ -29,10 +32,25 @@ var (
 	loadFileName       string
 	caCertFileName     string
 	logFormat          string
+	versioning         bool
+	partSizeStr        string
+	sparsePartNumber   bool
+	partConcurrency    int
+	checksumAlgorithm  string
+	checksumType       string
+	sharedKey          bool
+	keySchemeStr       string
+	checkpointInterval time.Duration
+	continueOnError    bool
+	maxErrors          int
+	corruptionDir      string
+	corruptionReread   bool
 
 	minSize, maxSize int
 	opeRatio         []float64
-	multipartThresh  int
+	multipartConfig  s3client.MultipartConfig
+	checksumConfig   s3client.ChecksumConfig
+	keyScheme        object.KeyScheme
 	execContext      *runner.ExecutionContext
 )
 
@@ -48,6 +66,15 @@ If no subcommands are specified, Oval runs in the single-process mode.`,
 		handleCommonFlags()
 		handleSubCommonFlags()
 
+		if checkpointInterval < 0 {
+			slog.Error("The checkpoint interval must be larger than or equal to 0.")
+			os.Exit(1)
+		}
+		if checkpointInterval != 0 && saveFileName == "" {
+			slog.Error(`The checkpoints require "save" parameter.`)
+			os.Exit(1)
+		}
+
 		// Check if a file with the name "saveFileName" exists.
 		_, err := os.Stat(saveFileName)
 		if err == nil {
@@ -77,10 +104,23 @@ If no subcommands are specified, Oval runs in the single-process mode.`,
 		var r *runner.Runner
 		if loadFileName == "" {
 			r = runner.NewRunner(execContext, opeRatio, execTime.Milliseconds(),
-				profiler, loadFileName, 0, multipartThresh, caCertFileName)
+				profiler, loadFileName, 0, multipartConfig, checksumConfig, caCertFileName)
 		} else {
 			r = runner.NewRunnerFromLoadFile(loadFileName, opeRatio, execTime.Milliseconds(),
-				profiler, multipartThresh, caCertFileName)
+				profiler, multipartConfig, checksumConfig, caCertFileName)
+		}
+		if checkpointInterval != 0 {
+			r.EnableCheckpoint(saveFileName, checkpointInterval)
+		}
+		if continueOnError {
+			r.ContinueOnError(maxErrors)
+		}
+		if corruptionDir != "" {
+			err = r.RecordCorruptions(corruptionDir, corruptionReread)
+			if err != nil {
+				slog.Error("r.RecordCorruptions() failed.", "err", err)
+				os.Exit(1)
+			}
 		}
 		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
 		defer stop()
@@ -93,12 +133,16 @@ If no subcommands are specified, Oval runs in the single-process mode.`,
 			os.Exit(1)
 		}
 		err = r.Run(ctx)
+		var errorReport *runner.ErrorReport
 		if err != nil {
 			slog.Error("r.Run() failed.")
 			if ctx.Err() == context.Canceled {
 				return
 			}
-			os.Exit(1)
+			// The context is still consistent if the workload continued on the errors.
+			if !errors.As(err, &errorReport) {
+				os.Exit(1)
+			}
 		}
 
 		if saveFileName != "" {
@@ -108,6 +152,9 @@ If no subcommands are specified, Oval runs in the single-process mode.`,
 				os.Exit(1)
 			}
 		}
+		if errorReport != nil {
+			os.Exit(1)
+		}
 	},
 }
 
@@ -136,9 +183,14 @@ func init() {
 	rootCmd.Flags().StringVar(&saveFileName, "save", "", "File name to save the execution context.")
 	rootCmd.Flags().StringVar(&loadFileName, "load", "", "File name to load the execution context.")
 	rootCmd.Flags().StringVar(&caCertFileName, "cacert", "", "File name of CA certificate.")
+	rootCmd.Flags().DurationVar(&checkpointInterval, "checkpoint_interval", 0, `Interval to save the execution context to the file of "save" parameter during the workload. The value 0 means to save it only at the end. The objects used after the last checkpoint are recorded to the journal file next to it, and they are reset when the file is loaded.`)
 
 	rootCmd.MarkFlagsMutuallyExclusive("bucket", "load")
 	rootCmd.MarkFlagsMutuallyExclusive("endpoint", "load")
+	rootCmd.MarkFlagsMutuallyExclusive("versioning", "load")
+	rootCmd.MarkFlagsMutuallyExclusive("key_scheme", "load")
+	rootCmd.MarkFlagsMutuallyExclusive("shared_key", "save")
+	rootCmd.MarkFlagsMutuallyExclusive("shared_key", "load")
 }
 
 func handleCommonFlags() {
@@ -161,19 +213,53 @@ func handleSubCommonFlags() {
 		slog.Error(err.Error())
 		os.Exit(1)
 	}
-	multipartThresh, err = argparser.ParseMultipartThresh(multipartThreshStr)
+	multipartThresh, err := argparser.ParseMultipartThresh(multipartThreshStr)
 	if err != nil {
 		slog.Error(err.Error())
 		os.Exit(1)
 	}
 
-	if numWorker >= 256 {
-		slog.Error("The number of workers must be less than 256.")
+	partSize := s3client.PartSize{
+		Min: multipartThresh,
+		Max: multipartThresh,
+	}
+	if partSizeStr != "" {
+		partSize, err = argparser.ParsePartSize(partSizeStr, maxSize)
+		if err != nil {
+			slog.Error(err.Error())
+			os.Exit(1)
+		}
+	}
+
+	if partConcurrency < 1 {
+		slog.Error("The part concurrency must be larger than or equal to 1.")
+		os.Exit(1)
+	}
+	multipartConfig = s3client.MultipartConfig{
+		Thresh:           multipartThresh,
+		PartSize:         partSize,
+		SparsePartNumber: sparsePartNumber,
+		PartConcurrency:  partConcurrency,
+	}
+	checksumConfig, err = argparser.ParseChecksum(checksumAlgorithm, checksumType)
+	if err != nil {
+		slog.Error(err.Error())
 		os.Exit(1)
 	}
 
-	if numObj > 0x1000000 {
-		slog.Error("The number of objects must be less than 16777216.")
+	keyScheme, err = argparser.ParseKeyScheme(keySchemeStr)
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+
+	if numWorker > 0x10000 {
+		slog.Error("The number of workers must be less than or equal to 65536.")
+		os.Exit(1)
+	}
+
+	if numObj > object.MaxObjPerRunner {
+		slog.Error(fmt.Sprintf("The number of objects must be less than or equal to %d.", object.MaxObjPerRunner))
 		os.Exit(1)
 	}
 
@@ -182,12 +268,35 @@ func handleSubCommonFlags() {
 		os.Exit(1)
 	}
 
+	if !sharedKey && numObj/numWorker > object.MaxObjPerWorker(numWorker) {
+		slog.Error(fmt.Sprintf("The number of objects per worker must be less than or equal to %d with %d workers.",
+			object.MaxObjPerWorker(numWorker), numWorker))
+		os.Exit(1)
+	}
+
+	if maxErrors < 0 {
+		slog.Error("The maximum number of errors must be larger than or equal to 0.")
+		os.Exit(1)
+	}
+	if maxErrors != 0 {
+		continueOnError = true
+	}
+
 	if execTime < 0 {
 		slog.Error("The execution time must be larger than or equal to 0.")
 		os.Exit(1)
 	}
 
-	if numObj%numWorker != 0 {
+	if sharedKey {
+		for ope, ratio := range opeRatio {
+			if ratio != 0 && runner.Operation(ope) != runner.Put && runner.Operation(ope) != runner.Get {
+				slog.Error("Only the put and get operations are allowed in the shared-key mode.")
+				os.Exit(1)
+			}
+		}
+	}
+
+	if !sharedKey && numObj%numWorker != 0 {
 		slog.Warn(fmt.Sprintf("The number of objects (%d) is not divisible by the number of workers (%d). Only %d objects will be used.",
 			numObj, numWorker, numObj/numWorker*numWorker))
 	}
@@ -199,6 +308,12 @@ func handleSubCommonFlags() {
 		NumWorker:   numWorker,
 		MinSize:     minSize,
 		MaxSize:     maxSize,
+		Versioning:  versioning,
+		KeyScheme:   keyScheme,
+		SharedKey:   sharedKey,
+	}
+	if sharedKey {
+		execContext.SharedKeyEpoch = time.Now().UnixMicro()
 	}
 }
 
@@ -212,7 +327,27 @@ func defineSubCommonFlags(cmd *cobra.Command) {
 	cmd.Flags().StringVar(&sizePattern, "size", "4k", `The size of object. Should be in the form like "8k" or "4k-2m". Only "k", "m" and "g" is allowed as an unit.`)
 	cmd.Flags().DurationVar(&execTime, "time", time.Second*3, "Time duration for run the workload. The value 0 means to run infinitely.")
 	cmd.Flags().StringSliceVar(&bucketNames, "bucket", nil, "The name list of the buckets. e.g. \"bucket1,bucket2\"")
-	cmd.Flags().StringVar(&opeRatioStr, "ope_ratio", "1,1,1,0", "The ration of put, get, delete and list operations. e.g. \"2,3,1,1\"")
+	cmd.Flags().StringVar(&opeRatioStr, "ope_ratio", "1,1,1,0", "The ratio of put, get, delete, list, range get, copy, compose, upload abort, upload abandonment, part overwrite, conditional put, head and batch delete operations. The omitted trailing values are treated as 0. e.g. \"2,3,1,1,1,1,1,1,1,1,1,1,1\"")
 	cmd.Flags().StringVar(&endpoint, "endpoint", "", "The endpoint URL and TCP port number. e.g. \"http://127.0.0.1:9000\"")
 	cmd.Flags().StringVar(&multipartThreshStr, "multipart_thresh", "100m", `The threshold of the object size to switch to the multipart upload. Only "k", "m" and "g" is allowed as an unit.`)
+	cmd.Flags().StringVar(&partSizeStr, "part_size", "", `The size of each part of the multipart upload. Should be in the form like "8m", "5m-16m" or "5m,7m,9m". Each size should be at least 5m and a multiple of 256 bytes, and the object of the max size should fit in 10000 parts. If omitted, the value of "multipart_thresh" is used.`)
+	cmd.Flags().BoolVar(&sparsePartNumber, "sparse_part_number", false, "Choose the part numbers of the multipart upload sparsely (e.g. 1, 3, 7, ...) instead of contiguously.")
+	cmd.Flags().IntVar(&partConcurrency, "part_concurrency", 1, "The number of parts of an object uploaded concurrently in the multipart upload.")
+	cmd.Flags().StringVar(&checksumAlgorithm, "checksum", "", `The algorithm of the flexible checksums sent on writes and validated on reads ("crc32", "crc32c", "crc64nvme", "sha1" or "sha256"). If omitted, the checksums are disabled.`)
+	cmd.Flags().StringVar(&checksumType, "checksum_type", "", `The checksum type of the multipart uploads ("full_object" or "composite"). If omitted, "full_object" is used for "crc64nvme" and "composite" for the others.`)
+	cmd.Flags().BoolVar(&versioning, "versioning", false, "Enable versioning of the buckets and validate the versions of objects.")
+	cmd.Flags().BoolVar(&sharedKey, "shared_key", false, "Make all workers of all processes write the same keys concurrently. Only the put and get operations are allowed. In the multi-process mode, the data written by the other processes is only checked to be intact and not older than the data of the same worker found by the earlier reads, because their writes are not known to each process.")
+
+	cmd.Flags().BoolVar(&continueOnError, "continue_on_error", false, "Continue the workload on the errors. The objects affected by each error are excluded from the workload and all errors are reported at the end.")
+	cmd.Flags().IntVar(&maxErrors, "max_errors", 0, `Continue the workload on the errors until the specified number of errors occur. See also "continue_on_error" parameter.`)
+	cmd.Flags().StringVar(&corruptionDir, "corruption_dir", "", `Directory to record the validation failures of the objects. Each failure is appended to "corruptions.jsonl" and the data which failed the validation is dumped to a file as it was read. If omitted, the failures are not recorded.`)
+	cmd.Flags().BoolVar(&corruptionReread, "corruption_reread", false, `Also read the object again after each failure and dump the whole data to another file. The data read again may differ from the data which failed the validation. See also "corruption_dir" parameter.`)
+
+	cmd.Flags().StringVar(&keySchemeStr, "key_scheme", string(object.KeySchemeFlat), `The scheme of the key names ("flat", "nested", "long", "unicode", "case" or "mixed"). The keys of the shared-key mode are always flat.`)
+
+	cmd.MarkFlagsMutuallyExclusive("shared_key", "versioning")
+	cmd.MarkFlagsMutuallyExclusive("shared_key", "key_scheme")
+	cmd.MarkFlagsMutuallyExclusive("shared_key", "continue_on_error")
+	cmd.MarkFlagsMutuallyExclusive("shared_key", "max_errors")
+	cmd.MarkFlagsMutuallyExclusive("continue_on_error", "max_errors")
 }
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/peng225/oval/internal/argparser"
+	"github.com/peng225/oval/internal/runner"
+	"github.com/peng225/oval/internal/s3client"
+	"github.com/spf13/cobra"
+)
+
+var (
+	verifyNumWorker int
+)
+
+// verifyCmd represents the verify command
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Verify all objects recorded in the saved execution context",
+	Long: `Verify all objects recorded in the saved execution context.
+Every existing object is read and validated, and every non-existing object is confirmed to be not found.
+The inventory of the lost, stale, corrupted and unexpected objects is printed at the end.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		handleCommonFlags()
+
+		if verifyNumWorker < 1 {
+			slog.Error("The number of workers must be larger than or equal to 1.")
+			os.Exit(1)
+		}
+		// The checksum type matters only for writes.
+		checksumConfig, err := argparser.ParseChecksum(checksumAlgorithm, "")
+		if err != nil {
+			slog.Error(err.Error())
+			os.Exit(1)
+		}
+		if caCertFileName != "" {
+			// Check if a file with the name "caCertFileName" exists.
+			_, err = os.Stat(caCertFileName)
+			if err != nil {
+				slog.Error(err.Error())
+				os.Exit(1)
+			}
+		}
+
+		r := runner.NewRunnerFromLoadFile(loadFileName, nil, 0, false,
+			s3client.MultipartConfig{}, checksumConfig, caCertFileName)
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+		defer stop()
+		if corruptionDir != "" {
+			err = r.RecordCorruptions(corruptionDir, corruptionReread)
+			if err != nil {
+				slog.Error("r.RecordCorruptions() failed.", "err", err)
+				os.Exit(1)
+			}
+		}
+		inv, err := r.Verify(ctx, verifyNumWorker)
+		if err != nil {
+			slog.Error("r.Verify() failed.", "err", err)
+			os.Exit(1)
+		}
+		inv.Print(os.Stdout)
+		if inv.NumProblems() != 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+
+	defineCommonFlags(verifyCmd)
+	verifyCmd.Flags().StringVar(&loadFileName, "load", "", "File name to load the execution context.")
+	verifyCmd.Flags().IntVar(&verifyNumWorker, "num_worker", 16, "The number of workers to verify the objects concurrently.")
+	verifyCmd.Flags().StringVar(&checksumAlgorithm, "checksum", "", `The algorithm of the flexible checksums validated on reads ("crc32", "crc32c", "crc64nvme", "sha1" or "sha256"). It should be the same as the one used at write. If omitted, the checksums are not validated.`)
+	verifyCmd.Flags().StringVar(&corruptionDir, "corruption_dir", "", `Directory to record the corrupted and stale objects. Each finding is appended to "corruptions.jsonl" and the data which failed the validation is dumped to a file as it was read. If omitted, the findings are not recorded.`)
+	verifyCmd.Flags().BoolVar(&corruptionReread, "corruption_reread", false, `Also read the object again after each finding and dump the whole data to another file. The data read again may differ from the data which failed the validation. See also "corruption_dir" parameter.`)
+	verifyCmd.Flags().StringVar(&caCertFileName, "cacert", "", "File name of CA certificate.")
+
+	err := verifyCmd.MarkFlagRequired("load")
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+}
@@ -121,13 +121,26 @@ func startHandler(w http.ResponseWriter, r *http.Request) {
 	ctx, stop = context.WithCancel(context.Background())
 	go func() {
 		run = runner.NewRunner(&param.Context, param.OpeRatio, param.TimeInMs, false, "",
-			param.ID, param.MultipartThresh, caCertFileName)
-		err := run.InitBucket(ctx)
-		if err != nil {
-			resultErr = fmt.Errorf("run.InitBucket() failed. %w", err)
-			slog.Error(resultErr.Error())
-		} else {
-			resultErr = run.Run(ctx)
+			param.ID, param.MultipartConfig, param.ChecksumConfig, caCertFileName)
+		if param.ContinueOnError {
+			run.ContinueOnError(param.MaxErrors)
+		}
+		var err error
+		if param.CorruptionDir != "" {
+			err = run.RecordCorruptions(param.CorruptionDir, param.CorruptionReread)
+			if err != nil {
+				resultErr = fmt.Errorf("run.RecordCorruptions() failed. %w", err)
+				slog.Error(resultErr.Error())
+			}
+		}
+		if err == nil {
+			err = run.InitBucket(ctx)
+			if err != nil {
+				resultErr = fmt.Errorf("run.InitBucket() failed. %w", err)
+				slog.Error(resultErr.Error())
+			} else {
+				resultErr = run.Run(ctx)
+			}
 		}
 		mu.Lock()
 		defer mu.Unlock()
@@ -160,7 +173,12 @@ func printStartFollowerParameter(param *StartFollowerParameter) {
 		"Context", param.Context,
 		"OpeRatio", param.OpeRatio,
 		"TimeInMs", param.TimeInMs,
-		"MultipartThresh", param.MultipartThresh)
+		"MultipartConfig", param.MultipartConfig,
+		"ChecksumConfig", param.ChecksumConfig,
+		"ContinueOnError", param.ContinueOnError,
+		"MaxErrors", param.MaxErrors,
+		"CorruptionDir", param.CorruptionDir,
+		"CorruptionReread", param.CorruptionReread)
 }
 
 func resultHandler(w http.ResponseWriter, r *http.Request) {
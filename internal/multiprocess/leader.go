@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/peng225/oval/internal/runner"
+	"github.com/peng225/oval/internal/s3client"
 )
 
 const (
@@ -19,23 +20,34 @@ const (
 )
 
 type StartFollowerParameter struct {
-	ID              int
-	Context         runner.ExecutionContext
-	OpeRatio        []float64
-	TimeInMs        int64
-	MultipartThresh int
+	ID               int
+	Context          runner.ExecutionContext
+	OpeRatio         []float64
+	TimeInMs         int64
+	MultipartConfig  s3client.MultipartConfig
+	ChecksumConfig   s3client.ChecksumConfig
+	ContinueOnError  bool
+	MaxErrors        int
+	CorruptionDir    string
+	CorruptionReread bool
 }
 
 func StartFollower(followerList []string,
 	context *runner.ExecutionContext,
-	opeRatio []float64, timeInMs int64, multipartThresh int) error {
+	opeRatio []float64, timeInMs int64, multipartConfig s3client.MultipartConfig,
+	checksumConfig s3client.ChecksumConfig, continueOnError bool, maxErrors int, corruptionDir string, corruptionReread bool) error {
 	for i, follower := range followerList {
 		param := StartFollowerParameter{
-			ID:              i,
-			Context:         *context,
-			OpeRatio:        opeRatio,
-			TimeInMs:        timeInMs,
-			MultipartThresh: multipartThresh,
+			ID:               i,
+			Context:          *context,
+			OpeRatio:         opeRatio,
+			TimeInMs:         timeInMs,
+			MultipartConfig:  multipartConfig,
+			ChecksumConfig:   checksumConfig,
+			ContinueOnError:  continueOnError,
+			MaxErrors:        maxErrors,
+			CorruptionDir:    corruptionDir,
+			CorruptionReread: corruptionReread,
 		}
 		data, err := json.Marshal(param)
 		if err != nil {
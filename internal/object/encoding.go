@@ -0,0 +1,276 @@
+package object
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"slices"
+)
+
+// objectMetaJSON is the on-disk representation of ObjectMeta.
+// The sizes, the write counts, the numbers of the parts, the skipped write counts and the last modified times
+// are encoded as the sequences of uvarints, the ETags as the sequence of the MD5 digests,
+// the checksums as the sequence of the decoded checksums,
+// and only the objects with the details are saved as they are.
+type objectMetaJSON struct {
+	NumObj            int            `json:"numObj"`
+	Sizes             []byte         `json:"sizes"`
+	WriteCounts       []byte         `json:"writeCounts"`
+	Existing          []byte         `json:"existing"`
+	ETags             []byte         `json:"etags,omitempty"`
+	ETagParts         []byte         `json:"etagParts,omitempty"`
+	ChecksumAlgorithm string         `json:"checksumAlgorithm,omitempty"`
+	Checksums         []byte         `json:"checksums,omitempty"`
+	ChecksumParts     []byte         `json:"checksumParts,omitempty"`
+	Skipped           []byte         `json:"skipped,omitempty"`
+	LastModified      []byte         `json:"lastModified,omitempty"`
+	Metadata          []byte         `json:"metadata,omitempty"`
+	Writer            *Writer        `json:"writer,omitempty"`
+	Objects           []objectWithID `json:"objects,omitempty"`
+	KeyIDOffset       int64          `json:"keyIDOffset"`
+	KeyPrefix         string
+	KeyScheme         KeyScheme `json:"keyScheme,omitempty"`
+
+	// The fields of the legacy format in which all objects were saved as they are.
+	ObjectList        []*Object `json:"objectList,omitempty"`
+	ExistingObjectIDs []int64   `json:"existingObjectIDs,omitempty"`
+}
+
+type objectWithID struct {
+	ID int64 `json:"id"`
+	*Object
+}
+
+func (om *ObjectMeta) MarshalJSON() ([]byte, error) {
+	omj := objectMetaJSON{
+		NumObj:      om.NumObj(),
+		Sizes:       make([]byte, 0, om.NumObj()),
+		WriteCounts: make([]byte, 0, om.NumObj()),
+		Existing:    make([]byte, 0, len(om.existing)*8),
+		Writer:      om.writer,
+		KeyIDOffset: om.KeyIDOffset,
+		KeyPrefix:   om.KeyPrefix,
+		KeyScheme:   om.KeyScheme,
+	}
+	etags := make([]byte, 0)
+	etagParts := make([]byte, 0)
+	checksums := make([]byte, 0)
+	checksumParts := make([]byte, 0)
+	skipped := make([]byte, 0)
+	lastModified := make([]byte, 0)
+	hasETags, hasETagParts, hasSkipped, hasLastModified := false, false, false, false
+	checksumAlgorithm, checksumSize := om.checksumAlgorithm, om.checksumSize()
+	metadata := make([]uint64, len(om.metadata))
+	for objID := range int64(om.NumObj()) {
+		p := om.packedAt(objID)
+		// The materialized objects may not have been packed yet.
+		if obj, ok := om.objects[objID]; ok {
+			var details bool
+			p, details = om.packed(obj)
+			if p.checksum != nil && checksumAlgorithm == "" {
+				checksumAlgorithm, checksumSize = p.checksumAlgorithm, len(p.checksum)
+			} else if p.checksum != nil && (p.checksumAlgorithm != checksumAlgorithm || len(p.checksum) != checksumSize) {
+				// The checksum is saved with the object.
+				p.checksum, p.checksumParts = nil, 0
+				details = true
+			}
+			if details {
+				omj.Objects = append(omj.Objects, objectWithID{ID: objID, Object: obj})
+			}
+		}
+		omj.Sizes = binary.AppendUvarint(omj.Sizes, uint64(p.size))
+		omj.WriteCounts = binary.AppendUvarint(omj.WriteCounts, uint64(p.writeCount))
+		etags = append(etags, p.etag[:]...)
+		hasETags = hasETags || p.etag != [md5.Size]byte{}
+		etagParts = binary.AppendUvarint(etagParts, uint64(p.etagParts))
+		hasETagParts = hasETagParts || p.etagParts != 0
+		if p.checksum != nil {
+			// The checksums of the objects before it are zero-filled.
+			checksums = append(checksums, make([]byte, int(objID)*checksumSize-len(checksums))...)
+			checksums = append(checksums, p.checksum...)
+		}
+		checksumParts = binary.AppendUvarint(checksumParts, uint64(p.checksumParts))
+		skipped = binary.AppendUvarint(skipped, uint64(p.skipped))
+		hasSkipped = hasSkipped || p.skipped != 0
+		lastModified = binary.AppendUvarint(lastModified, uint64(p.lastModified))
+		hasLastModified = hasLastModified || p.lastModified != 0
+		if p.ownMetadata {
+			metadata[objID/64] |= 1 << (objID % 64)
+		}
+	}
+	if hasETags {
+		omj.ETags = etags
+	}
+	if hasETagParts {
+		omj.ETagParts = etagParts
+	}
+	if len(checksums) != 0 {
+		omj.ChecksumAlgorithm = checksumAlgorithm
+		omj.Checksums = append(checksums, make([]byte, om.NumObj()*checksumSize-len(checksums))...)
+		omj.ChecksumParts = checksumParts
+	}
+	if hasSkipped {
+		omj.Skipped = skipped
+	}
+	if hasLastModified {
+		omj.LastModified = lastModified
+	}
+	for _, word := range om.existing {
+		omj.Existing = binary.LittleEndian.AppendUint64(omj.Existing, word)
+	}
+	if slices.ContainsFunc(metadata, func(word uint64) bool { return word != 0 }) {
+		for _, word := range metadata {
+			omj.Metadata = binary.LittleEndian.AppendUint64(omj.Metadata, word)
+		}
+	}
+	return json.Marshal(&omj)
+}
+
+func (om *ObjectMeta) UnmarshalJSON(data []byte) error {
+	omj := objectMetaJSON{}
+	err := json.Unmarshal(data, &omj)
+	if err != nil {
+		return err
+	}
+	if omj.ObjectList != nil {
+		err = om.fromLegacyJSON(&omj)
+	} else {
+		err = om.fromJSON(&omj)
+	}
+	if err != nil {
+		return err
+	}
+	om.TidyUp()
+	return nil
+}
+
+func (om *ObjectMeta) reset(numObj int, omj *objectMetaJSON) {
+	*om = ObjectMeta{
+		sizes:       make([]int64, numObj),
+		writeCounts: make([]uint32, numObj),
+		existing:    make([]uint64, (numObj+63)/64),
+		metadata:    make([]uint64, (numObj+63)/64),
+		objects:     make(map[int64]*Object),
+		writer:      omj.Writer,
+		KeyIDOffset: omj.KeyIDOffset,
+		KeyPrefix:   omj.KeyPrefix,
+		KeyScheme:   omj.KeyScheme,
+	}
+}
+
+func (om *ObjectMeta) fromJSON(omj *objectMetaJSON) error {
+	om.reset(omj.NumObj, omj)
+	sizes, writeCounts := omj.Sizes, omj.WriteCounts
+	for objID := range omj.NumObj {
+		size, n := binary.Uvarint(sizes)
+		if n <= 0 {
+			return fmt.Errorf("invalid sizes of the objects")
+		}
+		sizes = sizes[n:]
+		writeCount, n := binary.Uvarint(writeCounts)
+		if n <= 0 {
+			return fmt.Errorf("invalid write counts of the objects")
+		}
+		writeCounts = writeCounts[n:]
+		om.sizes[objID] = int64(size)
+		om.writeCounts[objID] = uint32(writeCount)
+	}
+	if len(omj.Existing) != len(om.existing)*8 {
+		return fmt.Errorf("invalid length of the existing object bitset. (expected = %d, actual = %d)",
+			len(om.existing)*8, len(omj.Existing))
+	}
+	for i := range om.existing {
+		om.existing[i] = binary.LittleEndian.Uint64(omj.Existing[i*8:])
+	}
+	if omj.ETags != nil {
+		if len(omj.ETags) != omj.NumObj*md5.Size {
+			return fmt.Errorf("invalid length of the ETags. (expected = %d, actual = %d)",
+				omj.NumObj*md5.Size, len(omj.ETags))
+		}
+		om.etags = make([][md5.Size]byte, omj.NumObj)
+		for objID := range om.etags {
+			copy(om.etags[objID][:], omj.ETags[objID*md5.Size:])
+		}
+	}
+	if omj.ETagParts != nil {
+		om.etagParts = make([]uint16, omj.NumObj)
+		err := decodeUvarints(omj.ETagParts, om.etagParts)
+		if err != nil {
+			return fmt.Errorf("invalid numbers of the parts of the ETags. %w", err)
+		}
+	}
+	if omj.Checksums != nil {
+		if omj.NumObj == 0 || len(omj.Checksums)%omj.NumObj != 0 {
+			return fmt.Errorf("invalid length of the checksums. (numObj = %d, actual = %d)",
+				omj.NumObj, len(omj.Checksums))
+		}
+		om.checksumAlgorithm = omj.ChecksumAlgorithm
+		om.checksums = omj.Checksums
+		om.checksumParts = make([]uint16, omj.NumObj)
+		err := decodeUvarints(omj.ChecksumParts, om.checksumParts)
+		if err != nil {
+			return fmt.Errorf("invalid numbers of the parts of the checksums. %w", err)
+		}
+	}
+	if omj.Skipped != nil {
+		om.skipped = make([]uint32, omj.NumObj)
+		err := decodeUvarints(omj.Skipped, om.skipped)
+		if err != nil {
+			return fmt.Errorf("invalid skipped write counts. %w", err)
+		}
+	}
+	if omj.LastModified != nil {
+		om.lastModified = make([]uint32, omj.NumObj)
+		err := decodeUvarints(omj.LastModified, om.lastModified)
+		if err != nil {
+			return fmt.Errorf("invalid last modified times of the objects. %w", err)
+		}
+	}
+	if omj.Metadata != nil {
+		if len(omj.Metadata) != len(om.metadata)*8 {
+			return fmt.Errorf("invalid length of the metadata bitset. (expected = %d, actual = %d)",
+				len(om.metadata)*8, len(omj.Metadata))
+		}
+		for i := range om.metadata {
+			om.metadata[i] = binary.LittleEndian.Uint64(omj.Metadata[i*8:])
+		}
+	}
+	for _, o := range omj.Objects {
+		if o.ID < 0 || int64(omj.NumObj) <= o.ID || o.Object == nil {
+			return fmt.Errorf("invalid object. (id = %d)", o.ID)
+		}
+		om.objects[o.ID] = o.Object
+	}
+	return nil
+}
+
+// decodeUvarints decodes the sequence of uvarints `data` into `values`.
+func decodeUvarints[T uint16 | uint32](data []byte, values []T) error {
+	for i := range values {
+		value, n := binary.Uvarint(data)
+		if n <= 0 || uint64(T(value)) != value {
+			return fmt.Errorf("invalid uvarint at the index %d", i)
+		}
+		data = data[n:]
+		values[i] = T(value)
+	}
+	return nil
+}
+
+// fromLegacyJSON loads the legacy format in which all objects were saved as they are.
+func (om *ObjectMeta) fromLegacyJSON(omj *objectMetaJSON) error {
+	om.reset(len(omj.ObjectList), omj)
+	for objID, obj := range omj.ObjectList {
+		if om.pack(int64(objID), obj) {
+			om.objects[int64(objID)] = obj
+		}
+	}
+	for _, objID := range omj.ExistingObjectIDs {
+		if objID < 0 || int64(om.NumObj()) <= objID {
+			return fmt.Errorf("invalid existing object ID: %d", objID)
+		}
+		om.existing[objID/64] |= 1 << (objID % 64)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package object
+
+import (
+	"fmt"
+	"strings"
+)
+
+// KeyScheme decides the key name of each object from its key ID.
+// The key ID, which is MaxKeyLength bytes, is embedded in the data units instead of the key
+// so that the key can be much longer and contain any characters.
+// All keys start with the prefix of the key ID identifying the process and the worker
+// so that the keys of each process and each worker can be listed by the prefix.
+type KeyScheme string
+
+const (
+	// KeySchemeFlat uses the key ID as it is. e.g. "ov0102000abc"
+	KeySchemeFlat KeyScheme = "flat"
+	// KeySchemeNested splits the key ID after the worker prefix into nested prefixes. e.g. "ov0102/00/0a/bc"
+	KeySchemeNested KeyScheme = "nested"
+	// KeySchemeLong pads the key ID up to MaxS3KeyLength bytes.
+	KeySchemeLong KeyScheme = "long"
+	// KeySchemeUnicode appends the UTF-8 and percent-encoding-sensitive characters to the key ID.
+	KeySchemeUnicode KeyScheme = "unicode"
+	// KeySchemeCase makes pairs of the keys which differ only in case. e.g. "ov0102000abc/key" and "ov0102000abc/KEY"
+	KeySchemeCase KeyScheme = "case"
+	// KeySchemeMixed uses all of the schemes above for the different objects.
+	KeySchemeMixed KeyScheme = "mixed"
+
+	// MaxS3KeyLength is the maximum length of the key names in bytes allowed by S3.
+	MaxS3KeyLength = 1024
+	// The length of each path segment of the long keys.
+	// It is kept short because some storages map each segment to a file name.
+	longKeySegmentLength = 200
+	unicodeKeySuffix     = "/ünï cødé 日本語 +%20%2F&=?#;:@$,!'()*[]~"
+)
+
+// KeySchemes is the list of the valid key schemes.
+var KeySchemes = []KeyScheme{
+	KeySchemeFlat, KeySchemeNested, KeySchemeLong, KeySchemeUnicode, KeySchemeCase, KeySchemeMixed,
+}
+
+// mixedKeySchemes is the list of the schemes used by KeySchemeMixed.
+var mixedKeySchemes = []KeyScheme{
+	KeySchemeFlat, KeySchemeNested, KeySchemeLong, KeySchemeUnicode, KeySchemeCase,
+}
+
+// The key ID is KeyShortPrefix followed by the hex digits of the object ID,
+// which consists of the runner ID, the worker index and the object index from the upper digits.
+// The worker index has only as many digits as needed for the number of the workers,
+// and the rest of the digits are used for the object index.
+const (
+	keyIDDigits    = MaxKeyLength - len(KeyShortPrefix)
+	runnerIDDigits = 2
+	// The number of the digits shared by the worker index and the object index.
+	workerObjDigits = keyIDDigits - runnerIDDigits
+	// MaxObjPerRunner is the maximum number of the objects of a runner.
+	MaxObjPerRunner = 1 << (4 * workerObjDigits)
+	// SharedKeyRunnerID is the runner ID reserved for the shared keys, which is the largest one.
+	SharedKeyRunnerID = 1<<(4*runnerIDDigits) - 1
+	// MaxNumRunners is the maximum number of the runners, whose IDs are less than SharedKeyRunnerID.
+	MaxNumRunners = SharedKeyRunnerID
+)
+
+// generateKey returns the key ID of the object `objID`.
+func generateKey(objID int64) string {
+	return fmt.Sprintf("%s%0*x", KeyShortPrefix, keyIDDigits, objID)
+}
+
+// workerIndexDigits returns the number of the hex digits of the worker index for `numWorker` workers.
+func workerIndexDigits(numWorker int) int {
+	digits := 0
+	for n := numWorker - 1; n > 0; n >>= 4 {
+		digits++
+	}
+	return digits
+}
+
+// MaxObjPerWorker returns the maximum number of the objects of each worker
+// when a runner has `numWorker` workers.
+func MaxObjPerWorker(numWorker int) int {
+	return 1 << (4 * (workerObjDigits - workerIndexDigits(numWorker)))
+}
+
+// keyIDLayout returns the object ID of the first object of the `workerIndex`-th worker
+// of the runner `runnerID` with `numWorker` workers, and the length of the key prefix of the worker.
+func keyIDLayout(runnerID, workerIndex, numWorker int) (int64, int) {
+	objDigits := workerObjDigits - workerIndexDigits(numWorker)
+	keyIDOffset := int64(runnerID)<<(4*workerObjDigits) + int64(workerIndex)<<(4*objDigits)
+	return keyIDOffset, MaxKeyLength - objDigits
+}
+
+// key returns the key name of the object `objID` in the scheme.
+// `prefixLength` is the length of the key prefix of the worker.
+func (s KeyScheme) key(objID int64, prefixLength int) string {
+	keyID := generateKey(objID)
+	switch s {
+	case KeySchemeNested:
+		segments := []string{keyID[:prefixLength]}
+		for i := prefixLength; i < len(keyID); i += 2 {
+			segments = append(segments, keyID[i:min(i+2, len(keyID))])
+		}
+		return strings.Join(segments, "/")
+	case KeySchemeLong:
+		var sb strings.Builder
+		sb.WriteString(keyID)
+		for sb.Len() < MaxS3KeyLength {
+			n := min(longKeySegmentLength, MaxS3KeyLength-sb.Len())
+			sb.WriteString("/")
+			sb.WriteString(strings.Repeat("l", n-1))
+		}
+		return sb.String()
+	case KeySchemeUnicode:
+		return keyID + unicodeKeySuffix
+	case KeySchemeCase:
+		// The pair of the objects share the key ID of the even one.
+		if objID%2 == 0 {
+			return keyID + "/key"
+		}
+		return generateKey(objID-1) + "/KEY"
+	case KeySchemeMixed:
+		// The pair of the objects use the same scheme so that KeySchemeCase makes pairs.
+		return mixedKeySchemes[(objID/2)%int64(len(mixedKeySchemes))].key(objID, prefixLength)
+	default:
+		return keyID
+	}
+}
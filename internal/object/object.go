@@ -1,139 +1,211 @@
 package object
 
 import (
-	"fmt"
-	"log"
-	"log/slog"
-	"math"
-	"math/rand"
-	"os"
-	"strconv"
+	"strings"
 )
 
 const (
 	MaxBucketNameLength = 16
 	MaxKeyLength        = 12
 	KeyShortPrefix      = "ov"
-	// len(KeyShortPrefix) + 2 (for process ID) + 2 (for worker ID)
-	KeyPrefixLength = 6
 )
 
 type Object struct {
-	Key        string `json:"key"`
+	Key string `json:"key"`
+	// KeyID is the key ID embedded in the data units instead of the key.
+	// It is empty if it is the same as the key.
+	KeyID      string `json:"keyID,omitempty"`
 	Size       int    `json:"size"`
 	WriteCount int    `json:"writeCount"`
+	// Segments is set if the current data of the object was copied from other objects.
+	// Each segment corresponds to a contiguous part of the object from the head.
+	Segments []Segment `json:"segments,omitempty"`
+	// Versions is the list of the versions of the object from the oldest one.
+	// It is used only for versioning-enabled buckets.
+	Versions []Version `json:"versions,omitempty"`
+	// Checksum is the checksum of the current data computed at write.
+	// It is nil if the checksum is unknown.
+	Checksum *Checksum `json:"checksum,omitempty"`
+	// ETag is the ETag of the current data returned at write.
+	// It is empty if the ETag is unknown.
+	ETag string `json:"etag,omitempty"`
+	// LastModified is the unix time in seconds of the last modified time
+	// returned by the latest HEAD request. It never decreases.
+	LastModified int64 `json:"lastModified,omitempty"`
+	// Metadata identifies the write which attached the metadata to the current data.
+	// It is nil if the current data was written without the metadata.
+	Metadata *Metadata `json:"metadata,omitempty"`
+	// Uncertain is true if an operation on the object was interrupted
+	// and it is unknown whether the operation took effect.
+	Uncertain bool `json:"uncertain,omitempty"`
+	// Quarantined is true if an error occurred on the object and it is excluded from the workload.
+	Quarantined bool `json:"quarantined,omitempty"`
 }
 
-type ObjectMeta struct {
-	ObjectList          []*Object `json:"objectList"`
-	ExistingObjectIDs   []int64   `json:"existingObjectIDs"`
-	existingObjectIDMap map[int64]struct{}
-	KeyIDOffset         int64 `json:"keyIDOffset"`
-	KeyPrefix           string
+// Metadata identifies the write which attached the user metadata, the tags and the content type to an object.
+type Metadata struct {
+	BucketName string `json:"bucketName"`
+	Key        string `json:"key"`
+	WriteCount int    `json:"writeCount"`
+	WorkerID   int    `json:"workerID"`
 }
 
-func (obj *Object) Clear() {
-	obj.Size = 0
-	obj.WriteCount = 0
+// Checksum is a flexible checksum of the data of an object.
+type Checksum struct {
+	Algorithm string `json:"algorithm"`
+	// Value is the base64-encoded checksum.
+	// The checksums of the composite type are suffixed with the number of parts like "-3".
+	Value string `json:"value"`
 }
 
-func NewObject(objID int64) *Object {
-	return &Object{
-		Key:        generateKey(objID),
-		Size:       0,
-		WriteCount: 0,
-	}
+// IsComposite returns true if the checksum is of the composite type.
+func (c *Checksum) IsComposite() bool {
+	return strings.Contains(c.Value, "-")
 }
 
-func generateKey(objID int64) string {
-	return fmt.Sprintf("%s%010x", KeyShortPrefix, objID)
+// Version is a version of an object in a versioning-enabled bucket.
+type Version struct {
+	VersionID    string    `json:"versionID"`
+	DeleteMarker bool      `json:"deleteMarker,omitempty"`
+	Size         int       `json:"size,omitempty"`
+	WriteCount   int       `json:"writeCount,omitempty"`
+	Segments     []Segment `json:"segments,omitempty"`
+	Checksum     *Checksum `json:"checksum,omitempty"`
+	Metadata     *Metadata `json:"metadata,omitempty"`
 }
 
-func getObjIDFromKey(key string) (int64, error) {
-	return strconv.ParseInt(key[KeyPrefixLength:], 16, 64)
+// DataSource identifies the data embedded in data units.
+type DataSource struct {
+	BucketName string `json:"bucketName"`
+	Key        string `json:"key"`
+	WriteCount int    `json:"writeCount"`
+}
 
+// Segment is a contiguous part of an object whose data came from a single data source.
+type Segment struct {
+	// Offset is the byte offset of the segment's data in the data source object.
+	Offset int        `json:"offset"`
+	Size   int        `json:"size"`
+	Source DataSource `json:"source"`
 }
 
-func NewObjectMeta(numObj int, keyIDOffset int64) *ObjectMeta {
-	om := &ObjectMeta{}
-	om.ObjectList = make([]*Object, numObj)
-	for objID := 0; objID < numObj; objID++ {
-		om.ObjectList[objID] = NewObject(keyIDOffset + int64(objID))
+// EmbeddedKey returns the key ID embedded in the data units of the object.
+func (obj *Object) EmbeddedKey() string {
+	if obj.KeyID != "" {
+		return obj.KeyID
 	}
-	om.ExistingObjectIDs = make([]int64, 0, int(math.Sqrt(float64(numObj))))
-	om.existingObjectIDMap = make(map[int64]struct{})
-	om.KeyIDOffset = keyIDOffset
-	om.KeyPrefix = generateKey(keyIDOffset)[:KeyPrefixLength]
+	return obj.Key
+}
 
-	return om
+func (obj *Object) Clear() {
+	obj.Size = 0
+	obj.WriteCount = 0
+	obj.Segments = nil
+	obj.Versions = nil
+	obj.Checksum = nil
+	obj.ETag = ""
+	obj.Metadata = nil
+	obj.Uncertain = false
 }
 
-func (om *ObjectMeta) GetRandomObject() *Object {
-	objID := rand.Intn(len(om.ObjectList))
-	return om.ObjectList[objID]
+// AddVersion records the current data of the object as a new version.
+func (obj *Object) AddVersion(versionID string) {
+	obj.Versions = append(obj.Versions, Version{
+		VersionID:  versionID,
+		Size:       obj.Size,
+		WriteCount: obj.WriteCount,
+		Segments:   obj.Segments,
+		Checksum:   obj.Checksum,
+		Metadata:   obj.Metadata,
+	})
 }
 
-func (om *ObjectMeta) RegisterToExistingList(key string) {
-	objID, err := getObjIDFromKey(key)
-	if err != nil {
-		slog.Error(err.Error())
-		os.Exit(1)
-	}
-	if _, ok := om.existingObjectIDMap[objID]; ok {
-		// The key is already registered.
-		return
-	}
-	om.ExistingObjectIDs = append(om.ExistingObjectIDs, objID)
-	om.existingObjectIDMap[objID] = struct{}{}
-	if len(om.ObjectList) < len(om.ExistingObjectIDs) {
-		log.Fatal("Invalid contents of existing object ID list.")
-	}
+// AddDeleteMarker records that a delete marker was created as the latest version of the object.
+// Unlike Clear(), the write count is retained so that the data of the next version
+// can be distinguished from the ones of the older versions.
+func (obj *Object) AddDeleteMarker(versionID string) {
+	obj.Size = 0
+	obj.Segments = nil
+	obj.Checksum = nil
+	obj.ETag = ""
+	obj.Metadata = nil
+	obj.Versions = append(obj.Versions, Version{
+		VersionID:    versionID,
+		DeleteMarker: true,
+	})
 }
 
-func (om *ObjectMeta) PopExistingRandomObject() *Object {
-	if len(om.ExistingObjectIDs) == 0 {
-		return nil
-	}
-	eoIDIndex := rand.Intn(len(om.ExistingObjectIDs))
-
-	objID := om.ExistingObjectIDs[eoIDIndex]
-	// Delete the `existingObjID`-th entry from existing object ID list
-	om.ExistingObjectIDs[eoIDIndex] = om.ExistingObjectIDs[len(om.ExistingObjectIDs)-1]
-	om.ExistingObjectIDs = om.ExistingObjectIDs[:len(om.ExistingObjectIDs)-1]
-	if _, ok := om.existingObjectIDMap[objID]; !ok {
-		log.Fatalf("objID 0x%x found in ExistingObjectIDs, but not in existingObjectIDMap.", objID)
+// SkipWriteCount consumes a write count without changing the expected data of the object.
+// It is used when the data of a generation has been written but must never be visible,
+// so that the data can be distinguished from the ones of the other generations.
+func (obj *Object) SkipWriteCount(bucketName string) {
+	if obj.Size != 0 && len(obj.Segments) == 0 {
+		obj.Segments = obj.DataSegments(bucketName, 0, obj.Size)
 	}
-	delete(om.existingObjectIDMap, objID)
-	return om.ObjectList[objID]
+	obj.WriteCount++
 }
 
-func (om *ObjectMeta) GetExistingRandomObject() *Object {
-	if len(om.ExistingObjectIDs) == 0 {
-		return nil
+// VersionObject returns the object whose data is the one of the `i`-th version.
+func (obj *Object) VersionObject(i int) *Object {
+	v := &obj.Versions[i]
+	return &Object{
+		Key:        obj.Key,
+		KeyID:      obj.KeyID,
+		Size:       v.Size,
+		WriteCount: v.WriteCount,
+		Segments:   v.Segments,
+		Checksum:   v.Checksum,
+		Metadata:   v.Metadata,
 	}
-	eoIDIndex := rand.Intn(len(om.ExistingObjectIDs))
-
-	objID := om.ExistingObjectIDs[eoIDIndex]
-	return om.ObjectList[objID]
 }
 
-func (om *ObjectMeta) Exist(key string) bool {
-	objID, err := getObjIDFromKey(key)
-	if err != nil {
-		slog.Error(err.Error())
-		os.Exit(1)
+// DataSegments returns the segments of the data in the range [start, start+length)
+// of the object stored in the bucket `bucketName`.
+func (obj *Object) DataSegments(bucketName string, start, length int) []Segment {
+	if len(obj.Segments) == 0 {
+		return []Segment{
+			{
+				Offset: start,
+				Size:   length,
+				Source: DataSource{
+					BucketName: bucketName,
+					Key:        obj.EmbeddedKey(),
+					WriteCount: obj.WriteCount,
+				},
+			},
+		}
 	}
-	_, ok := om.existingObjectIDMap[objID]
-	return ok
+	segments := make([]Segment, 0, 1)
+	end := start + length
+	segStart := 0
+	for _, seg := range obj.Segments {
+		segEnd := segStart + seg.Size
+		if start < segEnd && segStart < end {
+			from := max(start, segStart)
+			to := min(end, segEnd)
+			segments = append(segments, Segment{
+				Offset: seg.Offset + from - segStart,
+				Size:   to - from,
+				Source: seg.Source,
+			})
+		}
+		segStart = segEnd
+	}
+	return segments
 }
 
-func (om *ObjectMeta) GetHeadAndTailKey() (string, string) {
-	return om.ObjectList[0].Key, om.ObjectList[len(om.ObjectList)-1].Key
+func NewObject(objID int64) *Object {
+	return newObjectWithScheme(objID, KeySchemeFlat, MaxKeyLength)
 }
 
-func (om *ObjectMeta) TidyUp() {
-	om.existingObjectIDMap = make(map[int64]struct{})
-	for _, objID := range om.ExistingObjectIDs {
-		om.existingObjectIDMap[objID] = struct{}{}
+func newObjectWithScheme(objID int64, scheme KeyScheme, prefixLength int) *Object {
+	obj := &Object{
+		Key:        scheme.key(objID, prefixLength),
+		Size:       0,
+		WriteCount: 0,
+	}
+	if keyID := generateKey(objID); keyID != obj.Key {
+		obj.KeyID = keyID
 	}
+	return obj
 }
@@ -0,0 +1,457 @@
+package object
+
+import (
+	"crypto/md5"
+	"fmt"
+	"log"
+	"log/slog"
+	"math"
+	"math/bits"
+	"math/rand"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ObjectMeta is the metadata of the objects of a worker.
+// The sizes and the write counts are packed into the arrays and the keys are derived from the object IDs,
+// so that a huge number of objects can be handled with a small memory footprint.
+// An Object is materialized when it is requested, and it remains materialized only while it has the details
+// such as the segments copied from the other objects or the versions.
+// The ETags, the checksums, the last modified times, the skipped write counts and the metadata attached by the writer
+// are packed as well.
+type ObjectMeta struct {
+	sizes       []int64
+	writeCounts []uint32
+	// etags is the MD5 digests of the ETags, which are zero if the ETags are unknown.
+	// It is nil until the first ETag is packed.
+	etags [][md5.Size]byte
+	// etagParts is the number of the parts of each multipart ETag, which is zero for the single-part ETags.
+	// It is nil until the first multipart ETag is packed.
+	etagParts []uint16
+	// checksumAlgorithm is the algorithm of all packed checksums. It is decided by the first packed checksum.
+	checksumAlgorithm string
+	// checksums is the concatenation of the decoded checksums of the fixed size.
+	// It is nil until the first checksum is packed.
+	checksums []byte
+	// checksumParts is 0 if the checksum is unknown, 1 for the full object checksums,
+	// and the number of the parts plus one for the composite checksums.
+	// It is nil until the first checksum is packed.
+	checksumParts []uint16
+	// skipped is the number of the write counts consumed since the current data was written.
+	// It is nil until the first skipped write count is packed.
+	skipped []uint32
+	// lastModified is nil until the first last modified time is packed.
+	lastModified []uint32
+	// metadata is the bitset of the objects whose metadata was attached by the write of the current data by the writer.
+	metadata []uint64
+	// writer is nil if the writer is unknown. Then the metadata and the skipped write counts are never packed.
+	writer *Writer
+	// existing is the bitset of the existing objects.
+	existing []uint64
+	// existingObjectIDs is the list of the existing objects used to pick one of them randomly.
+	existingObjectIDs []uint32
+	// existingPos is the position of each existing object in existingObjectIDs.
+	existingPos []uint32
+	// objects is the map of the materialized objects.
+	objects map[int64]*Object
+	// inUse is the list of the objects materialized since the last Release().
+	inUse []int64
+	// before is the state of each object in inUse when it was requested first since the last Release().
+	before []objectSnapshot
+	// useHook is called with the key of each object requested, if it is not nil.
+	useHook     func(key string)
+	KeyIDOffset int64
+	KeyPrefix   string
+	// KeyScheme is empty for the contexts saved before the key schemes were introduced.
+	KeyScheme KeyScheme
+}
+
+// NewObjectMeta returns the metadata of the `numObj` objects of the `workerIndex`-th worker
+// of the runner `runnerID` with `numWorker` workers.
+func NewObjectMeta(numObj, runnerID, workerIndex, numWorker int, keyScheme KeyScheme) *ObjectMeta {
+	keyIDOffset, prefixLength := keyIDLayout(runnerID, workerIndex, numWorker)
+	om := &ObjectMeta{
+		sizes:       make([]int64, numObj),
+		writeCounts: make([]uint32, numObj),
+		existing:    make([]uint64, (numObj+63)/64),
+		metadata:    make([]uint64, (numObj+63)/64),
+		KeyIDOffset: keyIDOffset,
+		KeyPrefix:   generateKey(keyIDOffset)[:prefixLength],
+		KeyScheme:   keyScheme,
+	}
+	om.TidyUp()
+	return om
+}
+
+// NumObj returns the number of the objects.
+func (om *ObjectMeta) NumObj() int {
+	return len(om.sizes)
+}
+
+func (om *ObjectMeta) keyScheme() KeyScheme {
+	if om.KeyScheme == "" {
+		return KeySchemeFlat
+	}
+	return om.KeyScheme
+}
+
+func (om *ObjectMeta) key(objID int64) string {
+	return om.keyScheme().key(om.KeyIDOffset+objID, len(om.KeyPrefix))
+}
+
+func (om *ObjectMeta) getObjIDFromKey(key string) (int64, error) {
+	// The key ID is the first MaxKeyLength bytes of the key except the slashes.
+	keyID := make([]byte, 0, MaxKeyLength)
+	for i := 0; i < len(key) && len(keyID) < MaxKeyLength; i++ {
+		if key[i] != '/' {
+			keyID = append(keyID, key[i])
+		}
+	}
+	if len(keyID) != MaxKeyLength || !strings.HasPrefix(string(keyID), KeyShortPrefix) {
+		return 0, fmt.Errorf("invalid key: %s", key)
+	}
+	id, err := strconv.ParseInt(string(keyID[len(KeyShortPrefix):]), 16, 64)
+	if err != nil {
+		return 0, err
+	}
+	// The odd one of a pair of KeySchemeCase has the key ID of the even one.
+	for _, objID := range []int64{id - om.KeyIDOffset, id - om.KeyIDOffset + 1} {
+		if 0 <= objID && objID < int64(om.NumObj()) && om.key(objID) == key {
+			return objID, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown key: %s", key)
+}
+
+// objectSnapshot is the state of an object saved to restore it.
+type objectSnapshot struct {
+	objID    int64
+	obj      Object
+	existing bool
+}
+
+// GetObjectByID returns the `objID`-th object.
+func (om *ObjectMeta) GetObjectByID(objID int64) *Object {
+	om.inUse = append(om.inUse, objID)
+	obj, ok := om.objects[objID]
+	if !ok {
+		obj = newObjectWithScheme(om.KeyIDOffset+objID, om.keyScheme(), len(om.KeyPrefix))
+		p := om.packedAt(objID)
+		om.unpack(obj, &p)
+		om.objects[objID] = obj
+	}
+	if !slices.ContainsFunc(om.before, func(s objectSnapshot) bool { return s.objID == objID }) {
+		snapshot := objectSnapshot{objID: objID, obj: *obj, existing: om.isExisting(objID)}
+		// The slices may be modified in place.
+		snapshot.obj.Segments = slices.Clone(obj.Segments)
+		snapshot.obj.Versions = slices.Clone(obj.Versions)
+		om.before = append(om.before, snapshot)
+	}
+	if om.useHook != nil {
+		om.useHook(obj.Key)
+	}
+	return obj
+}
+
+// SetUseHook sets the function called with the key of each object requested.
+// The hook is called before the object is returned, so it can record the object before the use.
+func (om *ObjectMeta) SetUseHook(hook func(key string)) {
+	om.useHook = hook
+}
+
+// Release packs the objects materialized since the last call into the arrays.
+// The objects without the details are dematerialized,
+// so the pointers to the objects must not be used after that.
+func (om *ObjectMeta) Release() {
+	for _, objID := range om.inUse {
+		obj, ok := om.objects[objID]
+		if !ok {
+			continue
+		}
+		if !om.pack(objID, obj) {
+			delete(om.objects, objID)
+		}
+	}
+	om.inUse = om.inUse[:0]
+	om.before = om.before[:0]
+}
+
+// MarkUncertain restores the objects materialized since the last Release() to the states
+// when they were requested first, and marks them as uncertain.
+// The changes made to them by the interrupted operation may or may not have taken effect.
+func (om *ObjectMeta) MarkUncertain() {
+	for _, s := range om.before {
+		*om.objects[s.objID] = s.obj
+		om.objects[s.objID].Uncertain = true
+		if s.existing && !om.isExisting(s.objID) {
+			om.RegisterToExistingList(om.key(s.objID))
+		} else if !s.existing && om.isExisting(s.objID) {
+			om.removeExisting(s.objID)
+		}
+	}
+}
+
+// MarkUncertainKey marks the object with `key` as uncertain.
+// It returns false if the key is unknown.
+func (om *ObjectMeta) MarkUncertainKey(key string) bool {
+	objID, err := om.getObjIDFromKey(key)
+	if err != nil {
+		return false
+	}
+	om.GetObjectByID(objID).Uncertain = true
+	return true
+}
+
+// UncertainObjects returns the objects marked as uncertain except the quarantined ones.
+func (om *ObjectMeta) UncertainObjects() []*Object {
+	// The uncertain objects are always materialized.
+	objs := make([]*Object, 0)
+	for objID, obj := range om.objects {
+		if obj.Uncertain && !obj.Quarantined {
+			objs = append(objs, om.GetObjectByID(objID))
+		}
+	}
+	return objs
+}
+
+// Quarantine excludes the object with `key` from the workload.
+// The object is no longer regarded as existing, whatever its actual state is.
+// It returns false if the key is unknown or already quarantined.
+func (om *ObjectMeta) Quarantine(key string) bool {
+	objID, err := om.getObjIDFromKey(key)
+	if err != nil {
+		return false
+	}
+	obj := om.GetObjectByID(objID)
+	if obj.Quarantined {
+		return false
+	}
+	obj.Quarantined = true
+	om.UnregisterFromExistingList(key)
+	return true
+}
+
+// QuarantineInUse quarantines the objects materialized since the last Release() and returns their keys.
+func (om *ObjectMeta) QuarantineInUse() []string {
+	keys := make([]string, 0, len(om.inUse))
+	for _, objID := range slices.Clone(om.inUse) {
+		key := om.objects[objID].Key
+		if om.Quarantine(key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// IsQuarantined returns true if `key` is the key of a quarantined object.
+// It returns false for the unknown keys.
+func (om *ObjectMeta) IsQuarantined(key string) bool {
+	objID, err := om.getObjIDFromKey(key)
+	if err != nil {
+		return false
+	}
+	// The quarantined objects are always materialized.
+	obj, ok := om.objects[objID]
+	return ok && obj.Quarantined
+}
+
+// QuarantinedObjects returns the quarantined objects.
+func (om *ObjectMeta) QuarantinedObjects() []*Object {
+	objs := make([]*Object, 0)
+	for objID, obj := range om.objects {
+		if obj.Quarantined {
+			objs = append(objs, om.GetObjectByID(objID))
+		}
+	}
+	return objs
+}
+
+// GetObject returns the object with `key`.
+func (om *ObjectMeta) GetObject(key string) *Object {
+	objID, err := om.getObjIDFromKey(key)
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+	return om.GetObjectByID(objID)
+}
+
+// FindObject returns the object with `key`.
+// Unlike GetObject(), it returns an error if the key is unknown.
+func (om *ObjectMeta) FindObject(key string) (*Object, error) {
+	objID, err := om.getObjIDFromKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return om.GetObjectByID(objID), nil
+}
+
+// GetRandomObject returns a random object.
+// It returns nil if the chosen object is quarantined.
+func (om *ObjectMeta) GetRandomObject() *Object {
+	objID := int64(rand.Intn(om.NumObj()))
+	if obj, ok := om.objects[objID]; ok && obj.Quarantined {
+		return nil
+	}
+	return om.GetObjectByID(objID)
+}
+
+func (om *ObjectMeta) isExisting(objID int64) bool {
+	return om.existing[objID/64]&(1<<(objID%64)) != 0
+}
+
+func (om *ObjectMeta) RegisterToExistingList(key string) {
+	objID, err := om.getObjIDFromKey(key)
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+	if om.isExisting(objID) {
+		// The key is already registered.
+		return
+	}
+	om.existingPos[objID] = uint32(len(om.existingObjectIDs))
+	om.existingObjectIDs = append(om.existingObjectIDs, uint32(objID))
+	om.existing[objID/64] |= 1 << (objID % 64)
+	if om.NumObj() < len(om.existingObjectIDs) {
+		log.Fatal("Invalid contents of existing object ID list.")
+	}
+}
+
+func (om *ObjectMeta) PopExistingRandomObject() *Object {
+	if len(om.existingObjectIDs) == 0 {
+		return nil
+	}
+	eoIDIndex := rand.Intn(len(om.existingObjectIDs))
+
+	objID := int64(om.existingObjectIDs[eoIDIndex])
+	if !om.isExisting(objID) {
+		log.Fatalf("objID 0x%x found in existingObjectIDs, but not in the existing bitset.", objID)
+	}
+	// The object is requested before the removal, so that it can be restored as existing.
+	obj := om.GetObjectByID(objID)
+	om.removeExisting(objID)
+	return obj
+}
+
+// removeExisting removes the `objID`-th object from the existing object list.
+func (om *ObjectMeta) removeExisting(objID int64) {
+	// Move the last entry of the existing object ID list to the position of the removed one.
+	i := om.existingPos[objID]
+	last := om.existingObjectIDs[len(om.existingObjectIDs)-1]
+	om.existingObjectIDs[i] = last
+	om.existingPos[last] = i
+	om.existingObjectIDs = om.existingObjectIDs[:len(om.existingObjectIDs)-1]
+	om.existing[objID/64] &^= 1 << (objID % 64)
+}
+
+// UnregisterFromExistingList removes `key` from the existing object list.
+// It does nothing if the key is not registered.
+func (om *ObjectMeta) UnregisterFromExistingList(key string) {
+	objID, err := om.getObjIDFromKey(key)
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+	if !om.isExisting(objID) {
+		return
+	}
+	om.removeExisting(objID)
+}
+
+func (om *ObjectMeta) GetExistingRandomObject() *Object {
+	if len(om.existingObjectIDs) == 0 {
+		return nil
+	}
+	eoIDIndex := rand.Intn(len(om.existingObjectIDs))
+
+	objID := om.existingObjectIDs[eoIDIndex]
+	return om.GetObjectByID(int64(objID))
+}
+
+// ObjectSummary is the part of the state of an object which is returned by the LIST operations.
+type ObjectSummary struct {
+	Key  string
+	Size int
+	// ETag is empty if it is unknown.
+	ETag string
+}
+
+// ExistingObjectSummaries returns the summaries of all of the existing objects in the lexicographic order
+// of the keys without materializing the objects.
+func (om *ObjectMeta) ExistingObjectSummaries() []ObjectSummary {
+	summaries := make([]ObjectSummary, 0, len(om.existingObjectIDs))
+	for i, word := range om.existing {
+		for ; word != 0; word &= word - 1 {
+			objID := int64(i*64 + bits.TrailingZeros64(word))
+			summary := ObjectSummary{Key: om.key(objID)}
+			// The materialized objects may not have been packed yet.
+			if obj, ok := om.objects[objID]; ok {
+				summary.Size, summary.ETag = obj.Size, obj.ETag
+			} else {
+				p := om.packedAt(objID)
+				summary.Size = int(p.size)
+				if p.etag != [md5.Size]byte{} {
+					summary.ETag = formatETag(p.etag, p.etagParts)
+				}
+			}
+			summaries = append(summaries, summary)
+		}
+	}
+	// The object IDs are in the lexicographic order of the keys except for some key schemes.
+	if !slices.IsSortedFunc(summaries, compareSummaryKeys) {
+		slices.SortFunc(summaries, compareSummaryKeys)
+	}
+	return summaries
+}
+
+func compareSummaryKeys(a, b ObjectSummary) int {
+	return strings.Compare(a.Key, b.Key)
+}
+
+// NumVersions returns the total number of the versions of all objects except the quarantined ones.
+func (om *ObjectMeta) NumVersions() int {
+	// The objects with the versions are always materialized.
+	numVersions := 0
+	for _, obj := range om.objects {
+		if !obj.Quarantined {
+			numVersions += len(obj.Versions)
+		}
+	}
+	return numVersions
+}
+
+func (om *ObjectMeta) Exist(key string) bool {
+	objID, err := om.getObjIDFromKey(key)
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+	return om.isExisting(objID)
+}
+
+func (om *ObjectMeta) GetHeadAndTailKey() (string, string) {
+	return om.key(0), om.key(int64(om.NumObj() - 1))
+}
+
+// TidyUp rebuilds the in-memory structures which are not saved.
+func (om *ObjectMeta) TidyUp() {
+	if om.objects == nil {
+		om.objects = make(map[int64]*Object)
+	}
+	numExisting := 0
+	for _, word := range om.existing {
+		numExisting += bits.OnesCount64(word)
+	}
+	om.existingObjectIDs = make([]uint32, 0, max(numExisting, int(math.Sqrt(float64(om.NumObj())))))
+	om.existingPos = make([]uint32, om.NumObj())
+	for i, word := range om.existing {
+		for ; word != 0; word &= word - 1 {
+			objID := i*64 + bits.TrailingZeros64(word)
+			om.existingPos[objID] = uint32(len(om.existingObjectIDs))
+			om.existingObjectIDs = append(om.existingObjectIDs, uint32(objID))
+		}
+	}
+}
@@ -0,0 +1,398 @@
+package object
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetObjectByKey(t *testing.T) {
+	for _, keyScheme := range KeySchemes {
+		om := NewObjectMeta(9, 1, 2, 3, keyScheme)
+		keys := make(map[string]struct{})
+		for objID := range int64(om.NumObj()) {
+			obj := om.GetObjectByID(objID)
+			assert.Truef(t, len(obj.Key) <= MaxS3KeyLength, "keyScheme: %s, key: %s", keyScheme, obj.Key)
+			assert.Equal(t, MaxKeyLength, len(obj.EmbeddedKey()))
+			assert.Truef(t, len(obj.Key) >= len(om.KeyPrefix) && obj.Key[:len(om.KeyPrefix)] == om.KeyPrefix,
+				"keyScheme: %s, key: %s, prefix: %s", keyScheme, obj.Key, om.KeyPrefix)
+			assert.Same(t, obj, om.GetObject(obj.Key))
+			keys[obj.Key] = struct{}{}
+		}
+		assert.Equalf(t, om.NumObj(), len(keys), "keyScheme: %s", keyScheme)
+		_, err := om.getObjIDFromKey("ov0000000000")
+		assert.Error(t, err)
+	}
+}
+
+func TestKeySchemeCase(t *testing.T) {
+	om := NewObjectMeta(2, 0, 0, 1, KeySchemeCase)
+	even := om.GetObjectByID(0)
+	odd := om.GetObjectByID(1)
+	assert.NotEqual(t, even.Key, odd.Key)
+	assert.Equal(t, even.Key[:len(even.Key)-3], odd.Key[:len(odd.Key)-3])
+	assert.NotEqual(t, even.EmbeddedKey(), odd.EmbeddedKey())
+}
+
+func TestKeyIDLayout(t *testing.T) {
+	type testCase struct {
+		numWorker          int
+		expectedPrefix     string
+		expectedMaxObjects int
+	}
+	testCases := []testCase{
+		{numWorker: 1, expectedPrefix: "ov03", expectedMaxObjects: 1 << 32},
+		{numWorker: 16, expectedPrefix: "ov03f", expectedMaxObjects: 1 << 28},
+		{numWorker: 17, expectedPrefix: "ov0310", expectedMaxObjects: 1 << 24},
+		{numWorker: 4096, expectedPrefix: "ov03fff", expectedMaxObjects: 1 << 20},
+		{numWorker: 65536, expectedPrefix: "ov03ffff", expectedMaxObjects: 1 << 16},
+	}
+	for _, tc := range testCases {
+		om := NewObjectMeta(1, 3, tc.numWorker-1, tc.numWorker, KeySchemeFlat)
+		assert.Equal(t, tc.expectedPrefix, om.KeyPrefix)
+		assert.Equal(t, tc.expectedMaxObjects, MaxObjPerWorker(tc.numWorker))
+	}
+}
+
+func TestExistingList(t *testing.T) {
+	om := NewObjectMeta(100, 0, 0, 1, KeySchemeFlat)
+	for objID := int64(0); objID < 100; objID += 2 {
+		om.RegisterToExistingList(om.GetObjectByID(objID).Key)
+	}
+	// Registering twice is allowed.
+	om.RegisterToExistingList(om.GetObjectByID(0).Key)
+	assert.Len(t, om.ExistingObjectSummaries(), 50)
+	for objID := range int64(100) {
+		assert.Equal(t, objID%2 == 0, om.Exist(om.GetObjectByID(objID).Key))
+	}
+
+	om.UnregisterFromExistingList(om.GetObjectByID(10).Key)
+	om.UnregisterFromExistingList(om.GetObjectByID(11).Key)
+	assert.False(t, om.Exist(om.GetObjectByID(10).Key))
+	assert.Len(t, om.ExistingObjectSummaries(), 49)
+	for i, objID := range om.existingObjectIDs {
+		assert.Equal(t, uint32(i), om.existingPos[objID])
+	}
+
+	for range 10 {
+		obj := om.GetExistingRandomObject()
+		require.NotNil(t, obj)
+		assert.True(t, om.Exist(obj.Key))
+	}
+	for range 49 {
+		obj := om.PopExistingRandomObject()
+		require.NotNil(t, obj)
+		assert.False(t, om.Exist(obj.Key))
+	}
+	assert.Nil(t, om.PopExistingRandomObject())
+	assert.Nil(t, om.GetExistingRandomObject())
+}
+
+func TestExistingObjectSummaries(t *testing.T) {
+	for _, keyScheme := range KeySchemes {
+		om := NewObjectMeta(20, 0, 0, 1, keyScheme)
+		for objID := int64(0); objID < 20; objID += 3 {
+			obj := om.GetObjectByID(objID)
+			obj.Size = int(objID) * 100
+			obj.ETag = `"0123456789abcdef0123456789abcdef"`
+			om.RegisterToExistingList(obj.Key)
+		}
+		om.Release()
+		// The object in use is summarized from the latest state.
+		inUse := om.GetObjectByID(6)
+		inUse.Size = 1
+		inUse.ETag = `"0123456789abcdef0123456789abcdef-2"`
+
+		summaries := om.ExistingObjectSummaries()
+		require.Len(t, summaries, 7, "keyScheme: %s", keyScheme)
+		assert.Truef(t, slices.IsSortedFunc(summaries, func(a, b ObjectSummary) int {
+			return strings.Compare(a.Key, b.Key)
+		}), "keyScheme: %s", keyScheme)
+		for _, s := range summaries {
+			obj := om.GetObject(s.Key)
+			assert.Equal(t, obj.Size, s.Size)
+			assert.Equal(t, obj.ETag, s.ETag)
+		}
+		om.Release()
+	}
+}
+
+func TestRelease(t *testing.T) {
+	om := NewObjectMeta(10, 0, 0, 1, KeySchemeNested)
+	plain := om.GetObjectByID(3)
+	plain.Size = 1000
+	plain.WriteCount = 2
+	detailed := om.GetObjectByID(4)
+	detailed.Size = 2000
+	detailed.ETag = `"etag"`
+	detailed.AddVersion("v1")
+	assert.Same(t, plain, om.GetObjectByID(3))
+	om.Release()
+
+	// The object without the details is packed and dematerialized.
+	obj := om.GetObjectByID(3)
+	assert.NotSame(t, plain, obj)
+	assert.Equal(t, plain.Key, obj.Key)
+	assert.Equal(t, 1000, obj.Size)
+	assert.Equal(t, 2, obj.WriteCount)
+	// The object with the details remains materialized.
+	assert.Same(t, detailed, om.GetObjectByID(4))
+	assert.Equal(t, 1, om.NumVersions())
+
+	obj.Clear()
+	om.Release()
+	obj = om.GetObjectByID(3)
+	assert.Equal(t, 0, obj.Size)
+	assert.Equal(t, 0, obj.WriteCount)
+}
+
+func TestReleaseWrittenObject(t *testing.T) {
+	om := NewObjectMeta(10, 0, 0, 1, KeySchemeFlat)
+	om.SetWriter("bucket", 0x12)
+	// Update the object as a single-part write does.
+	written := om.GetObjectByID(3)
+	written.Size = 1000
+	written.WriteCount++
+	written.Metadata = &Metadata{
+		BucketName: "bucket",
+		Key:        written.EmbeddedKey(),
+		WriteCount: written.WriteCount,
+		WorkerID:   0x12,
+	}
+	written.ETag = `"0123456789abcdef0123456789abcdef"`
+	written.LastModified = 1700000000
+	multipart := om.GetObjectByID(4)
+	multipart.Size = 2000
+	multipart.WriteCount++
+	multipart.ETag = `"0123456789abcdef0123456789abcdef-2"`
+	foreign := om.GetObjectByID(5)
+	foreign.WriteCount++
+	foreign.Metadata = &Metadata{
+		BucketName: "bucket",
+		Key:        foreign.EmbeddedKey(),
+		WriteCount: foreign.WriteCount,
+		WorkerID:   0x13,
+	}
+	om.Release()
+
+	// The written object is packed and dematerialized.
+	_, ok := om.objects[3]
+	assert.False(t, ok)
+	obj := om.GetObjectByID(3)
+	assert.NotSame(t, written, obj)
+	assert.Equal(t, written, obj)
+	// The multipart ETag is packed as well.
+	obj = om.GetObjectByID(4)
+	assert.NotSame(t, multipart, obj)
+	assert.Equal(t, multipart, obj)
+	// The object with the metadata of another worker remains materialized.
+	assert.Same(t, foreign, om.GetObjectByID(5))
+	om.Release()
+
+	data, err := json.Marshal(om)
+	require.NoError(t, err)
+	loaded := &ObjectMeta{}
+	require.NoError(t, json.Unmarshal(data, loaded))
+	_, ok = loaded.objects[3]
+	assert.False(t, ok)
+	for objID := range int64(om.NumObj()) {
+		assert.Equal(t, om.GetObjectByID(objID), loaded.GetObjectByID(objID))
+	}
+	om.Release()
+
+	// The deletion clears the packed state except for the last modified time.
+	obj = om.GetObjectByID(3)
+	obj.Clear()
+	om.Release()
+	obj = om.GetObjectByID(3)
+	assert.Nil(t, obj.Metadata)
+	assert.Empty(t, obj.ETag)
+	assert.Equal(t, int64(1700000000), obj.LastModified)
+}
+
+func TestPackMixedWorkload(t *testing.T) {
+	om := NewObjectMeta(10, 0, 0, 1, KeySchemeFlat)
+	om.SetWriter("bucket", 0x12)
+	expected := make(map[int64]Object)
+	for i := range 50 {
+		objID := int64(i % 4)
+		obj := om.GetObjectByID(objID)
+		switch i % 5 {
+		case 0, 3:
+			// Write the object by a single-part or a multipart upload with the checksum.
+			obj.Size = 1000 * (i + 1)
+			obj.WriteCount++
+			obj.Segments = nil
+			obj.Metadata = &Metadata{BucketName: "bucket", Key: obj.EmbeddedKey(), WriteCount: obj.WriteCount, WorkerID: 0x12}
+			if i%2 == 0 {
+				obj.ETag = `"0123456789abcdef0123456789abcdef"`
+				obj.Checksum = &Checksum{Algorithm: "CRC64NVME", Value: "AAAAAAAAAAE="}
+			} else {
+				obj.ETag = fmt.Sprintf(`"0123456789abcdef0123456789abcdef-%d"`, i)
+				obj.Checksum = &Checksum{Algorithm: "CRC64NVME", Value: fmt.Sprintf("AAAAAAAAAAI=-%d", i)}
+			}
+		case 1, 4:
+			// Upload the data which must never be visible.
+			obj.SkipWriteCount("bucket")
+		case 2:
+			obj.LastModified = int64(1700000000 + i)
+		}
+		expected[objID] = *obj
+		om.Release()
+		assert.Empty(t, om.objects, "i = %d", i)
+	}
+
+	data, err := json.Marshal(om)
+	require.NoError(t, err)
+	loaded := &ObjectMeta{}
+	require.NoError(t, json.Unmarshal(data, loaded))
+	for objID, obj := range expected {
+		assert.Equal(t, &obj, om.GetObjectByID(objID))
+		assert.Equal(t, &obj, loaded.GetObjectByID(objID))
+	}
+	om.Release()
+	loaded.Release()
+	assert.Empty(t, loaded.objects)
+
+	// The checksum of another algorithm remains materialized.
+	obj := om.GetObjectByID(5)
+	obj.Checksum = &Checksum{Algorithm: "SHA256", Value: "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="}
+	om.Release()
+	assert.Same(t, obj, om.GetObjectByID(5))
+}
+
+func TestUncertainObjects(t *testing.T) {
+	om := NewObjectMeta(10, 0, 0, 1, KeySchemeFlat)
+	om.GetObjectByID(1)
+	om.Release()
+	obj := om.GetObjectByID(2)
+	obj.Size = 100
+	obj.WriteCount = 1
+	om.RegisterToExistingList(obj.Key)
+	om.Release()
+
+	// The interrupted operation overwrote and deleted the object.
+	obj = om.GetObjectByID(2)
+	obj.AddVersion("v1")
+	obj.WriteCount++
+	obj.Size = 200
+	om.GetObjectByID(2)
+	om.UnregisterFromExistingList(obj.Key)
+	om.MarkUncertain()
+	om.Release()
+
+	uncertain := om.UncertainObjects()
+	require.Len(t, uncertain, 1)
+	// The object is restored to the state before the operation.
+	assert.Equal(t, 100, uncertain[0].Size)
+	assert.Equal(t, 1, uncertain[0].WriteCount)
+	assert.Empty(t, uncertain[0].Versions)
+	assert.True(t, om.Exist(obj.Key))
+	uncertain[0].Clear()
+	om.Release()
+	assert.Empty(t, om.UncertainObjects())
+
+	// The popped object is restored as existing.
+	obj = om.PopExistingRandomObject()
+	obj.Clear()
+	om.MarkUncertain()
+	om.Release()
+	assert.True(t, om.Exist(obj.Key))
+}
+
+func TestQuarantine(t *testing.T) {
+	om := NewObjectMeta(2, 0, 0, 1, KeySchemeFlat)
+	obj := om.GetObjectByID(0)
+	obj.AddVersion("v1")
+	om.RegisterToExistingList(obj.Key)
+	key := obj.Key
+	assert.Equal(t, []string{key}, om.QuarantineInUse())
+	om.Release()
+
+	assert.True(t, om.IsQuarantined(key))
+	assert.False(t, om.IsQuarantined(om.GetObjectByID(1).Key))
+	assert.False(t, om.IsQuarantined("unknown"))
+	assert.False(t, om.Quarantine("unknown"))
+	assert.False(t, om.Quarantine(key))
+	assert.False(t, om.Exist(key))
+	assert.Equal(t, 0, om.NumVersions())
+	require.Len(t, om.QuarantinedObjects(), 1)
+	for range 10 {
+		obj := om.GetRandomObject()
+		if obj != nil {
+			assert.NotEqual(t, key, obj.Key)
+		}
+	}
+	om.Release()
+
+	data, err := json.Marshal(om)
+	require.NoError(t, err)
+	loaded := &ObjectMeta{}
+	require.NoError(t, json.Unmarshal(data, loaded))
+	assert.True(t, loaded.IsQuarantined(key))
+}
+
+func TestObjectMetaJSON(t *testing.T) {
+	om := NewObjectMeta(130, 2, 1, 4, KeySchemeMixed)
+	for objID := int64(0); objID < 130; objID += 3 {
+		obj := om.GetObjectByID(objID)
+		obj.Size = int(objID) * 1000
+		obj.WriteCount = int(objID)
+		if objID%2 == 0 {
+			obj.Checksum = &Checksum{Algorithm: "CRC32", Value: "AAAAAA=="}
+		}
+		om.RegisterToExistingList(obj.Key)
+	}
+	// The objects are saved even if they are not released.
+	data, err := json.Marshal(om)
+	require.NoError(t, err)
+
+	loaded := &ObjectMeta{}
+	require.NoError(t, json.Unmarshal(data, loaded))
+	assert.Equal(t, om.NumObj(), loaded.NumObj())
+	assert.Equal(t, om.KeyIDOffset, loaded.KeyIDOffset)
+	assert.Equal(t, om.KeyPrefix, loaded.KeyPrefix)
+	assert.Equal(t, om.KeyScheme, loaded.KeyScheme)
+	assert.ElementsMatch(t, om.existingObjectIDs, loaded.existingObjectIDs)
+	for objID := range int64(om.NumObj()) {
+		expected := om.GetObjectByID(objID)
+		actual := loaded.GetObjectByID(objID)
+		assert.Equal(t, expected, actual)
+		assert.Equal(t, om.Exist(expected.Key), loaded.Exist(actual.Key))
+	}
+
+	assert.Error(t, json.Unmarshal([]byte(`{"numObj": 2, "sizes": "", "writeCounts": "", "existing": ""}`), &ObjectMeta{}))
+}
+
+func TestObjectMetaLegacyJSON(t *testing.T) {
+	legacy := `{
+		"objectList": [
+			{"key": "ov0102000000", "size": 0, "writeCount": 0},
+			{"key": "ov0102000001", "size": 4096, "writeCount": 3},
+			{"key": "ov0102000002", "size": 512, "writeCount": 1, "etag": "\"abc\""}
+		],
+		"existingObjectIDs": [2, 1],
+		"keyIDOffset": 4328521728,
+		"KeyPrefix": "ov0102"
+	}`
+	om := &ObjectMeta{}
+	require.NoError(t, json.Unmarshal([]byte(legacy), om))
+	assert.Equal(t, 3, om.NumObj())
+	assert.Equal(t, "ov0102000000", om.GetObjectByID(0).Key)
+	assert.False(t, om.Exist("ov0102000000"))
+
+	obj := om.GetObject("ov0102000001")
+	assert.Equal(t, 4096, obj.Size)
+	assert.Equal(t, 3, obj.WriteCount)
+	assert.True(t, om.Exist(obj.Key))
+
+	obj = om.GetObject("ov0102000002")
+	assert.Equal(t, 512, obj.Size)
+	assert.Equal(t, `"abc"`, obj.ETag)
+	assert.True(t, om.Exist(obj.Key))
+}
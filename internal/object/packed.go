@@ -0,0 +1,308 @@
+package object
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Writer identifies the worker which writes the objects to a bucket.
+// It is used to derive the metadata attached by the writes of the objects.
+type Writer struct {
+	BucketName string `json:"bucketName"`
+	WorkerID   int    `json:"workerID"`
+}
+
+// packedObject is the state of an object which is kept in the arrays of ObjectMeta.
+type packedObject struct {
+	size       int64
+	writeCount uint32
+	// etag is the MD5 digest of the ETag. It is zero if the ETag is unknown.
+	etag      [md5.Size]byte
+	etagParts uint16
+	// checksum is the decoded checksum. It is nil if the checksum is unknown.
+	checksum          []byte
+	checksumAlgorithm string
+	checksumParts     uint16
+	skipped           uint32
+	lastModified      uint32
+	// ownMetadata is true if the metadata was attached by the write of the current data by the writer.
+	ownMetadata bool
+}
+
+// SetWriter sets the worker which writes the objects to the bucket `bucketName`,
+// and dematerializes the objects which have no details other than the ones derived from the worker.
+// It must be called while no objects are in use.
+func (om *ObjectMeta) SetWriter(bucketName string, workerID int) {
+	om.writer = &Writer{
+		BucketName: bucketName,
+		WorkerID:   workerID,
+	}
+	for objID, obj := range om.objects {
+		if !om.pack(objID, obj) {
+			delete(om.objects, objID)
+		}
+	}
+}
+
+// ownMetadata returns the metadata attached by the write `writeCount` of the object with the key ID `keyID`
+// by the writer. It returns nil if the writer is unknown.
+func (om *ObjectMeta) ownMetadata(keyID string, writeCount int) *Metadata {
+	if om.writer == nil {
+		return nil
+	}
+	return &Metadata{
+		BucketName: om.writer.BucketName,
+		Key:        keyID,
+		WriteCount: writeCount,
+		WorkerID:   om.writer.WorkerID,
+	}
+}
+
+// ownSegments returns the segments of the object of `size` bytes with the key ID `keyID`
+// whose data was written by the write `writeCount` of the writer and whose later write counts were skipped.
+// It returns nil if the writer is unknown.
+func (om *ObjectMeta) ownSegments(keyID string, size, writeCount int) []Segment {
+	if om.writer == nil {
+		return nil
+	}
+	return []Segment{
+		{
+			Offset: 0,
+			Size:   size,
+			Source: DataSource{
+				BucketName: om.writer.BucketName,
+				Key:        keyID,
+				WriteCount: writeCount,
+			},
+		},
+	}
+}
+
+// packed returns the packed state of `obj` and whether it has the details which cannot be packed,
+// such as the segments copied from the other objects or the versions.
+func (om *ObjectMeta) packed(obj *Object) (packedObject, bool) {
+	p := packedObject{
+		size:       int64(obj.Size),
+		writeCount: uint32(obj.WriteCount),
+	}
+	details := len(obj.Versions) != 0 || obj.Uncertain || obj.Quarantined
+	dataWriteCount := obj.WriteCount
+	if len(obj.Segments) != 0 {
+		// The segments made by SkipWriteCount() are packed as the number of the skipped write counts.
+		src := obj.Segments[0].Source
+		if 0 < src.WriteCount && src.WriteCount < obj.WriteCount &&
+			slices.Equal(obj.Segments, om.ownSegments(obj.EmbeddedKey(), obj.Size, src.WriteCount)) {
+			p.skipped = uint32(obj.WriteCount - src.WriteCount)
+			dataWriteCount = src.WriteCount
+		} else {
+			details = true
+		}
+	}
+	if obj.ETag != "" {
+		etag, parts, ok := parseETag(obj.ETag)
+		if ok {
+			p.etag, p.etagParts = etag, parts
+		} else {
+			details = true
+		}
+	}
+	if obj.Checksum != nil {
+		checksum, parts, ok := parseChecksum(obj.Checksum.Value)
+		if ok && (om.checksumAlgorithm == "" ||
+			(obj.Checksum.Algorithm == om.checksumAlgorithm && len(checksum) == om.checksumSize())) {
+			p.checksum, p.checksumAlgorithm, p.checksumParts = checksum, obj.Checksum.Algorithm, parts
+		} else {
+			details = true
+		}
+	}
+	if 0 <= obj.LastModified && obj.LastModified <= math.MaxUint32 {
+		p.lastModified = uint32(obj.LastModified)
+	} else {
+		details = true
+	}
+	if obj.Metadata != nil {
+		if md := om.ownMetadata(obj.EmbeddedKey(), dataWriteCount); md != nil && *md == *obj.Metadata {
+			p.ownMetadata = true
+		} else {
+			details = true
+		}
+	}
+	return p, details
+}
+
+// checksumSize returns the size of the decoded checksums packed in the arrays.
+func (om *ObjectMeta) checksumSize() int {
+	if om.checksums == nil {
+		return 0
+	}
+	return len(om.checksums) / om.NumObj()
+}
+
+// packedAt returns the packed state of the `objID`-th object.
+func (om *ObjectMeta) packedAt(objID int64) packedObject {
+	p := packedObject{
+		size:        om.sizes[objID],
+		writeCount:  om.writeCounts[objID],
+		ownMetadata: om.metadata[objID/64]&(1<<(objID%64)) != 0,
+	}
+	if om.etags != nil {
+		p.etag = om.etags[objID]
+	}
+	if om.etagParts != nil {
+		p.etagParts = om.etagParts[objID]
+	}
+	if om.checksumParts != nil && om.checksumParts[objID] != 0 {
+		size := int64(om.checksumSize())
+		p.checksum = om.checksums[objID*size : (objID+1)*size]
+		p.checksumAlgorithm = om.checksumAlgorithm
+		p.checksumParts = om.checksumParts[objID]
+	}
+	if om.skipped != nil {
+		p.skipped = om.skipped[objID]
+	}
+	if om.lastModified != nil {
+		p.lastModified = om.lastModified[objID]
+	}
+	return p
+}
+
+// pack stores the state of `obj` into the arrays and returns true if it has the details.
+func (om *ObjectMeta) pack(objID int64, obj *Object) bool {
+	p, details := om.packed(obj)
+	om.store(objID, &p)
+	return details
+}
+
+// store stores `p` as the packed state of the `objID`-th object.
+// The arrays other than the sizes and the write counts are allocated on the first use.
+func (om *ObjectMeta) store(objID int64, p *packedObject) {
+	om.sizes[objID] = p.size
+	om.writeCounts[objID] = p.writeCount
+	if om.etags == nil && p.etag != [md5.Size]byte{} {
+		om.etags = make([][md5.Size]byte, om.NumObj())
+	}
+	if om.etags != nil {
+		om.etags[objID] = p.etag
+	}
+	if om.etagParts == nil && p.etagParts != 0 {
+		om.etagParts = make([]uint16, om.NumObj())
+	}
+	if om.etagParts != nil {
+		om.etagParts[objID] = p.etagParts
+	}
+	if om.checksums == nil && p.checksum != nil {
+		om.checksumAlgorithm = p.checksumAlgorithm
+		om.checksums = make([]byte, om.NumObj()*len(p.checksum))
+		om.checksumParts = make([]uint16, om.NumObj())
+	}
+	if om.checksums != nil {
+		size := int64(om.checksumSize())
+		copy(om.checksums[objID*size:(objID+1)*size], p.checksum)
+		om.checksumParts[objID] = p.checksumParts
+	}
+	if om.skipped == nil && p.skipped != 0 {
+		om.skipped = make([]uint32, om.NumObj())
+	}
+	if om.skipped != nil {
+		om.skipped[objID] = p.skipped
+	}
+	if om.lastModified == nil && p.lastModified != 0 {
+		om.lastModified = make([]uint32, om.NumObj())
+	}
+	if om.lastModified != nil {
+		om.lastModified[objID] = p.lastModified
+	}
+	if p.ownMetadata {
+		om.metadata[objID/64] |= 1 << (objID % 64)
+	} else {
+		om.metadata[objID/64] &^= 1 << (objID % 64)
+	}
+}
+
+// unpack sets the packed state `p` to `obj`.
+func (om *ObjectMeta) unpack(obj *Object, p *packedObject) {
+	obj.Size = int(p.size)
+	obj.WriteCount = int(p.writeCount)
+	dataWriteCount := int(p.writeCount - p.skipped)
+	if p.skipped != 0 {
+		obj.Segments = om.ownSegments(obj.EmbeddedKey(), obj.Size, dataWriteCount)
+	}
+	if p.etag != [md5.Size]byte{} {
+		obj.ETag = formatETag(p.etag, p.etagParts)
+	}
+	if p.checksum != nil {
+		obj.Checksum = &Checksum{
+			Algorithm: p.checksumAlgorithm,
+			Value:     formatChecksum(p.checksum, p.checksumParts),
+		}
+	}
+	obj.LastModified = int64(p.lastModified)
+	if p.ownMetadata {
+		obj.Metadata = om.ownMetadata(obj.EmbeddedKey(), dataWriteCount)
+	}
+}
+
+// parseETag returns the MD5 digest of `etag` and the number of the parts if it is the ETag of a multipart upload.
+// It returns false if the ETag cannot be restored from them.
+func parseETag(etag string) ([md5.Size]byte, uint16, bool) {
+	var digest [md5.Size]byte
+	if len(etag) < 2*md5.Size+2 || etag[0] != '"' || etag[len(etag)-1] != '"' {
+		return digest, 0, false
+	}
+	var parts uint16
+	if suffix := etag[2*md5.Size+1 : len(etag)-1]; suffix != "" {
+		n, err := strconv.ParseUint(strings.TrimPrefix(suffix, "-"), 10, 16)
+		if err != nil || n == 0 {
+			return digest, 0, false
+		}
+		parts = uint16(n)
+	}
+	_, err := hex.Decode(digest[:], []byte(etag[1:2*md5.Size+1]))
+	// The ETag must be restored as it is.
+	if err != nil || digest == [md5.Size]byte{} || formatETag(digest, parts) != etag {
+		return digest, 0, false
+	}
+	return digest, parts, true
+}
+
+// formatETag returns the ETag of the MD5 digest `digest`, which is of a multipart upload if `parts` is not zero.
+func formatETag(digest [md5.Size]byte, parts uint16) string {
+	if parts == 0 {
+		return fmt.Sprintf(`"%s"`, hex.EncodeToString(digest[:]))
+	}
+	return fmt.Sprintf(`"%s-%d"`, hex.EncodeToString(digest[:]), parts)
+}
+
+// parseChecksum returns the decoded checksum `value` and its packed number of the parts.
+// It returns false if the checksum cannot be restored from them.
+func parseChecksum(value string) ([]byte, uint16, bool) {
+	encoded, suffix, composite := strings.Cut(value, "-")
+	parts := uint16(1)
+	if composite {
+		n, err := strconv.ParseUint(suffix, 10, 16)
+		if err != nil || n == 0 || n == math.MaxUint16 {
+			return nil, 0, false
+		}
+		parts = uint16(n) + 1
+	}
+	checksum, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(checksum) == 0 || formatChecksum(checksum, parts) != value {
+		return nil, 0, false
+	}
+	return checksum, parts, true
+}
+
+// formatChecksum returns the base64-encoded checksum `checksum` with the packed number of the parts `parts`.
+func formatChecksum(checksum []byte, parts uint16) string {
+	encoded := base64.StdEncoding.EncodeToString(checksum)
+	if parts == 1 {
+		return encoded
+	}
+	return fmt.Sprintf("%s-%d", encoded, parts-1)
+}
@@ -0,0 +1,345 @@
+package pattern
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/peng225/oval/internal/object"
+)
+
+const (
+	dataUnitHeaderSize = object.MaxBucketNameLength + object.MaxKeyLength + dataUnitHeaderSizeWithoutBucketAndKey
+	unixTimeFieldPos   = object.MaxBucketNameLength + object.MaxKeyLength + 12
+)
+
+// AnalysisClass is the class of the bad data found by the analysis.
+type AnalysisClass string
+
+const (
+	// AnalysisValid means that the data is the expected one.
+	AnalysisValid AnalysisClass = "valid"
+	// AnalysisStaleGeneration means that the data was written by an older write of the object.
+	AnalysisStaleGeneration AnalysisClass = "stale generation"
+	// AnalysisNewerGeneration means that the data was written by a newer write of the object than expected.
+	// It happens when a write which was considered to be failed actually took effect.
+	AnalysisNewerGeneration AnalysisClass = "newer generation"
+	// AnalysisMisdirectedWrite means that the data was written for another key, bucket or worker.
+	AnalysisMisdirectedWrite AnalysisClass = "misdirected write"
+	// AnalysisShiftedData means that the data of the object is placed at the wrong offset.
+	AnalysisShiftedData AnalysisClass = "shifted data"
+	// AnalysisTornWrite means that the data mixes the data units of multiple writes of the object.
+	AnalysisTornWrite AnalysisClass = "torn write"
+	// AnalysisZeroFilled means that the data units are filled with zero.
+	AnalysisZeroFilled AnalysisClass = "zero-filled region"
+	// AnalysisTruncatedTail means that the data is shorter than expected.
+	AnalysisTruncatedTail AnalysisClass = "truncated tail"
+	// AnalysisExtraTail means that the data is longer than expected.
+	AnalysisExtraTail AnalysisClass = "extra tail"
+	// AnalysisRandomGarbage means that the data does not look like the data generated by oval.
+	AnalysisRandomGarbage AnalysisClass = "random garbage"
+	// AnalysisMixed means that the data has the bad regions of different classes.
+	AnalysisMixed AnalysisClass = "mixed"
+)
+
+// Region is a contiguous range of the bad data of the same class.
+type Region struct {
+	Class AnalysisClass `json:"class"`
+	// Start and End are the byte offsets of the range [Start, End) in the object.
+	Start int `json:"start"`
+	End   int `json:"end"`
+	// Header is the header of the first data unit in the region.
+	// It is nil if the data units do not have the valid headers.
+	Header *DataUnitHeader `json:"header,omitempty"`
+	// WrittenAt is the time when the data of the region was originally written.
+	WrittenAt time.Time `json:"writtenAt,omitzero"`
+	// Shift is the difference between the offset fields and the expected ones in the shifted data.
+	Shift int `json:"shift,omitempty"`
+}
+
+// Analysis is the result of the analysis of the whole data of an object.
+type Analysis struct {
+	Class        AnalysisClass `json:"class"`
+	Size         int           `json:"size"`
+	ExpectedSize int           `json:"expectedSize"`
+	NumUnits     int           `json:"numUnits"`
+	NumBadUnits  int           `json:"numBadUnits"`
+	// WrittenAt is the time when the valid data units were written.
+	// It is zero if there are no valid data units.
+	WrittenAt time.Time `json:"writtenAt,omitzero"`
+	Regions   []Region  `json:"regions"`
+}
+
+// generation identifies a write of a data source of the expected object.
+type generation struct {
+	bucketName string
+	key        string
+	writeCount int
+	unixMicro  int64
+}
+
+// Analyze scans the whole data read from `reader` and classifies how it differs from
+// the expected data of `obj` stored in the bucket `expectedBucketName` by the worker `workerID`.
+// It returns an error only if the data could not be read.
+func Analyze(workerID int, expectedBucketName string, obj *object.Object, reader io.Reader) (*Analysis, error) {
+	a := &Analysis{
+		ExpectedSize: obj.Size,
+		Regions:      make([]Region, 0),
+	}
+	generations := make(map[generation]struct{})
+	data := make([]byte, DataUnitSize)
+	for {
+		n, err := io.ReadFull(reader, data)
+		if err == io.EOF {
+			break
+		}
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, err
+		}
+		unitStart := a.Size
+		a.Size += n
+		a.NumUnits++
+		if unitStart >= obj.Size {
+			a.NumBadUnits++
+			a.addRegion(Region{Class: AnalysisExtraTail, Start: unitStart, End: a.Size})
+			continue
+		}
+		length := min(n, obj.Size-unitStart)
+		region, gen := analyzeDataUnit(workerID, expectedBucketName, obj, unitStart, data[:length])
+		if gen != nil {
+			generations[*gen] = struct{}{}
+		}
+		if region.Class == AnalysisValid {
+			if region.Header != nil && a.WrittenAt.IsZero() {
+				a.WrittenAt = region.WrittenAt
+			}
+		} else {
+			a.NumBadUnits++
+			a.addRegion(region)
+		}
+		if length < n {
+			a.addRegion(Region{Class: AnalysisExtraTail, Start: obj.Size, End: a.Size})
+		}
+		if n < DataUnitSize {
+			break
+		}
+	}
+	if a.Size < obj.Size {
+		a.addRegion(Region{Class: AnalysisTruncatedTail, Start: a.Size, End: obj.Size})
+	}
+	a.Class = a.classify(maxGenerations(generations))
+	return a, nil
+}
+
+// maxGenerations returns the maximum number of the writes of a single data source found in the data.
+func maxGenerations(generations map[generation]struct{}) int {
+	counts := make(map[[2]string]int)
+	n := 0
+	for gen := range generations {
+		source := [2]string{gen.bucketName, gen.key}
+		counts[source]++
+		n = max(n, counts[source])
+	}
+	return n
+}
+
+// analyzeDataUnit classifies `data`, which was read from the offset `unitStart` of the object.
+// It also returns the generation of the data unit if it was written by a write of the expected object.
+func analyzeDataUnit(workerID int, expectedBucketName string, obj *object.Object,
+	unitStart int, data []byte) (Region, *generation) {
+	region := Region{
+		Class: AnalysisValid,
+		Start: unitStart,
+		End:   unitStart + len(data),
+	}
+	expected, mask, expectedHeader := expectedDataUnit(workerID, expectedBucketName, obj, unitStart, len(data))
+	var header *DataUnitHeader
+	if len(data) >= dataUnitHeaderSize && validBody(data) {
+		header = ParseDataUnitHeader(data)
+		region.Header = header
+		region.WrittenAt = time.UnixMicro(header.UnixMicro)
+	}
+	sameObject := header != nil && expectedHeader != nil &&
+		header.BucketName == expectedHeader.BucketName && header.Key == expectedHeader.Key &&
+		header.WorkerID == expectedHeader.WorkerID
+	var gen *generation
+	if sameObject {
+		gen = &generation{
+			bucketName: header.BucketName,
+			key:        header.Key,
+			writeCount: header.WriteCount,
+			unixMicro:  header.UnixMicro,
+		}
+	}
+
+	matched := true
+	for i := range data {
+		if !mask[i] && data[i] != expected[i] {
+			matched = false
+			break
+		}
+	}
+	switch {
+	case matched:
+	case isZero(data):
+		region.Class = AnalysisZeroFilled
+		region.Header = nil
+	case header == nil:
+		region.Class = AnalysisRandomGarbage
+	case !sameObject:
+		region.Class = AnalysisMisdirectedWrite
+	case uint32(header.WriteCount) < uint32(expectedHeader.WriteCount):
+		region.Class = AnalysisStaleGeneration
+	case uint32(header.WriteCount) > uint32(expectedHeader.WriteCount):
+		region.Class = AnalysisNewerGeneration
+	case uint32(header.Offset) != uint32(expectedHeader.Offset):
+		region.Class = AnalysisShiftedData
+		region.Shift = int(int32(uint32(header.Offset) - uint32(expectedHeader.Offset)))
+	default:
+		region.Class = AnalysisRandomGarbage
+	}
+	if region.Class == AnalysisRandomGarbage {
+		region.Header = nil
+		region.WrittenAt = time.Time{}
+	}
+	return region, gen
+}
+
+// expectedDataUnit returns the expected data of the `length` bytes from the offset `unitStart` of the object,
+// the mask of the bytes of the unix time, which are unknown, and the expected header.
+// The header is nil if the range does not start at the head of a data unit of the data source.
+func expectedDataUnit(workerID int, expectedBucketName string, obj *object.Object,
+	unitStart, length int) ([]byte, []bool, *DataUnitHeader) {
+	expected := make([]byte, 0, length)
+	mask := make([]bool, 0, length)
+	var header *DataUnitHeader
+	for i, seg := range obj.DataSegments(expectedBucketName, unitStart, length) {
+		bucketName := seg.Source.BucketName
+		if len(bucketName) > object.MaxBucketNameLength {
+			bucketName = bucketName[:object.MaxBucketNameLength]
+		}
+		src := &object.Object{
+			Key:        seg.Source.Key,
+			WriteCount: seg.Source.WriteCount,
+		}
+		if i == 0 && seg.Offset%DataUnitSize == 0 && seg.Size >= dataUnitHeaderSize {
+			header = &DataUnitHeader{
+				BucketName: bucketName,
+				Key:        src.EmbeddedKey(),
+				WriteCount: src.WriteCount,
+				Offset:     seg.Offset,
+				WorkerID:   workerID,
+			}
+		}
+		for srcOffset := seg.Offset; srcOffset < seg.Offset+seg.Size; {
+			srcUnitStart := srcOffset - srcOffset%DataUnitSize
+			// The error is always nil.
+			dataUnit, _ := generateDataUnit(srcUnitStart/DataUnitSize, workerID, bucketName, src)
+			to := min(seg.Offset+seg.Size, srcUnitStart+DataUnitSize)
+			for pos := srcOffset - srcUnitStart; pos < to-srcUnitStart; pos++ {
+				expected = append(expected, dataUnit[pos])
+				mask = append(mask, unixTimeFieldPos <= pos && pos < dataUnitHeaderSize)
+			}
+			srcOffset = to
+		}
+	}
+	return expected, mask, header
+}
+
+// validBody returns true if the body of the data unit `data` has the generated pattern.
+func validBody(data []byte) bool {
+	for i := dataUnitHeaderSize; i < len(data); i++ {
+		if data[i] != byte(i) {
+			return false
+		}
+	}
+	return true
+}
+
+func isZero(data []byte) bool {
+	for _, b := range data {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// addRegion appends `r` to the regions, merging it with the last region if they are contiguous
+// and have the same class and origin.
+func (a *Analysis) addRegion(r Region) {
+	if len(a.Regions) != 0 {
+		last := &a.Regions[len(a.Regions)-1]
+		if last.End == r.Start && last.Class == r.Class && last.Shift == r.Shift && sameOrigin(last.Header, r.Header) {
+			last.End = r.End
+			return
+		}
+	}
+	a.Regions = append(a.Regions, r)
+}
+
+// sameOrigin returns true if the data units with `h1` and `h2` were written by the same write.
+func sameOrigin(h1, h2 *DataUnitHeader) bool {
+	if h1 == nil || h2 == nil {
+		return h1 == nil && h2 == nil
+	}
+	return h1.BucketName == h2.BucketName && h1.Key == h2.Key && h1.WorkerID == h2.WorkerID &&
+		h1.WriteCount == h2.WriteCount && h1.UnixMicro == h2.UnixMicro
+}
+
+// classify decides the class of the whole data from the regions.
+// `numGenerations` is the maximum number of the writes of a single data source found in the data.
+func (a *Analysis) classify(numGenerations int) AnalysisClass {
+	classes := make(map[AnalysisClass]struct{})
+	for _, r := range a.Regions {
+		classes[r.Class] = struct{}{}
+	}
+	generationOnly := true
+	for class := range classes {
+		if class != AnalysisStaleGeneration && class != AnalysisNewerGeneration {
+			generationOnly = false
+		}
+	}
+	switch {
+	case len(classes) == 0 && numGenerations <= 1:
+		return AnalysisValid
+	case generationOnly && numGenerations > 1:
+		return AnalysisTornWrite
+	case len(classes) == 1:
+		return a.Regions[0].Class
+	default:
+		return AnalysisMixed
+	}
+}
+
+// String returns the human-readable summary of the analysis.
+func (a *Analysis) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "The data is classified as %s. (size = %d, expected size = %d, bad data units = %d/%d)\n",
+		a.Class, a.Size, a.ExpectedSize, a.NumBadUnits, a.NumUnits)
+	if !a.WrittenAt.IsZero() {
+		fmt.Fprintf(&sb, "The valid data units were written at %s.\n", a.WrittenAt.UTC().Format(time.RFC3339Nano))
+	}
+	for _, r := range a.Regions {
+		fmt.Fprintf(&sb, "- [%d, %d) %s", r.Start, r.End, r.Class)
+		if r.Header != nil {
+			fmt.Fprintf(&sb, ": bucket = %s, key = %s, workerID = %#x, writeCount = %d, offset = %d",
+				r.Header.BucketName, r.Header.Key, r.Header.WorkerID, r.Header.WriteCount, r.Header.Offset)
+		}
+		if r.Shift != 0 {
+			fmt.Fprintf(&sb, ", shift = %d", r.Shift)
+		}
+		if !r.WrittenAt.IsZero() {
+			fmt.Fprintf(&sb, ", written at %s", r.WrittenAt.UTC().Format(time.RFC3339Nano))
+			if d := a.WrittenAt.Sub(r.WrittenAt); !a.WrittenAt.IsZero() && d >= 0 {
+				fmt.Fprintf(&sb, " (%s before the valid data units)", d)
+			} else if !a.WrittenAt.IsZero() {
+				fmt.Fprintf(&sb, " (%s after the valid data units)", -d)
+			}
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,149 @@
+package pattern
+
+import (
+	"bytes"
+	"math/rand"
+	"time"
+
+	"github.com/peng225/oval/internal/object"
+)
+
+func (suite *PatternSuite) generate(size, workerID int, bucketName, key string, writeCount int) []byte {
+	data, err := Generate(size, workerID, bucketName, &object.Object{Key: key, WriteCount: writeCount})
+	suite.Require().NoError(err)
+	return data
+}
+
+func (suite *PatternSuite) analyze(obj *object.Object, data []byte) *Analysis {
+	a, err := Analyze(1, testBucketName, obj, bytes.NewReader(data))
+	suite.Require().NoError(err)
+	return a
+}
+
+func (suite *PatternSuite) TestAnalyzeValid() {
+	obj := &object.Object{Key: testKeyName, Size: 4 * DataUnitSize, WriteCount: 3}
+	a := suite.analyze(obj, suite.generate(obj.Size, 1, testBucketName, testKeyName, 3))
+	suite.Equal(AnalysisValid, a.Class)
+	suite.Equal(4, a.NumUnits)
+	suite.Equal(0, a.NumBadUnits)
+	suite.Empty(a.Regions)
+	suite.False(a.WrittenAt.IsZero())
+
+	// The data copied from the other object.
+	obj = &object.Object{
+		Key:        testKeyName,
+		Size:       2 * DataUnitSize,
+		WriteCount: 1,
+		Segments: []object.Segment{
+			{Offset: DataUnitSize, Size: 2 * DataUnitSize, Source: object.DataSource{BucketName: testBucketName, Key: "src", WriteCount: 5}},
+		},
+	}
+	a = suite.analyze(obj, suite.generate(3*DataUnitSize, 1, testBucketName, "src", 5)[DataUnitSize:])
+	suite.Equal(AnalysisValid, a.Class)
+}
+
+func (suite *PatternSuite) TestAnalyzeStaleGeneration() {
+	obj := &object.Object{Key: testKeyName, Size: 4 * DataUnitSize, WriteCount: 3}
+	a := suite.analyze(obj, suite.generate(obj.Size, 1, testBucketName, testKeyName, 2))
+	suite.Equal(AnalysisStaleGeneration, a.Class)
+	suite.Equal(4, a.NumBadUnits)
+	suite.Require().Len(a.Regions, 1)
+	suite.Equal(0, a.Regions[0].Start)
+	suite.Equal(obj.Size, a.Regions[0].End)
+	suite.Equal(2, a.Regions[0].Header.WriteCount)
+	suite.False(a.Regions[0].WrittenAt.IsZero())
+	suite.Contains(a.String(), "stale generation")
+}
+
+func (suite *PatternSuite) TestAnalyzeTornWrite() {
+	obj := &object.Object{Key: testKeyName, Size: 4 * DataUnitSize, WriteCount: 3}
+	older := suite.generate(obj.Size, 1, testBucketName, testKeyName, 2)
+	time.Sleep(time.Millisecond)
+	data := suite.generate(obj.Size, 1, testBucketName, testKeyName, 3)
+	copy(data[2*DataUnitSize:], older[2*DataUnitSize:])
+	a := suite.analyze(obj, data)
+	suite.Equal(AnalysisTornWrite, a.Class)
+	suite.Equal(2, a.NumBadUnits)
+	suite.Require().Len(a.Regions, 1)
+	suite.Equal(AnalysisStaleGeneration, a.Regions[0].Class)
+	suite.Equal(2*DataUnitSize, a.Regions[0].Start)
+	suite.True(a.Regions[0].WrittenAt.Before(a.WrittenAt))
+	suite.Contains(a.String(), "before the valid data units")
+}
+
+func (suite *PatternSuite) TestAnalyzeMisdirectedWrite() {
+	obj := &object.Object{Key: testKeyName, Size: 2 * DataUnitSize, WriteCount: 3}
+	for _, data := range [][]byte{
+		suite.generate(obj.Size, 1, testBucketName, "other-key", 3),
+		suite.generate(obj.Size, 1, "other-bucket", testKeyName, 3),
+		suite.generate(obj.Size, 2, testBucketName, testKeyName, 3),
+	} {
+		a := suite.analyze(obj, data)
+		suite.Equal(AnalysisMisdirectedWrite, a.Class)
+		suite.Require().Len(a.Regions, 1)
+		suite.NotNil(a.Regions[0].Header)
+	}
+}
+
+func (suite *PatternSuite) TestAnalyzeShiftedData() {
+	obj := &object.Object{Key: testKeyName, Size: 2 * DataUnitSize, WriteCount: 3}
+	data := suite.generate(obj.Size+DataUnitSize, 1, testBucketName, testKeyName, 3)[DataUnitSize:]
+	a := suite.analyze(obj, data)
+	suite.Equal(AnalysisShiftedData, a.Class)
+	suite.Require().Len(a.Regions, 1)
+	suite.Equal(DataUnitSize, a.Regions[0].Shift)
+	suite.Equal(obj.Size, a.Regions[0].End)
+}
+
+func (suite *PatternSuite) TestAnalyzeZeroFilled() {
+	obj := &object.Object{Key: testKeyName, Size: 4 * DataUnitSize, WriteCount: 3}
+	data := suite.generate(obj.Size, 1, testBucketName, testKeyName, 3)
+	clear(data[DataUnitSize : 3*DataUnitSize])
+	a := suite.analyze(obj, data)
+	suite.Equal(AnalysisZeroFilled, a.Class)
+	suite.Require().Len(a.Regions, 1)
+	suite.Equal(DataUnitSize, a.Regions[0].Start)
+	suite.Equal(3*DataUnitSize, a.Regions[0].End)
+	suite.Nil(a.Regions[0].Header)
+}
+
+func (suite *PatternSuite) TestAnalyzeTruncatedAndExtraTail() {
+	obj := &object.Object{Key: testKeyName, Size: 4 * DataUnitSize, WriteCount: 3}
+	data := suite.generate(obj.Size, 1, testBucketName, testKeyName, 3)
+	a := suite.analyze(obj, data[:DataUnitSize+10])
+	suite.Equal(AnalysisTruncatedTail, a.Class)
+	suite.Equal(0, a.NumBadUnits)
+	suite.Require().Len(a.Regions, 1)
+	suite.Equal(DataUnitSize+10, a.Regions[0].Start)
+	suite.Equal(obj.Size, a.Regions[0].End)
+
+	obj.Size = 2 * DataUnitSize
+	a = suite.analyze(obj, data)
+	suite.Equal(AnalysisExtraTail, a.Class)
+	suite.Require().Len(a.Regions, 1)
+	suite.Equal(obj.Size, a.Regions[0].Start)
+	suite.Equal(len(data), a.Regions[0].End)
+}
+
+func (suite *PatternSuite) TestAnalyzeRandomGarbageAndMixed() {
+	obj := &object.Object{Key: testKeyName, Size: 4 * DataUnitSize, WriteCount: 3}
+	data := make([]byte, obj.Size)
+	rand.New(rand.NewSource(1)).Read(data)
+	a := suite.analyze(obj, data)
+	suite.Equal(AnalysisRandomGarbage, a.Class)
+	suite.Equal(4, a.NumBadUnits)
+	suite.Require().Len(a.Regions, 1)
+	suite.True(a.WrittenAt.IsZero())
+
+	// A flipped bit in the body is garbage as well.
+	data = suite.generate(obj.Size, 1, testBucketName, testKeyName, 3)
+	data[DataUnitSize-1] ^= 0x01
+	suite.Equal(AnalysisRandomGarbage, suite.analyze(obj, data).Class)
+
+	clear(data[2*DataUnitSize:])
+	a = suite.analyze(obj, data)
+	suite.Equal(AnalysisMixed, a.Class)
+	suite.Require().Len(a.Regions, 2)
+	suite.Equal(AnalysisRandomGarbage, a.Regions[0].Class)
+	suite.Equal(AnalysisZeroFilled, a.Regions[1].Class)
+}
@@ -0,0 +1,159 @@
+package pattern
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+var hexDumpLineRegexp = regexp.MustCompile(`^[0-9a-f]{8}  ([^|]*)`)
+
+// ParseHexDump reads the bytes from the hexdump like the one in the validation error messages.
+// The lines other than the hexdump lines, such as the annotations of the fields, are ignored.
+// The multiple hexdumps are concatenated in the order of appearance.
+func ParseHexDump(reader io.Reader) ([]byte, error) {
+	data := make([]byte, 0)
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		m := hexDumpLineRegexp.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+		for _, field := range strings.Fields(m[1]) {
+			b, err := hex.DecodeString(field)
+			if err != nil || len(b) != 1 {
+				return nil, fmt.Errorf("invalid hexdump line: %s", scanner.Text())
+			}
+			data = append(data, b[0])
+		}
+	}
+	err := scanner.Err()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, errors.New("no hexdump lines found")
+	}
+	return data, nil
+}
+
+// InspectedUnit is a data unit decoded by the inspection.
+type InspectedUnit struct {
+	// Position is the byte offset of the data unit in the inspected data.
+	Position int `json:"position"`
+	Size     int `json:"size"`
+	// Header is nil if the data unit is filled with zero or too short to have the header.
+	Header    *DataUnitHeader `json:"header,omitempty"`
+	WrittenAt time.Time       `json:"writtenAt,omitzero"`
+	ValidBody bool            `json:"validBody"`
+	// Discontinuities is the list of the fields which do not continue from the previous valid data unit.
+	// "zero" and "body" mean that the data unit is filled with zero and that the body is wrong respectively.
+	Discontinuities []string `json:"discontinuities,omitempty"`
+}
+
+// Inspect decodes the header of every data unit of the data read from `reader`
+// and finds the discontinuities between the data units.
+func Inspect(reader io.Reader) ([]InspectedUnit, error) {
+	units := make([]InspectedUnit, 0)
+	// last is the last data unit with the valid body.
+	var last *InspectedUnit
+	data := make([]byte, DataUnitSize)
+	for position := 0; ; position += DataUnitSize {
+		n, err := io.ReadFull(reader, data)
+		if err == io.EOF {
+			break
+		}
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, err
+		}
+		unit := InspectedUnit{
+			Position:        position,
+			Size:            n,
+			Discontinuities: make([]string, 0),
+		}
+		switch {
+		case isZero(data[:n]):
+			unit.Discontinuities = append(unit.Discontinuities, "zero")
+		case n < dataUnitHeaderSize:
+			unit.Discontinuities = append(unit.Discontinuities, "body")
+		default:
+			unit.Header = ParseDataUnitHeader(data[:n])
+			unit.WrittenAt = time.UnixMicro(unit.Header.UnixMicro)
+			unit.ValidBody = validBody(data[:n])
+			unit.Discontinuities = append(unit.Discontinuities, discontinuities(last, &unit)...)
+			if !unit.ValidBody {
+				unit.Discontinuities = append(unit.Discontinuities, "body")
+			}
+		}
+		units = append(units, unit)
+		if unit.ValidBody {
+			last = &unit
+		}
+		if n < DataUnitSize {
+			break
+		}
+	}
+	return units, nil
+}
+
+// discontinuities returns the fields of `unit` which do not continue from `last`.
+// The offset of the first data unit is expected to be its position.
+func discontinuities(last, unit *InspectedUnit) []string {
+	if last == nil {
+		if uint32(unit.Header.Offset) != uint32(unit.Position) {
+			return []string{"offset"}
+		}
+		return nil
+	}
+	fields := make([]string, 0)
+	if unit.Header.BucketName != last.Header.BucketName {
+		fields = append(fields, "bucketName")
+	}
+	if unit.Header.Key != last.Header.Key {
+		fields = append(fields, "key")
+	}
+	if unit.Header.WriteCount != last.Header.WriteCount {
+		fields = append(fields, "writeCount")
+	}
+	if uint32(unit.Header.Offset) != uint32(last.Header.Offset+unit.Position-last.Position) {
+		fields = append(fields, "offset")
+	}
+	if unit.Header.WorkerID != last.Header.WorkerID {
+		fields = append(fields, "workerID")
+	}
+	if unit.Header.UnixMicro != last.Header.UnixMicro {
+		fields = append(fields, "unixMicro")
+	}
+	return fields
+}
+
+// WriteUnitTable writes the inspected data units as a table to `out`.
+// The data units with the discontinuities are marked with "*".
+// If `onlyDiscontinuities` is true, the data units without the discontinuities are omitted.
+func WriteUnitTable(out io.Writer, units []InspectedUnit, onlyDiscontinuities bool) error {
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "\tPOSITION\tBUCKET\tKEY\tWRITE COUNT\tOFFSET\tWORKER ID\tWRITTEN AT\tDISCONTINUITIES")
+	for _, u := range units {
+		if onlyDiscontinuities && len(u.Discontinuities) == 0 {
+			continue
+		}
+		mark := ""
+		if len(u.Discontinuities) != 0 {
+			mark = "*"
+		}
+		if u.Header == nil {
+			fmt.Fprintf(tw, "%s\t%d\t-\t-\t-\t-\t-\t-\t%s\n", mark, u.Position, strings.Join(u.Discontinuities, ","))
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%q\t%q\t%d\t%d\t%#x\t%s\t%s\n", mark, u.Position,
+			u.Header.BucketName, u.Header.Key, u.Header.WriteCount, u.Header.Offset, u.Header.WorkerID,
+			u.WrittenAt.UTC().Format(time.RFC3339Nano), strings.Join(u.Discontinuities, ","))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,84 @@
+package pattern
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+
+	"github.com/peng225/oval/internal/object"
+)
+
+func (suite *PatternSuite) TestParseHexDump() {
+	data := suite.generate(2*DataUnitSize, 1, testBucketName, testKeyName, 3)
+	// The hexdumps with the annotations are concatenated.
+	text := "- Data body is wrong.\n" + dump(hex.Dump(data[:DataUnitSize])) + "\n" + dump(hex.Dump(data[DataUnitSize:]))
+	parsed, err := ParseHexDump(strings.NewReader(text))
+	suite.Require().NoError(err)
+	suite.Equal(data, parsed)
+
+	_, err = ParseHexDump(strings.NewReader("no hexdump\n"))
+	suite.Error(err)
+	_, err = ParseHexDump(strings.NewReader("00000000  zz 65  |.e|\n"))
+	suite.Error(err)
+}
+
+func (suite *PatternSuite) TestInspect() {
+	data := suite.generate(6*DataUnitSize, 1, testBucketName, testKeyName, 3)
+	older := suite.generate(6*DataUnitSize, 1, testBucketName, testKeyName, 2)
+	copy(data[2*DataUnitSize:3*DataUnitSize], older[2*DataUnitSize:])
+	clear(data[4*DataUnitSize : 5*DataUnitSize])
+	data[6*DataUnitSize-1] ^= 0xff
+	units, err := Inspect(bytes.NewReader(data[:6*DataUnitSize-10]))
+	suite.Require().NoError(err)
+	suite.Require().Len(units, 6)
+
+	suite.Empty(units[0].Discontinuities)
+	suite.Equal(testBucketName, units[0].Header.BucketName)
+	suite.Equal(testKeyName, units[0].Header.Key)
+	suite.Equal(3, units[0].Header.WriteCount)
+	suite.Equal(1, units[0].Header.WorkerID)
+	suite.False(units[0].WrittenAt.IsZero())
+	suite.Empty(units[1].Discontinuities)
+	suite.Equal([]string{"writeCount", "unixMicro"}, units[2].Discontinuities)
+	suite.Equal(2*DataUnitSize, units[2].Header.Offset)
+	suite.Equal([]string{"writeCount", "unixMicro"}, units[3].Discontinuities)
+	suite.Equal([]string{"zero"}, units[4].Discontinuities)
+	suite.Nil(units[4].Header)
+	// The truncated last data unit has the valid body.
+	suite.Equal(DataUnitSize-10, units[5].Size)
+	suite.True(units[5].ValidBody)
+	suite.Empty(units[5].Discontinuities)
+
+	// The data starting from the middle of an object.
+	units, err = Inspect(bytes.NewReader(data[DataUnitSize : 2*DataUnitSize]))
+	suite.Require().NoError(err)
+	suite.Equal([]string{"offset"}, units[0].Discontinuities)
+
+	out := &bytes.Buffer{}
+	suite.Require().NoError(WriteUnitTable(out, units, false))
+	suite.Contains(out.String(), "DISCONTINUITIES")
+	suite.Contains(out.String(), `"test-bucket"`)
+
+	other := suite.generate(DataUnitSize, 2, "other-bucket", "other-key", 3)
+	units, err = Inspect(bytes.NewReader(append(data[:DataUnitSize:DataUnitSize], other...)))
+	suite.Require().NoError(err)
+	suite.Equal([]string{"bucketName", "key", "offset", "workerID", "unixMicro"}, units[1].Discontinuities)
+	out.Reset()
+	suite.Require().NoError(WriteUnitTable(out, units, true))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	suite.Require().Len(lines, 2)
+	suite.True(strings.HasPrefix(lines[1], "*"))
+}
+
+func (suite *PatternSuite) TestInspectGarbage() {
+	obj := &object.Object{Key: testKeyName, WriteCount: 1}
+	data := suite.generate(DataUnitSize, 1, testBucketName, obj.Key, obj.WriteCount)
+	data[DataUnitSize-1] ^= 0xff
+	units, err := Inspect(bytes.NewReader(append(data, 1, 2, 3)))
+	suite.Require().NoError(err)
+	suite.Require().Len(units, 2)
+	suite.False(units[0].ValidBody)
+	suite.Equal([]string{"body"}, units[0].Discontinuities)
+	suite.Nil(units[1].Header)
+	suite.Equal([]string{"body"}, units[1].Discontinuities)
+}
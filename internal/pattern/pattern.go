@@ -8,6 +8,7 @@ import (
 	"io"
 	"math"
 	"math/rand"
+	"strconv"
 	"strings"
 	"time"
 
@@ -15,19 +16,19 @@ import (
 )
 
 const (
-	dataUnitSize                          = 256
+	DataUnitSize                          = 256
 	dataUnitHeaderSizeWithoutBucketAndKey = 20
 )
 
 func DecideSize(minSize, maxSize int) (int, error) {
-	if minSize < dataUnitSize {
-		return 0, fmt.Errorf("minSize should be larger than or equal to %v", dataUnitSize)
+	if minSize < DataUnitSize {
+		return 0, fmt.Errorf("minSize should be larger than or equal to %v", DataUnitSize)
 	}
-	if minSize%dataUnitSize != 0 {
-		return 0, fmt.Errorf("minSize should be a multiple of %v", dataUnitSize)
+	if minSize%DataUnitSize != 0 {
+		return 0, fmt.Errorf("minSize should be a multiple of %v", DataUnitSize)
 	}
-	if maxSize%dataUnitSize != 0 {
-		return 0, fmt.Errorf("maxSize should be a multiple of %v", dataUnitSize)
+	if maxSize%DataUnitSize != 0 {
+		return 0, fmt.Errorf("maxSize should be a multiple of %v", DataUnitSize)
 	}
 	if maxSize < minSize {
 		return 0, errors.New("maxSize should be larger than or equal to minSize")
@@ -67,35 +68,104 @@ func DecideSize(minSize, maxSize int) (int, error) {
 		x is in [0, 1) whose density function is f_X(x).
 		We should transform it to the integer value in [minSize, maxSize].
 	*/
-	return minSize + dataUnitSize*int(float64((maxSize-minSize)/dataUnitSize+1)*x), nil
+	return minSize + DataUnitSize*int(float64((maxSize-minSize)/DataUnitSize+1)*x), nil
 }
 
 func Generate(dataSize, workerID int, bucketName string, obj *object.Object) ([]byte, error) {
+	reader, err := NewReader(dataSize, workerID, bucketName, obj)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, dataSize)
+	n, err := io.ReadFull(reader, data)
+	if err != nil {
+		return nil, fmt.Errorf("generated data size is wrong. (expected: %v, actual: %v)", dataSize, n)
+	}
+	return data, nil
+}
+
+// Reader generates the data of an object lazily.
+// The same data is returned every time the same offset is read,
+// so that it can be used as a body of requests which may be signed or retried.
+type Reader struct {
+	size   int64
+	offset int64
+	// template is the first data unit, from which all data units are derived
+	// by rewriting the offset field.
+	template []byte
+}
+
+var _ io.ReadSeeker = (*Reader)(nil)
+var _ io.ReaderAt = (*Reader)(nil)
+
+// NewReader returns a reader which generates the data of the size `dataSize`.
+// All data units share the same unix time, which is the time when this function is called.
+func NewReader(dataSize, workerID int, bucketName string, obj *object.Object) (*Reader, error) {
 	if len(bucketName) > object.MaxBucketNameLength {
 		bucketName = bucketName[:object.MaxBucketNameLength]
 	}
-
-	data := make([]byte, 0, dataSize)
-	for i := 0; i < dataSize/dataUnitSize; i++ {
-		dataUnit, err := generateDataUnit(i, workerID, bucketName, obj)
-		if err != nil {
-			return nil, err
-		}
-		data = append(data, dataUnit...)
+	template, err := generateDataUnit(0, workerID, bucketName, obj)
+	if err != nil {
+		return nil, err
 	}
+	return &Reader{
+		size:     int64(dataSize),
+		template: template,
+	}, nil
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	n, err := r.ReadAt(p, r.offset)
+	r.offset += int64(n)
+	return n, err
+}
 
-	if len(data) != dataSize {
-		return nil, fmt.Errorf("generated data size is wrong. (expected: %v, actual: %v)", dataSize, len(data))
+// ReadAt does not modify the state of the reader,
+// thus it can be called concurrently.
+func (r *Reader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("pattern.Reader.ReadAt: negative offset")
 	}
+	dataUnit := make([]byte, DataUnitSize)
+	n := 0
+	for n < len(p) && off < r.size {
+		unitStart := off - off%DataUnitSize
+		copy(dataUnit, r.template)
+		offsetFieldPos := object.MaxBucketNameLength + object.MaxKeyLength + 4
+		binary.LittleEndian.PutUint32(dataUnit[offsetFieldPos:offsetFieldPos+4], uint32(unitStart))
+		limit := min(int64(len(p)-n), r.size-off)
+		m := copy(p[n:int64(n)+limit], dataUnit[off-unitStart:])
+		n += m
+		off += int64(m)
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
 
-	return data, nil
+func (r *Reader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, errors.New("pattern.Reader.Seek: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("pattern.Reader.Seek: negative position")
+	}
+	r.offset = offset
+	return offset, nil
 }
 
 func generateDataUnit(unitCount, workerID int, bucketName string, obj *object.Object) ([]byte, error) {
 	bucketKeyformat := fmt.Sprintf("%%-%vs%%-%vs", object.MaxBucketNameLength, object.MaxKeyLength)
-	offsetInObject := unitCount * dataUnitSize
-	dataUnit := make([]byte, 0, dataUnitSize)
-	dataUnit = append(dataUnit, []byte(fmt.Sprintf(bucketKeyformat, bucketName, obj.Key))...)
+	offsetInObject := unitCount * DataUnitSize
+	dataUnit := make([]byte, 0, DataUnitSize)
+	dataUnit = append(dataUnit, []byte(fmt.Sprintf(bucketKeyformat, bucketName, obj.EmbeddedKey()))...)
 
 	numBinBuf := make([]byte, dataUnitHeaderSizeWithoutBucketAndKey)
 	binary.LittleEndian.PutUint32(numBinBuf[0:4], uint32(obj.WriteCount))
@@ -107,7 +177,7 @@ func generateDataUnit(unitCount, workerID int, bucketName string, obj *object.Ob
 	dataUnit = append(dataUnit, numBinBuf...)
 
 	unitBodyStartPos := object.MaxBucketNameLength + object.MaxKeyLength + dataUnitHeaderSizeWithoutBucketAndKey
-	for i := unitBodyStartPos; i < dataUnitSize; i += 4 {
+	for i := unitBodyStartPos; i < DataUnitSize; i += 4 {
 		dataUnit = append(dataUnit,
 			byte(i), byte(i+1), byte(i+2), byte(i+3),
 		)
@@ -116,16 +186,50 @@ func generateDataUnit(unitCount, workerID int, bucketName string, obj *object.Ob
 }
 
 func Valid(workerID int, expectedBucketName string, obj *object.Object, reader io.Reader) error {
+	return ValidRange(workerID, expectedBucketName, obj, reader, 0, obj.Size)
+}
+
+// ValidRange validates the `length` bytes read from `reader`
+// as the data starting at the byte offset `start` of the object.
+// `start` and `length` do not have to be aligned to the data unit size.
+func ValidRange(workerID int, expectedBucketName string, obj *object.Object, reader io.Reader, start, length int) error {
+	for _, seg := range obj.DataSegments(expectedBucketName, start, length) {
+		err := validSegment(workerID, &seg, reader)
+		if err != nil {
+			if len(obj.Segments) != 0 {
+				err = fmt.Errorf("- The data was expected to be copied from the offset %d of the object. (bucket = \"%s\", key = \"%s\", writeCount = %d)\n%w",
+					seg.Offset, seg.Source.BucketName, seg.Source.Key, seg.Source.WriteCount, err)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// validSegment validates the data read from `reader` as the data of the segment `seg`.
+func validSegment(workerID int, seg *object.Segment, reader io.Reader) error {
+	expectedBucketName := seg.Source.BucketName
 	if len(expectedBucketName) > object.MaxBucketNameLength {
 		expectedBucketName = expectedBucketName[:object.MaxBucketNameLength]
 	}
-	data := make([]byte, dataUnitSize)
-	for i := 0; i < obj.Size/dataUnitSize; i++ {
-		n, err := io.ReadFull(reader, data)
+	obj := &object.Object{
+		Key:        seg.Source.Key,
+		WriteCount: seg.Source.WriteCount,
+	}
+	data := make([]byte, DataUnitSize)
+	end := seg.Offset + seg.Size
+	for unitStart := seg.Offset - seg.Offset%DataUnitSize; unitStart < end; unitStart += DataUnitSize {
+		from := max(seg.Offset, unitStart) - unitStart
+		to := min(end, unitStart+DataUnitSize) - unitStart
+		n, err := io.ReadFull(reader, data[from:to])
 		if err != nil {
-			return fmt.Errorf("could not read some data. (expected: %vbyte, actual: %vbyte)\n%v", dataUnitSize, n, dump(hex.Dump(data[0:n])))
+			return fmt.Errorf("could not read some data. (expected: %vbyte, actual: %vbyte)\n%v", to-from, n, dump(hex.Dump(data[from:from+n])))
+		}
+		if from == 0 && to == DataUnitSize {
+			err = validDataUnit(unitStart/DataUnitSize, workerID, expectedBucketName, obj, data)
+		} else {
+			err = validPartialDataUnit(unitStart/DataUnitSize, workerID, expectedBucketName, obj, data, from, to)
 		}
-		err = validDataUnit(i, workerID, expectedBucketName, obj, data)
 		if err != nil {
 			return err
 		}
@@ -133,52 +237,212 @@ func Valid(workerID int, expectedBucketName string, obj *object.Object, reader i
 	return nil
 }
 
+// Generation identifies the write which generated the data of an object.
+type Generation struct {
+	WorkerID   int
+	WriteCount int
+	// UnixMicro is the time when the data was generated.
+	UnixMicro int64
+	Size      int
+}
+
+// ValidGeneration validates that the whole data read from `reader` was generated by a single write,
+// whichever worker did it, and returns the generation of the data.
+// It is used when the objects are written by multiple workers and thus the expected writer is unknown.
+// The data mixing the data units of multiple writes is detected as a torn object.
+func ValidGeneration(expectedBucketName, key string, reader io.Reader) (*Generation, error) {
+	if len(expectedBucketName) > object.MaxBucketNameLength {
+		expectedBucketName = expectedBucketName[:object.MaxBucketNameLength]
+	}
+	var gen *Generation
+	obj := &object.Object{
+		Key: key,
+	}
+	data := make([]byte, DataUnitSize)
+	for unitCount := 0; ; unitCount++ {
+		n, err := io.ReadFull(reader, data)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not read some data. (expected: %vbyte, actual: %vbyte)\n%v", DataUnitSize, n, dump(hex.Dump(data[:n])))
+		}
+		if gen == nil {
+			gen = parseGeneration(data)
+			obj.WriteCount = gen.WriteCount
+		}
+		err = validDataUnit(unitCount, gen.WorkerID, expectedBucketName, obj, data)
+		if unixMicro := parseGeneration(data).UnixMicro; err == nil && unixMicro != gen.UnixMicro {
+			err = fmt.Errorf("- Unix time is wrong. (expected = \"%d\", actual = \"%d\")\n%s",
+				gen.UnixMicro, unixMicro, dump(hex.Dump(data)))
+		}
+		if err != nil {
+			if unitCount != 0 {
+				err = fmt.Errorf("- The data unit at the offset %d was not written by the write of the first data unit. (workerID = %#x, writeCount = %d)\n%w",
+					unitCount*DataUnitSize, gen.WorkerID, gen.WriteCount, err)
+			}
+			return nil, err
+		}
+		gen.Size += DataUnitSize
+	}
+	if gen == nil {
+		return nil, errors.New("the object is empty")
+	}
+	return gen, nil
+}
+
+// parseGeneration returns the generation embedded in the data unit.
+// The size of the returned generation is 0.
+func parseGeneration(data []byte) *Generation {
+	header := ParseDataUnitHeader(data)
+	return &Generation{
+		WriteCount: header.WriteCount,
+		WorkerID:   header.WorkerID,
+		UnixMicro:  header.UnixMicro,
+	}
+}
+
+// DataUnitHeader is the header embedded at the head of each data unit.
+type DataUnitHeader struct {
+	BucketName string `json:"bucketName"`
+	// Key is the key ID of the object.
+	Key        string `json:"key"`
+	WriteCount int    `json:"writeCount"`
+	// Offset is the byte offset of the data unit in the object.
+	Offset    int   `json:"offset"`
+	WorkerID  int   `json:"workerID"`
+	UnixMicro int64 `json:"unixMicro,omitempty"`
+}
+
+// ParseDataUnitHeader decodes the header of the data unit `data`.
+// The bucket name and the key are trimmed of the padding.
+func ParseDataUnitHeader(data []byte) *DataUnitHeader {
+	current := object.MaxBucketNameLength + object.MaxKeyLength
+	return &DataUnitHeader{
+		BucketName: strings.TrimSpace(string(data[0:object.MaxBucketNameLength])),
+		Key:        strings.TrimSpace(string(data[object.MaxBucketNameLength:current])),
+		WriteCount: int(binary.LittleEndian.Uint32(data[current : current+4])),
+		Offset:     int(binary.LittleEndian.Uint32(data[current+4 : current+8])),
+		WorkerID:   int(binary.LittleEndian.Uint32(data[current+8 : current+12])),
+		UnixMicro:  int64(binary.LittleEndian.Uint64(data[current+12 : current+20])),
+	}
+}
+
+// validPartialDataUnit validates data[from:to] as a part of the `unitCount`-th data unit.
+func validPartialDataUnit(unitCount, workerID int, expectedBucketName string, obj *object.Object, data []byte, from, to int) error {
+	dataUnit, err := generateDataUnit(unitCount, workerID, expectedBucketName, obj)
+	if err != nil {
+		return err
+	}
+	// Overlay the actual bytes on the expected data unit
+	// so that the fields out of the range always match.
+	copy(dataUnit[from:to], data[from:to])
+	err = validDataUnit(unitCount, workerID, expectedBucketName, obj, dataUnit)
+	if err != nil {
+		return fmt.Errorf("- Only the bytes in [%d, %d) of the data unit were read. The others are filled with the expected values.\n%w",
+			from, to, err)
+	}
+	return nil
+}
+
 func validDataUnit(unitCount, workerID int, expectedBucketName string, obj *object.Object, data []byte) error {
-	bucketName := data[0:object.MaxBucketNameLength]
-	current := object.MaxBucketNameLength
+	actual := ParseDataUnitHeader(data)
 	errMsg := ""
-	if expectedBucketName != strings.TrimSpace(string(bucketName)) {
+	mismatches := make([]FieldMismatch, 0)
+	if expectedBucketName != actual.BucketName {
 		errMsg += fmt.Sprintf("- Bucket name is wrong. (expected = \"%s\", actual = \"%s\")\n",
-			expectedBucketName, strings.TrimSpace(string(bucketName)))
+			expectedBucketName, actual.BucketName)
+		mismatches = append(mismatches, FieldMismatch{Field: "bucketName", Expected: expectedBucketName, Actual: actual.BucketName})
 	}
 
-	key := data[current : current+object.MaxKeyLength]
-	current = current + object.MaxKeyLength
-	if obj.Key != strings.TrimSpace(string(key)) {
+	if obj.EmbeddedKey() != actual.Key {
 		errMsg += fmt.Sprintf("- Key name is wrong. (expected = \"%s\", actual = \"%s\")\n",
-			obj.Key, strings.TrimSpace(string(key)))
+			obj.EmbeddedKey(), actual.Key)
+		mismatches = append(mismatches, FieldMismatch{Field: "key", Expected: obj.EmbeddedKey(), Actual: actual.Key})
 	}
 
-	writeCount := binary.LittleEndian.Uint32(data[current : current+4])
-	current += 4
-	if uint32(obj.WriteCount) != writeCount {
+	if uint32(obj.WriteCount) != uint32(actual.WriteCount) {
 		errMsg += fmt.Sprintf("- WriteCount is wrong. (expected = \"%d\", actual = \"%d\")\n",
-			obj.WriteCount, writeCount)
+			obj.WriteCount, actual.WriteCount)
+		mismatches = append(mismatches, FieldMismatch{Field: "writeCount",
+			Expected: strconv.Itoa(obj.WriteCount), Actual: strconv.Itoa(actual.WriteCount)})
 	}
 
-	offsetInObject := binary.LittleEndian.Uint32(data[current : current+4])
-	current += 4
-	if uint32(unitCount*dataUnitSize) != offsetInObject {
+	// The offset field is 32-bit, thus it wraps around in the objects larger than 4GiB.
+	if uint32(unitCount*DataUnitSize) != uint32(actual.Offset) {
 		errMsg += fmt.Sprintf("- OffsetInObject is wrong. (expected = \"%d\", actual = \"%d\")\n",
-			unitCount*dataUnitSize, offsetInObject)
+			unitCount*DataUnitSize, actual.Offset)
+		mismatches = append(mismatches, FieldMismatch{Field: "offset",
+			Expected: strconv.Itoa(unitCount * DataUnitSize), Actual: strconv.Itoa(actual.Offset)})
 	}
 
-	actualWorkerID := int(binary.LittleEndian.Uint32(data[current : current+4]))
-	if workerID != actualWorkerID {
+	if workerID != actual.WorkerID {
 		errMsg += fmt.Sprintf("- WorkerID is wrong. (expected = \"%d\", actual = \"%d\")\n",
-			workerID, actualWorkerID)
+			workerID, actual.WorkerID)
+		mismatches = append(mismatches, FieldMismatch{Field: "workerID",
+			Expected: strconv.Itoa(workerID), Actual: strconv.Itoa(actual.WorkerID)})
 	}
 
 	// Skip the unix time area.
 
+	unitBodyStartPos := object.MaxBucketNameLength + object.MaxKeyLength + dataUnitHeaderSizeWithoutBucketAndKey
+	for i := unitBodyStartPos; i < DataUnitSize; i++ {
+		if data[i] != byte(i) {
+			errMsg += fmt.Sprintf("- Data body is wrong. (offset in the data unit = %d, expected = 0x%02x, actual = 0x%02x)\n",
+				i, byte(i), data[i])
+			mismatches = append(mismatches, FieldMismatch{Field: "body", Position: i,
+				Expected: fmt.Sprintf("0x%02x", byte(i)), Actual: fmt.Sprintf("0x%02x", data[i])})
+			break
+		}
+	}
+
 	if errMsg != "" {
-		errMsg += dump(hex.Dump(data))
-		return errors.New(errMsg)
+		return &DataUnitError{
+			Offset:     unitCount * DataUnitSize,
+			Mismatches: mismatches,
+			Expected: &DataUnitHeader{
+				BucketName: expectedBucketName,
+				Key:        obj.EmbeddedKey(),
+				WriteCount: obj.WriteCount,
+				Offset:     unitCount * DataUnitSize,
+				WorkerID:   workerID,
+			},
+			Actual: actual,
+			Dump:   dump(hex.Dump(data)),
+			msg:    errMsg,
+		}
 	}
 
 	return nil
 }
 
+// FieldMismatch is a field of a data unit which does not have the expected value.
+type FieldMismatch struct {
+	Field    string `json:"field"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+	// Position is the byte position in the data unit of the first wrong byte of the body.
+	Position int `json:"position,omitempty"`
+}
+
+// DataUnitError is the error of a data unit which does not have the expected contents.
+type DataUnitError struct {
+	// Offset is the byte offset of the data unit in the object.
+	Offset     int
+	Mismatches []FieldMismatch
+	// Expected is the expected header except the unix time, which is unknown.
+	Expected *DataUnitHeader
+	// Actual is the header decoded from the data unit.
+	Actual *DataUnitHeader
+	// Dump is the hexdump of the data unit annotated with the fields.
+	Dump string
+	msg  string
+}
+
+func (e *DataUnitError) Error() string {
+	return e.msg + e.Dump
+}
+
 func dump(data string) string {
 	if len(data) == 0 {
 		return ""
@@ -2,6 +2,7 @@ package pattern
 
 import (
 	"bytes"
+	"io"
 	"testing"
 
 	"github.com/peng225/oval/internal/object"
@@ -37,7 +38,7 @@ func (suite *PatternSuite) TestGenerateDataUnitSuccess() {
 
 	data, err := generateDataUnit(4, workerID, testBucketName, obj)
 	suite.NoError(err)
-	suite.Equal(dataUnitSize, len(data))
+	suite.Equal(DataUnitSize, len(data))
 
 	// bucketName
 	suite.Equal(append([]byte(testBucketName), 0x20, 0x20, 0x20, 0x20, 0x20),
@@ -87,7 +88,7 @@ func (suite *PatternSuite) TestGenerateSuccess() {
 	suite.Equal([]byte{0x64, 0x00, 0x00, 0x00}, data[current:current+4]) // hex(100) = 0x64
 
 	// 2nd data unit
-	current = dataUnitSize
+	current = DataUnitSize
 	// bucketName
 	suite.Equal(append([]byte(testBucketName), 0x20, 0x20, 0x20, 0x20, 0x20),
 		data[current:current+object.MaxBucketNameLength])
@@ -136,7 +137,7 @@ func (suite *PatternSuite) TestGenerateLongBucketName() {
 	suite.Equal([]byte{0x64, 0x00, 0x00, 0x00}, data[current:current+4]) // hex(100) = 0x64
 
 	// 2nd data unit
-	current = dataUnitSize
+	current = DataUnitSize
 	// bucketName
 	suite.Equal([]byte(testLongBucketName[:object.MaxBucketNameLength]),
 		data[current:current+object.MaxBucketNameLength])
@@ -199,6 +200,314 @@ func (suite *PatternSuite) TestValidLongBucketName() {
 	suite.NoError(err)
 }
 
+func (suite *PatternSuite) TestValidCopiedObject() {
+	srcObj := &object.Object{
+		Key:        testKeyName,
+		WriteCount: 300,
+	}
+	workerID := 100
+
+	size := 1024
+	data, err := Generate(size, workerID, testBucketName, srcObj)
+	suite.NoError(err)
+	srcObj.Size = size
+
+	dstObj := &object.Object{
+		Key:        "test-key2",
+		Size:       size,
+		WriteCount: 2,
+		Segments:   srcObj.DataSegments(testBucketName, 0, size),
+	}
+	suite.NoError(Valid(workerID, testLongBucketName, dstObj, bytes.NewReader(data)))
+	suite.NoError(ValidRange(workerID, testLongBucketName, dstObj, bytes.NewReader(data[10:300]), 10, 290))
+
+	// The data of the copied object should not be accepted without the provenance.
+	dstObj.Segments = nil
+	suite.Error(Valid(workerID, testLongBucketName, dstObj, bytes.NewReader(data)))
+}
+
+func (suite *PatternSuite) TestReaderReadAt() {
+	obj := &object.Object{
+		Key:        testKeyName,
+		WriteCount: 300,
+	}
+	workerID := 100
+
+	size := 1024
+	data, err := Generate(size, workerID, testBucketName, obj)
+	suite.NoError(err)
+	obj.Size = size
+
+	reader, err := NewReader(size, workerID, testBucketName, obj)
+	suite.NoError(err)
+	partialData := make([]byte, 700)
+	n, err := reader.ReadAt(partialData, 100)
+	suite.NoError(err)
+	suite.Equal(700, n)
+	suite.NoError(ValidRange(workerID, testBucketName, obj, bytes.NewReader(partialData), 100, 700))
+	// Compare the data body area, which does not include the unix time.
+	suite.Equal(data[600:700], partialData[500:600])
+}
+
+func (suite *PatternSuite) TestReader() {
+	obj := &object.Object{
+		Key:        testKeyName,
+		WriteCount: 300,
+	}
+	workerID := 100
+
+	size := 1024
+	reader, err := NewReader(size, workerID, testBucketName, obj)
+	suite.NoError(err)
+	obj.Size = size
+
+	data, err := io.ReadAll(reader)
+	suite.NoError(err)
+	suite.Len(data, size)
+	suite.NoError(Valid(workerID, testBucketName, obj, bytes.NewReader(data)))
+
+	// The same data should be returned after seeking.
+	pos, err := reader.Seek(-300, io.SeekEnd)
+	suite.NoError(err)
+	suite.Equal(int64(size-300), pos)
+	tail, err := io.ReadAll(reader)
+	suite.NoError(err)
+	suite.Equal(data[size-300:], tail)
+
+	pos, err = reader.Seek(10, io.SeekStart)
+	suite.NoError(err)
+	suite.Equal(int64(10), pos)
+	pos, err = reader.Seek(20, io.SeekCurrent)
+	suite.NoError(err)
+	suite.Equal(int64(30), pos)
+	buf := make([]byte, 500)
+	_, err = io.ReadFull(reader, buf)
+	suite.NoError(err)
+	suite.Equal(data[30:530], buf)
+
+	_, err = reader.Seek(-1, io.SeekStart)
+	suite.Error(err)
+
+	// Reading beyond the end
+	n, err := reader.ReadAt(buf, int64(size-100))
+	suite.Equal(100, n)
+	suite.ErrorIs(err, io.EOF)
+}
+
+func (suite *PatternSuite) TestValidComposedObject() {
+	workerID := 100
+	srcObj1 := &object.Object{
+		Key:        "test-key1",
+		Size:       1024,
+		WriteCount: 3,
+	}
+	srcData1, err := Generate(srcObj1.Size, workerID, testBucketName, srcObj1)
+	suite.NoError(err)
+	srcObj2 := &object.Object{
+		Key:        "test-key2",
+		Size:       2048,
+		WriteCount: 5,
+	}
+	srcData2, err := Generate(srcObj2.Size, workerID, testLongBucketName, srcObj2)
+	suite.NoError(err)
+
+	dstObj := &object.Object{
+		Key:        "test-key3",
+		Size:       1500,
+		WriteCount: 1,
+	}
+	// [0, 300): srcObj1[10, 310)
+	// [300, 500): fresh data
+	// [500, 1500): srcObj2[777, 1777)
+	dstReader, err := NewReader(dstObj.Size, workerID, testBucketName, dstObj)
+	suite.NoError(err)
+	freshData := make([]byte, 200)
+	_, err = dstReader.ReadAt(freshData, 300)
+	suite.NoError(err)
+	dstData := append([]byte{}, srcData1[10:310]...)
+	dstData = append(dstData, freshData...)
+	dstData = append(dstData, srcData2[777:1777]...)
+	dstObj.Segments = append(srcObj1.DataSegments(testBucketName, 10, 300),
+		dstObj.DataSegments(testBucketName, 300, 200)...)
+	dstObj.Segments = append(dstObj.Segments, srcObj2.DataSegments(testLongBucketName, 777, 1000)...)
+
+	suite.NoError(Valid(workerID, testBucketName, dstObj, bytes.NewReader(dstData)))
+	suite.NoError(ValidRange(workerID, testBucketName, dstObj, bytes.NewReader(dstData[250:600]), 250, 350))
+
+	// Copying a composed object keeps the original data sources.
+	copiedObj := &object.Object{
+		Key:        "test-key4",
+		Size:       dstObj.Size,
+		WriteCount: 1,
+		Segments:   dstObj.DataSegments(testBucketName, 0, dstObj.Size),
+	}
+	suite.NoError(Valid(workerID, testLongBucketName, copiedObj, bytes.NewReader(dstData)))
+
+	// Swap the data of the first and the last data units.
+	corruptedData := append([]byte{}, dstData...)
+	copy(corruptedData[0:DataUnitSize], dstData[len(dstData)-DataUnitSize:])
+	suite.Error(Valid(workerID, testBucketName, dstObj, bytes.NewReader(corruptedData)))
+}
+
+func (suite *PatternSuite) TestValidDataUnitBodyCorrupted() {
+	obj := &object.Object{
+		Key:        testKeyName,
+		Size:       256,
+		WriteCount: 300,
+	}
+	workerID := 100
+
+	data, err := generateDataUnit(4, workerID, testBucketName, obj)
+	suite.NoError(err)
+	data[DataUnitSize-1] ^= 0xff
+	err = validDataUnit(4, workerID, testBucketName, obj, data)
+	var duErr *DataUnitError
+	suite.ErrorAs(err, &duErr)
+	suite.Equal(4*DataUnitSize, duErr.Offset)
+	suite.Contains(duErr.Dump, "bucket name")
+	suite.Contains(err.Error(), "Data body is wrong.")
+	suite.Equal([]FieldMismatch{
+		{Field: "body", Expected: "0xff", Actual: "0x00", Position: DataUnitSize - 1},
+	}, duErr.Mismatches)
+
+	// The data unit of another object
+	otherObj := &object.Object{
+		Key:        "other-key",
+		WriteCount: 3,
+	}
+	data, err = generateDataUnit(5, workerID+1, testBucketName, otherObj)
+	suite.NoError(err)
+	suite.ErrorAs(validDataUnit(4, workerID, testBucketName, obj, data), &duErr)
+	suite.Equal([]FieldMismatch{
+		{Field: "key", Expected: testKeyName, Actual: "other-key"},
+		{Field: "writeCount", Expected: "300", Actual: "3"},
+		{Field: "offset", Expected: "1024", Actual: "1280"},
+		{Field: "workerID", Expected: "100", Actual: "101"},
+	}, duErr.Mismatches)
+	suite.Equal(testKeyName, duErr.Expected.Key)
+	suite.Equal("other-key", duErr.Actual.Key)
+	suite.NotZero(duErr.Actual.UnixMicro)
+}
+
+func (suite *PatternSuite) TestEmbeddedKeyID() {
+	obj := &object.Object{
+		Key:        "ov0102000abc/ünï cødé +%20&=?#",
+		KeyID:      "ov0102000abc",
+		Size:       3 * DataUnitSize,
+		WriteCount: 300,
+	}
+	workerID := 100
+
+	data, err := Generate(obj.Size, workerID, testBucketName, obj)
+	suite.NoError(err)
+	current := object.MaxBucketNameLength
+	suite.Equal([]byte(obj.KeyID), data[current:current+object.MaxKeyLength])
+	suite.NoError(Valid(workerID, testBucketName, obj, bytes.NewReader(data)))
+
+	// The data of the other key ID
+	otherObj := *obj
+	otherObj.KeyID = "ov0102000abd"
+	suite.Error(Valid(workerID, testBucketName, &otherObj, bytes.NewReader(data)))
+}
+
+func (suite *PatternSuite) TestParseDataUnitHeader() {
+	obj := &object.Object{
+		Key:        testKeyName,
+		WriteCount: 300,
+	}
+	workerID := 100
+
+	data, err := Generate(3*DataUnitSize, workerID, testBucketName, obj)
+	suite.NoError(err)
+	header := ParseDataUnitHeader(data[2*DataUnitSize:])
+	suite.Equal(testBucketName, header.BucketName)
+	suite.Equal(testKeyName, header.Key)
+	suite.Equal(300, header.WriteCount)
+	suite.Equal(2*DataUnitSize, header.Offset)
+	suite.Equal(workerID, header.WorkerID)
+	suite.NotZero(header.UnixMicro)
+}
+
+func (suite *PatternSuite) TestValidGeneration() {
+	obj := &object.Object{
+		Key:        testKeyName,
+		WriteCount: 300,
+	}
+	workerID := 100
+
+	data, err := Generate(3*DataUnitSize, workerID, testLongBucketName, obj)
+	suite.NoError(err)
+	gen, err := ValidGeneration(testLongBucketName, testKeyName, bytes.NewReader(data))
+	suite.NoError(err)
+	suite.Equal(workerID, gen.WorkerID)
+	suite.Equal(obj.WriteCount, gen.WriteCount)
+	suite.Equal(3*DataUnitSize, gen.Size)
+
+	// Torn object mixing the data units of two writes
+	otherData, err := Generate(3*DataUnitSize, workerID+1, testLongBucketName, &object.Object{
+		Key:        testKeyName,
+		WriteCount: 1,
+	})
+	suite.NoError(err)
+	torn := append(append([]byte{}, data[:2*DataUnitSize]...), otherData[2*DataUnitSize:]...)
+	_, err = ValidGeneration(testLongBucketName, testKeyName, bytes.NewReader(torn))
+	suite.Error(err)
+
+	// Truncated object
+	_, err = ValidGeneration(testLongBucketName, testKeyName, bytes.NewReader(data[:2*DataUnitSize+10]))
+	suite.Error(err)
+
+	// Empty object
+	_, err = ValidGeneration(testLongBucketName, testKeyName, bytes.NewReader(nil))
+	suite.Error(err)
+}
+
+func (suite *PatternSuite) TestValidRange() {
+	obj := &object.Object{
+		Key:        testKeyName,
+		WriteCount: 300,
+	}
+	workerID := 100
+
+	size := 1024
+	data, err := Generate(size, workerID, testBucketName, obj)
+	suite.NoError(err)
+	obj.Size = size
+
+	type testCase struct {
+		start  int
+		length int
+	}
+	testCases := []testCase{
+		{start: 0, length: size},
+		{start: 0, length: 1},
+		{start: 255, length: 2},
+		{start: 256, length: 256},
+		{start: 30, length: 10},
+		{start: 100, length: 700},
+		{start: size - 1, length: 1},
+	}
+	for _, tc := range testCases {
+		err = ValidRange(workerID, testBucketName, obj,
+			bytes.NewReader(data[tc.start:tc.start+tc.length]), tc.start, tc.length)
+		suite.NoErrorf(err, "tc.start: %d, tc.length: %d", tc.start, tc.length)
+	}
+
+	// Shifted data
+	err = ValidRange(workerID, testBucketName, obj, bytes.NewReader(data[257:513]), 256, 256)
+	suite.Error(err)
+
+	// Data from another generation
+	obj.WriteCount++
+	err = ValidRange(workerID, testBucketName, obj, bytes.NewReader(data[25:35]), 25, 10)
+	suite.Error(err)
+	obj.WriteCount--
+
+	// Short data
+	err = ValidRange(workerID, testBucketName, obj, bytes.NewReader(data[100:200]), 100, 101)
+	suite.Error(err)
+}
+
 func (suite *PatternSuite) TestDecideSize() {
 	type testCase struct {
 		minSize     int
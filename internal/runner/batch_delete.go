@@ -0,0 +1,114 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"slices"
+
+	"github.com/peng225/oval/internal/object"
+)
+
+const (
+	// The maximum number of the keys deleted by a batch delete operation.
+	maxBatchDeleteKeys = 8
+)
+
+// BatchDelete deletes random objects, which may or may not exist, by a single DeleteObjects request.
+// All keys must be reported as deleted and must not be found after that.
+func (w *Worker) BatchDelete(ctx context.Context) error {
+	bucketWithObj := w.selectBucketWithObject()
+	numObj := bucketWithObj.ObjectMeta.NumObj()
+	numKeys := 1 + rand.Intn(min(maxBatchDeleteKeys, numObj))
+	objs := make([]*object.Object, 0, numKeys)
+	keys := make([]string, 0, numKeys)
+	for _, i := range randomObjectIDs(numObj, numKeys) {
+		obj := bucketWithObj.ObjectMeta.GetObjectByID(i)
+		if obj.Quarantined {
+			continue
+		}
+		err := w.validBeforeWrite(ctx, bucketWithObj, obj, "batch delete")
+		if err != nil {
+			if errors.Is(err, errCanceled) {
+				return nil
+			}
+			return err
+		}
+		objs = append(objs, obj)
+		keys = append(keys, obj.Key)
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	res, err := w.client.DeleteObjects(ctx, bucketWithObj.BucketName, keys)
+	if err != nil {
+		w.logger.Error(err.Error())
+		return err
+	}
+	if len(res.Errors) != 0 {
+		err = fmt.Errorf("some keys failed to be deleted by the batch delete. (errors = %+v)", res.Errors)
+		w.logger.Error(err.Error())
+		return err
+	}
+	// Deleting a nonexistent key also succeeds.
+	deleteMarkerVersionIDs := make(map[string]string, len(res.Deleted))
+	for _, d := range res.Deleted {
+		if _, ok := deleteMarkerVersionIDs[d.Key]; ok {
+			err = fmt.Errorf("a key was reported as deleted more than once by the batch delete. (key = %s)", d.Key)
+			w.logger.Error(err.Error())
+			return err
+		}
+		deleteMarkerVersionIDs[d.Key] = d.DeleteMarkerVersionID
+	}
+	if len(deleteMarkerVersionIDs) != len(keys) {
+		err = fmt.Errorf("the keys reported as deleted by the batch delete are wrong. (expected = %v, actual = %+v)",
+			keys, res.Deleted)
+		w.logger.Error(err.Error())
+		return err
+	}
+	w.st.AddBatchDeleteCount()
+	w.st.AddBatchDeletedKeyCount(int64(len(keys)))
+
+	for _, obj := range objs {
+		versionID, ok := deleteMarkerVersionIDs[obj.Key]
+		if !ok {
+			err = fmt.Errorf("a key was not reported as deleted by the batch delete. (key = %s)", obj.Key)
+			w.logger.Error(err.Error())
+			return err
+		}
+		existed := bucketWithObj.ObjectMeta.Exist(obj.Key)
+		bucketWithObj.ObjectMeta.UnregisterFromExistingList(obj.Key)
+		if w.versioning {
+			// A delete marker is created even if the key did not exist.
+			err = w.recordDeleteMarker(ctx, bucketWithObj, obj, versionID)
+			if err != nil {
+				return err
+			}
+		} else if existed {
+			obj.Clear()
+		}
+
+		err = w.validCurrentState(ctx, bucketWithObj, obj, "after batch delete")
+		if err != nil {
+			if errors.Is(err, errCanceled) {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// randomObjectIDs returns `n` distinct random object IDs less than `numObj`.
+func randomObjectIDs(numObj, n int) []int64 {
+	ids := make([]int64, 0, n)
+	for len(ids) < n {
+		id := rand.Int63n(int64(numObj))
+		if !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,351 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/peng225/oval/internal/object"
+	"github.com/peng225/oval/internal/pattern"
+	"github.com/peng225/oval/internal/s3client"
+)
+
+// checkpointContext is the execution context whose workers were snapshotted one by one.
+type checkpointContext struct {
+	*ExecutionContext
+	Workers []json.RawMessage `json:"workers"`
+}
+
+// EnableCheckpoint makes the runner save the execution context to the file `saveFileName`
+// every `interval` during the workload and when the workload finishes even with an error.
+func (r *Runner) EnableCheckpoint(saveFileName string, interval time.Duration) {
+	r.saveFileName = saveFileName
+	r.checkpointInterval = interval
+}
+
+// startCheckpoint starts saving the execution context periodically
+// and returns the function to stop it.
+func (r *Runner) startCheckpoint() func() {
+	if r.checkpointInterval == 0 {
+		return func() {}
+	}
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(r.checkpointInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				err := r.checkpoint()
+				if err != nil {
+					slog.Error("Checkpoint failed.", "err", err)
+					continue
+				}
+				slog.Info("Checkpoint saved.", "file", r.saveFileName)
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// checkpoint saves the execution context while the workers are running.
+// Each worker is snapshotted between its operations, so the snapshot of each worker is consistent.
+// The journal is rotated before the snapshots, so it keeps the objects used after them.
+func (r *Runner) checkpoint() error {
+	if r.journal != nil {
+		err := r.journal.rotate()
+		if err != nil {
+			return err
+		}
+	}
+	cc := &checkpointContext{
+		ExecutionContext: r.execContext,
+		Workers:          make([]json.RawMessage, len(r.execContext.Workers)),
+	}
+	for i := range r.execContext.Workers {
+		w := &r.execContext.Workers[i]
+		w.mu.Lock()
+		data, err := json.Marshal(w)
+		w.mu.Unlock()
+		if err != nil {
+			return err
+		}
+		cc.Workers[i] = data
+	}
+	err := writeContextFile(r.saveFileName, cc)
+	if err != nil {
+		return err
+	}
+	if r.journal != nil {
+		return r.journal.commit()
+	}
+	return nil
+}
+
+// resolveUncertainObjects decides the state of each object whose last operation was interrupted.
+// The object is read once, and the state is accepted if it is either the recorded one before the operation
+// or the one written by the operations after it. Otherwise, the object is reset so that the workload can continue,
+// and the mismatch is returned as a finding.
+func (w *Worker) resolveUncertainObjects(ctx context.Context) ([]*objectError, error) {
+	findings := make([]*objectError, 0)
+	for _, bucketWithObj := range w.BucketsWithObject {
+		for _, obj := range bucketWithObj.ObjectMeta.UncertainObjects() {
+			err := w.abortUncertainUploads(ctx, bucketWithObj, obj.Key)
+			if err != nil {
+				return nil, err
+			}
+			mismatch, err := w.acceptUncertainState(ctx, bucketWithObj, obj)
+			if err != nil {
+				w.logger.Error(err.Error())
+				return nil, err
+			}
+			if mismatch != nil {
+				w.logger.Warn(mismatch.Error())
+				err = w.resetObject(ctx, bucketWithObj, obj)
+				if err != nil {
+					return nil, err
+				}
+				findings = append(findings, mismatch)
+				continue
+			}
+			obj.Uncertain = false
+			w.logger.Info("Uncertain object was resolved.", "bucket", bucketWithObj.BucketName, "key", obj.Key,
+				"exists", bucketWithObj.ObjectMeta.Exist(obj.Key), "writeCount", obj.WriteCount)
+		}
+		bucketWithObj.ObjectMeta.Release()
+	}
+	return findings, nil
+}
+
+// abortUncertainUploads aborts the in-progress multipart uploads of `key`,
+// because the interrupted operation may have started or aborted an upload without recording it.
+func (w *Worker) abortUncertainUploads(ctx context.Context, bucketWithObj *BucketWithObject, key string) error {
+	uploads, err := w.client.ListMultipartUploads(ctx, bucketWithObj.BucketName, key)
+	if err != nil {
+		w.logger.Error(err.Error())
+		return err
+	}
+	for _, u := range uploads {
+		if u.Key != key {
+			continue
+		}
+		err = w.client.AbortMultipartUpload(ctx, bucketWithObj.BucketName, u.Key, u.UploadID)
+		if err != nil {
+			w.logger.Error(err.Error())
+			return err
+		}
+	}
+	bucketWithObj.DanglingUploads = slices.DeleteFunc(bucketWithObj.DanglingUploads,
+		func(u s3client.MultipartUpload) bool {
+			return u.Key == key
+		})
+	return nil
+}
+
+// acceptUncertainState updates `obj` to the state found in the storage if it is the recorded one
+// or a newer write of the worker. It returns the error describing the mismatch otherwise.
+func (w *Worker) acceptUncertainState(ctx context.Context, bucketWithObj *BucketWithObject,
+	obj *object.Object) (*objectError, error) {
+	om := bucketWithObj.ObjectMeta
+	expected := stateNotFound
+	if om.Exist(obj.Key) {
+		expected = objectState(obj)
+	}
+	expected += " or a newer write"
+	mismatch := func(actual string, err error) *objectError {
+		return newObjectError(FindingUncertain, "load", bucketWithObj.BucketName, obj, expected, actual,
+			fmt.Errorf("the uncertain object is in neither the recorded state nor the one of a newer write. (key = %s)\n%w",
+				obj.Key, err))
+	}
+
+	var newVersion *s3client.ObjectVersion
+	if w.versioning {
+		var err error
+		newVersion, err = w.acceptUncertainVersions(ctx, bucketWithObj, obj)
+		if err != nil {
+			var objErr *objectError
+			if errors.As(err, &objErr) {
+				return objErr, nil
+			}
+			return nil, err
+		}
+	}
+
+	body, err := w.client.GetObject(ctx, bucketWithObj.BucketName, obj.Key)
+	if err != nil {
+		if !errors.Is(err, s3client.ErrNoSuchKey) {
+			return nil, err
+		}
+		if newVersion != nil && !newVersion.DeleteMarker {
+			return mismatch(stateNotFound, fmt.Errorf("the new version was not found. (versionID = %s)", newVersion.VersionID)), nil
+		}
+		if w.versioning && newVersion == nil && om.Exist(obj.Key) {
+			return mismatch(stateNotFound, errors.New("the object was deleted without a delete marker")), nil
+		}
+		// The object was deleted, or it was never written.
+		om.UnregisterFromExistingList(obj.Key)
+		if newVersion != nil {
+			obj.AddDeleteMarker(newVersion.VersionID)
+		} else if !w.versioning {
+			obj.Clear()
+		}
+		return nil, nil
+	}
+	defer body.Close()
+	if newVersion != nil && newVersion.DeleteMarker {
+		return mismatch(stateExists, fmt.Errorf("the object was found despite the new delete marker. (versionID = %s)",
+			newVersion.VersionID)), nil
+	}
+
+	// The first data unit tells which state should be validated.
+	head := make([]byte, pattern.DataUnitSize)
+	n, _ := io.ReadFull(body, head)
+	if n != pattern.DataUnitSize {
+		return mismatch(fmt.Sprintf("%s (size = %d)", stateExists, body.Size),
+			errors.New("the object is smaller than a data unit")), nil
+	}
+	header := pattern.ParseDataUnitHeader(head)
+	candidate := *obj
+	recorded := newVersion == nil && om.Exist(obj.Key) &&
+		isDataOf(&obj.DataSegments(bucketWithObj.BucketName, 0, pattern.DataUnitSize)[0].Source, header)
+	if !recorded {
+		if (w.versioning && newVersion == nil) || header.WorkerID != w.id || header.WriteCount <= obj.WriteCount ||
+			!isDataOf(&object.DataSource{
+				BucketName: bucketWithObj.BucketName,
+				Key:        obj.EmbeddedKey(),
+				WriteCount: header.WriteCount,
+			}, header) {
+			return mismatch(fmt.Sprintf("%s (header = %+v)", stateExists, *header),
+				errors.New("the data is of neither state")), nil
+		}
+		// The checksum and the ETag of the newer write are unknown.
+		candidate.Size = int(body.Size)
+		candidate.WriteCount = header.WriteCount
+		candidate.Segments = nil
+		candidate.Checksum = nil
+		candidate.ETag = ""
+		candidate.Metadata = &object.Metadata{
+			BucketName: bucketWithObj.BucketName,
+			Key:        obj.EmbeddedKey(),
+			WriteCount: header.WriteCount,
+			WorkerID:   w.id,
+		}
+		if validMetadataHeaders(&candidate, &body.MetadataHeaders) != "" {
+			// The write did not attach the metadata.
+			candidate.Metadata = nil
+		}
+	}
+	err = pattern.Valid(w.id, bucketWithObj.BucketName, &candidate, io.MultiReader(bytes.NewReader(head), body))
+	if err == nil {
+		err = w.validChecksum(&candidate, body)
+	}
+	if err == nil {
+		var mdMismatch string
+		mdMismatch, err = w.validMetadata(ctx, bucketWithObj.BucketName, &candidate, "", body)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, err
+		}
+		if mdMismatch != "" {
+			err = errors.New(mdMismatch)
+		}
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return mismatch(objectState(&candidate), err), nil
+	}
+	if !recorded {
+		*obj = candidate
+		om.RegisterToExistingList(obj.Key)
+		if newVersion != nil {
+			obj.AddVersion(newVersion.VersionID)
+		}
+	}
+	return nil, nil
+}
+
+// acceptUncertainVersions removes the oldest versions of `obj` pruned by the interrupted operations,
+// and returns the version added by them. It returns nil if no version was added,
+// and the objectError if the versions were changed otherwise.
+func (w *Worker) acceptUncertainVersions(ctx context.Context, bucketWithObj *BucketWithObject,
+	obj *object.Object) (*s3client.ObjectVersion, error) {
+	versions, err := w.client.ListObjectVersions(ctx, bucketWithObj.BucketName, obj.Key)
+	if err != nil {
+		return nil, err
+	}
+	versions = slices.DeleteFunc(versions, func(v s3client.ObjectVersion) bool {
+		return v.Key != obj.Key
+	})
+	listed := make(map[string]bool, len(versions))
+	added := make([]s3client.ObjectVersion, 0)
+	for _, v := range versions {
+		listed[v.VersionID] = true
+		if !slices.ContainsFunc(obj.Versions, func(ov object.Version) bool {
+			return ov.VersionID == v.VersionID
+		}) {
+			added = append(added, v)
+		}
+	}
+	numPruned := 0
+	for numPruned < len(obj.Versions) && !listed[obj.Versions[numPruned].VersionID] {
+		numPruned++
+	}
+	lost := slices.ContainsFunc(obj.Versions[numPruned:], func(ov object.Version) bool {
+		return !listed[ov.VersionID]
+	})
+	if lost || len(added) > 1 || (len(added) == 1 && !added[0].IsLatest) {
+		return nil, newObjectError(FindingUncertain, "load", bucketWithObj.BucketName, obj,
+			fmt.Sprintf("%d versions or a newer version", len(obj.Versions)), fmt.Sprintf("%d versions", len(versions)),
+			fmt.Errorf("the versions of the uncertain object were changed by more than the pruning and a new version. (key = %s, added = %d)",
+				obj.Key, len(added)))
+	}
+	obj.Versions = slices.Clone(obj.Versions[numPruned:])
+	if len(added) == 0 {
+		return nil, nil
+	}
+	return &added[0], nil
+}
+
+// resetObject deletes the object and all of its versions so that the workload can continue from the empty state.
+func (w *Worker) resetObject(ctx context.Context, bucketWithObj *BucketWithObject, obj *object.Object) error {
+	var err error
+	if w.versioning {
+		err = w.client.ClearBucketVersions(ctx, bucketWithObj.BucketName, obj.Key)
+	} else {
+		err = w.client.ClearBucket(ctx, bucketWithObj.BucketName, obj.Key)
+	}
+	if err != nil {
+		w.logger.Error(err.Error())
+		return err
+	}
+	bucketWithObj.ObjectMeta.UnregisterFromExistingList(obj.Key)
+	obj.Clear()
+	w.logger.Info("Uncertain object was reset.", "bucket", bucketWithObj.BucketName, "key", obj.Key)
+	return nil
+}
+
+// isDataOf returns true if the data unit with `header` is of the data source `src`.
+func isDataOf(src *object.DataSource, header *pattern.DataUnitHeader) bool {
+	bucketName := src.BucketName
+	if len(bucketName) > object.MaxBucketNameLength {
+		bucketName = bucketName[:object.MaxBucketNameLength]
+	}
+	return header.BucketName == bucketName && header.Key == src.Key && header.WriteCount == src.WriteCount
+}
@@ -0,0 +1,120 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/peng225/oval/internal/object"
+	"github.com/peng225/oval/internal/pattern"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckpoint(t *testing.T) {
+	ec := newTestExecutionContext()
+	om := ec.Workers[0].BucketsWithObject[0].ObjectMeta
+	obj := om.GetObjectByID(5)
+	obj.Size = 100
+	obj.WriteCount = 1
+	om.Release()
+	// The operation after the write was interrupted.
+	obj = om.GetObjectByID(5)
+	obj.WriteCount++
+	om.MarkUncertain()
+	om.Release()
+
+	r := &Runner{execContext: ec}
+	r.EnableCheckpoint(filepath.Join(t.TempDir(), "context"), time.Minute)
+	require.NoError(t, r.checkpoint())
+
+	loaded, err := readContextFile(r.saveFileName)
+	require.NoError(t, err)
+	assert.Equal(t, ec.BucketNames, loaded.BucketNames)
+	require.Len(t, loaded.Workers, 1)
+	uncertain := loaded.Workers[0].BucketsWithObject[0].ObjectMeta.UncertainObjects()
+	require.Len(t, uncertain, 1)
+	assert.Equal(t, obj.Key, uncertain[0].Key)
+	assert.Equal(t, 100, uncertain[0].Size)
+	assert.Equal(t, 1, uncertain[0].WriteCount)
+}
+
+func TestCheckpointJournal(t *testing.T) {
+	ec := newTestExecutionContext()
+	w := &ec.Workers[0]
+	om := w.BucketsWithObject[0].ObjectMeta
+	r := &Runner{execContext: ec}
+	r.EnableCheckpoint(filepath.Join(t.TempDir(), "context"), time.Minute)
+	require.NoError(t, r.startJournal())
+	require.NoError(t, r.checkpoint())
+
+	// The reads are not journaled.
+	om.GetObjectByID(4)
+	om.Release()
+	// Write an object after the checkpoint and get killed before the next one.
+	w.mutating = true
+	obj := om.GetObjectByID(7)
+	obj.Size = 4096
+	obj.WriteCount = 1
+	om.RegisterToExistingList(obj.Key)
+	om.Release()
+	w.mutating = false
+
+	loaded, err := readContextFile(r.saveFileName)
+	require.NoError(t, err)
+	require.NoError(t, applyJournal(loaded, r.saveFileName))
+	uncertain := loaded.Workers[0].BucketsWithObject[0].ObjectMeta.UncertainObjects()
+	require.Len(t, uncertain, 1)
+	assert.Equal(t, obj.Key, uncertain[0].Key)
+	// The object is in the state of the checkpoint, which is reset before the workload.
+	assert.Equal(t, 0, uncertain[0].WriteCount)
+
+	// The next checkpoint includes the write, so the journal is no longer needed.
+	require.NoError(t, r.checkpoint())
+	loaded, err = readContextFile(r.saveFileName)
+	require.NoError(t, err)
+	require.NoError(t, applyJournal(loaded, r.saveFileName))
+	assert.Empty(t, loaded.Workers[0].BucketsWithObject[0].ObjectMeta.UncertainObjects())
+	assert.Equal(t, 1, loaded.Workers[0].BucketsWithObject[0].ObjectMeta.GetObject(obj.Key).WriteCount)
+
+	require.NoError(t, r.journal.remove())
+	_, err = os.Stat(r.saveFileName + journalFileSuffix)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestCheckpointJournalRotation(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "context")
+	j, err := openJournal(fileName)
+	require.NoError(t, err)
+	require.NoError(t, j.record(&journalEntry{BucketName: "bucket", Key: "key1"}))
+	require.NoError(t, j.rotate())
+	require.NoError(t, j.record(&journalEntry{BucketName: "bucket", Key: "key2"}))
+	// The entries are kept until a checkpoint is saved.
+	require.NoError(t, j.rotate())
+	require.NoError(t, j.record(&journalEntry{BucketName: "bucket", Key: "key3"}))
+	entries, err := readJournal(fileName)
+	require.NoError(t, err)
+	keys := make([]string, 0)
+	for _, entry := range entries {
+		keys = append(keys, entry.Key)
+	}
+	assert.Equal(t, []string{"key1", "key2", "key3"}, keys)
+
+	require.NoError(t, j.commit())
+	entries, err = readJournal(fileName)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "key3", entries[0].Key)
+}
+
+func TestIsDataOf(t *testing.T) {
+	src := &object.DataSource{BucketName: "a-long-bucket-name-for-test", Key: "ov0000000001", WriteCount: 3}
+	header := &pattern.DataUnitHeader{BucketName: "a-long-bucket-na", Key: "ov0000000001", WriteCount: 3}
+	assert.True(t, isDataOf(src, header))
+	header.WriteCount = 4
+	assert.False(t, isDataOf(src, header))
+	header.WriteCount = 3
+	header.Key = "ov0000000002"
+	assert.False(t, isDataOf(src, header))
+}
@@ -0,0 +1,149 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/peng225/oval/internal/object"
+	"github.com/peng225/oval/internal/pattern"
+	"github.com/peng225/oval/internal/s3client"
+)
+
+const (
+	// The ETag which is never returned by the storage.
+	fakeETag = `"00000000000000000000000000000000"`
+)
+
+// ConditionalPut writes a random object by the conditional PutObject requests.
+// If the object exists, `If-None-Match: *` must be rejected, `If-Match` with the current ETag must succeed
+// and `If-Match` with the ETag which became stale by the write must be rejected.
+// If the object does not exist, `If-Match` must be rejected and `If-None-Match: *` must succeed.
+func (w *Worker) ConditionalPut(ctx context.Context) error {
+	bucketWithObj := w.selectBucketWithObject()
+	obj := bucketWithObj.ObjectMeta.GetRandomObject()
+	if obj == nil {
+		return nil
+	}
+
+	err := w.validBeforeWrite(ctx, bucketWithObj, obj, "conditional put")
+	if err != nil {
+		if errors.Is(err, errCanceled) {
+			return nil
+		}
+		return err
+	}
+
+	if bucketWithObj.ObjectMeta.Exist(obj.Key) {
+		err = w.rejectedPut(ctx, bucketWithObj, obj, &s3client.WriteCondition{IfNoneMatch: "*"})
+		if err != nil {
+			return err
+		}
+		if obj.ETag == "" {
+			// The current ETag is unknown if the object was written by an older version of oval.
+			return nil
+		}
+		staleETag := obj.ETag
+		err = w.acceptedPut(ctx, bucketWithObj, obj, &s3client.WriteCondition{IfMatch: staleETag})
+		if err != nil {
+			return err
+		}
+		return w.rejectedPut(ctx, bucketWithObj, obj, &s3client.WriteCondition{IfMatch: staleETag})
+	}
+
+	err = w.rejectedPut(ctx, bucketWithObj, obj, &s3client.WriteCondition{IfMatch: fakeETag})
+	if err != nil {
+		return err
+	}
+	return w.acceptedPut(ctx, bucketWithObj, obj, &s3client.WriteCondition{IfNoneMatch: "*"})
+}
+
+// acceptedPut writes `obj` by the conditional PutObject request which must succeed.
+func (w *Worker) acceptedPut(ctx context.Context, bucketWithObj *BucketWithObject, obj *object.Object,
+	cond *s3client.WriteCondition) error {
+	size, err := pattern.DecideSize(w.minSize, w.maxSize)
+	if err != nil {
+		w.logger.Error(err.Error())
+		return err
+	}
+	obj.Size = size
+	bucketWithObj.ObjectMeta.RegisterToExistingList(obj.Key)
+	obj.WriteCount++
+	obj.Segments = nil
+	body, err := pattern.NewReader(size, w.id, bucketWithObj.BucketName, obj)
+	if err != nil {
+		w.logger.Error(err.Error())
+		return err
+	}
+	res, err := w.client.PutObjectWithOptions(ctx, bucketWithObj.BucketName, obj.Key, body, int64(size),
+		&s3client.PutOptions{Condition: cond})
+	if err != nil {
+		if errors.Is(err, s3client.ErrPreconditionFailed) {
+			err = fmt.Errorf("expected: conditional put succeeded, actual: precondition failed. (cond = %+v)\nerr: %w\nobj: %v",
+				*cond, err, obj)
+		}
+		w.logger.Error(err.Error())
+		return err
+	}
+	obj.Checksum = writtenChecksum(res)
+	obj.ETag = writtenETag(res)
+	obj.Metadata = nil
+
+	w.st.AddUploadedPartCount(int64(res.PartCount))
+	w.st.AddConditionalPutCount()
+
+	err = w.recordVersion(ctx, bucketWithObj, obj, res.VersionID)
+	if err != nil {
+		return err
+	}
+
+	err = w.validAfterWrite(ctx, bucketWithObj, obj, "conditional put")
+	if err != nil && !errors.Is(err, errCanceled) {
+		return err
+	}
+	return nil
+}
+
+// rejectedPut issues the conditional PutObject request for `obj` which must fail.
+// The object must remain unchanged.
+func (w *Worker) rejectedPut(ctx context.Context, bucketWithObj *BucketWithObject, obj *object.Object,
+	cond *s3client.WriteCondition) error {
+	size, err := pattern.DecideSize(w.minSize, w.maxSize)
+	if err != nil {
+		w.logger.Error(err.Error())
+		return err
+	}
+	// The data is the one of a generation which must never be visible.
+	obj.SkipWriteCount(bucketWithObj.BucketName)
+	body, err := pattern.NewReader(size, w.id, bucketWithObj.BucketName, &object.Object{
+		Key:        obj.Key,
+		KeyID:      obj.KeyID,
+		WriteCount: obj.WriteCount,
+	})
+	if err != nil {
+		w.logger.Error(err.Error())
+		return err
+	}
+	_, err = w.client.PutObjectWithOptions(ctx, bucketWithObj.BucketName, obj.Key, body, int64(size),
+		&s3client.PutOptions{Condition: cond})
+	if err == nil {
+		err = fmt.Errorf("expected: precondition failed, actual: conditional put succeeded. (cond = %+v)\nobj: %v",
+			*cond, obj)
+		w.logger.Error(err.Error())
+		return err
+	}
+	// If-Match against a nonexistent object may be rejected as a missing key.
+	if !errors.Is(err, s3client.ErrPreconditionFailed) &&
+		!(cond.IfMatch != "" && errors.Is(err, s3client.ErrNoSuchKey)) {
+		err = fmt.Errorf("unexpected error occurred. (err = %w)", err)
+		w.logger.Error(err.Error())
+		return err
+	}
+	w.st.AddPreconditionFailedCount()
+
+	err = w.validCurrentState(ctx, bucketWithObj, obj, "after rejected conditional put")
+	if err != nil && !errors.Is(err, errCanceled) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package runner
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// The context file consists of the header and the payload, which is the gzip-compressed JSON
+// of the execution context. The header is in the following format. (little endian)
+//
+//	magic         [8]byte
+//	version       uint32
+//	compression   uint32
+//	payloadLength uint64
+//	checksum      [32]byte (SHA-256 of the payload)
+//
+// The legacy context file is the plain JSON of the execution context.
+const (
+	contextFileMagic           = "OVALCTX\x00"
+	contextFileVersion         = 1
+	contextFileCompressionGzip = 1
+	contextFileHeaderSize      = 8 + 4 + 4 + 8 + sha256.Size
+	contextFilePermission      = 0644
+)
+
+var ErrCorruptedContextFile = errors.New("the context file is truncated or corrupted")
+
+type contextFileHeader struct {
+	Magic         [8]byte
+	Version       uint32
+	Compression   uint32
+	PayloadLength uint64
+	Checksum      [sha256.Size]byte
+}
+
+// writeContextFile saves the execution context `ec` to the file `fileName` atomically.
+// The file is written to a temporary file, synced and renamed to `fileName`
+// so that the previous file is kept if the process crashes on the way.
+func writeContextFile(fileName string, ec any) error {
+	data, err := json.Marshal(ec)
+	if err != nil {
+		return err
+	}
+	payload := &bytes.Buffer{}
+	zw := gzip.NewWriter(payload)
+	_, err = zw.Write(data)
+	if err != nil {
+		return err
+	}
+	err = zw.Close()
+	if err != nil {
+		return err
+	}
+
+	header := contextFileHeader{
+		Version:       contextFileVersion,
+		Compression:   contextFileCompressionGzip,
+		PayloadLength: uint64(payload.Len()),
+		Checksum:      sha256.Sum256(payload.Bytes()),
+	}
+	copy(header.Magic[:], contextFileMagic)
+
+	f, err := os.CreateTemp(filepath.Dir(fileName), filepath.Base(fileName)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpFileName := f.Name()
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(tmpFileName)
+		}
+	}()
+	err = binary.Write(f, binary.LittleEndian, &header)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(payload.Bytes())
+	if err != nil {
+		return err
+	}
+	err = f.Chmod(contextFilePermission)
+	if err != nil {
+		return err
+	}
+	err = f.Sync()
+	if err != nil {
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmpFileName, fileName)
+	if err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(fileName))
+}
+
+// syncDir makes the rename in the directory `dirName` durable.
+func syncDir(dirName string) error {
+	d, err := os.Open(dirName)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// readContextFile loads the execution context from the file `fileName`.
+// Both the current format and the legacy JSON format are supported.
+func readContextFile(fileName string) (*ExecutionContext, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	ec := &ExecutionContext{}
+	if !bytes.HasPrefix(data, []byte(contextFileMagic)) {
+		err = json.Unmarshal(data, ec)
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to parse the legacy context file\n%w", ErrCorruptedContextFile, err)
+		}
+		return ec, nil
+	}
+
+	payload, err := contextFilePayload(data)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("%w\n%w", ErrCorruptedContextFile, err)
+	}
+	defer zr.Close()
+	data, err = io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("%w\n%w", ErrCorruptedContextFile, err)
+	}
+	err = json.Unmarshal(data, ec)
+	if err != nil {
+		return nil, fmt.Errorf("%w\n%w", ErrCorruptedContextFile, err)
+	}
+	return ec, nil
+}
+
+// contextFilePayload validates the header of the context file `data` and returns the payload.
+func contextFilePayload(data []byte) ([]byte, error) {
+	if len(data) < contextFileHeaderSize {
+		return nil, fmt.Errorf("%w: the header is truncated. (size = %d)", ErrCorruptedContextFile, len(data))
+	}
+	header := contextFileHeader{}
+	err := binary.Read(bytes.NewReader(data), binary.LittleEndian, &header)
+	if err != nil {
+		return nil, err
+	}
+	if header.Version != contextFileVersion {
+		return nil, fmt.Errorf("unsupported version of the context file: %d", header.Version)
+	}
+	if header.Compression != contextFileCompressionGzip {
+		return nil, fmt.Errorf("unsupported compression of the context file: %d", header.Compression)
+	}
+	payload := data[contextFileHeaderSize:]
+	if uint64(len(payload)) != header.PayloadLength {
+		return nil, fmt.Errorf("%w: the payload length is wrong. (expected = %d, actual = %d)",
+			ErrCorruptedContextFile, header.PayloadLength, len(payload))
+	}
+	if sha256.Sum256(payload) != header.Checksum {
+		return nil, fmt.Errorf("%w: the checksum is wrong", ErrCorruptedContextFile)
+	}
+	return payload, nil
+}
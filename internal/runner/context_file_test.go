@@ -0,0 +1,93 @@
+package runner
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/peng225/oval/internal/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestExecutionContext() *ExecutionContext {
+	om := object.NewObjectMeta(10, 0, 0, 1, object.KeySchemeFlat)
+	obj := om.GetObjectByID(3)
+	obj.Size = 4096
+	obj.WriteCount = 2
+	om.RegisterToExistingList(obj.Key)
+	om.Release()
+	return &ExecutionContext{
+		Endpoint:    "http://localhost:9000",
+		BucketNames: []string{"test-bucket"},
+		NumObj:      10,
+		NumWorker:   1,
+		MinSize:     4096,
+		MaxSize:     4096,
+		Workers: []Worker{
+			{
+				BucketsWithObject: []*BucketWithObject{
+					{
+						BucketName: "test-bucket",
+						ObjectMeta: om,
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestContextFile(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "context")
+	ec := newTestExecutionContext()
+	require.NoError(t, writeContextFile(fileName, ec))
+	// Overwriting the existing file is allowed.
+	require.NoError(t, writeContextFile(fileName, ec))
+	entries, err := os.ReadDir(filepath.Dir(fileName))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	loaded, err := readContextFile(fileName)
+	require.NoError(t, err)
+	assert.Equal(t, ec.Endpoint, loaded.Endpoint)
+	assert.Equal(t, ec.BucketNames, loaded.BucketNames)
+	require.Len(t, loaded.Workers, 1)
+	om := loaded.Workers[0].BucketsWithObject[0].ObjectMeta
+	obj := om.GetObjectByID(3)
+	assert.Equal(t, 4096, obj.Size)
+	assert.Equal(t, 2, obj.WriteCount)
+	assert.True(t, om.Exist(obj.Key))
+
+	data, err := os.ReadFile(fileName)
+	require.NoError(t, err)
+	// Truncated
+	for _, size := range []int{len(data) - 1, contextFileHeaderSize, contextFileHeaderSize - 1, 3} {
+		require.NoError(t, os.WriteFile(fileName, data[:size], 0644))
+		_, err = readContextFile(fileName)
+		assert.ErrorIsf(t, err, ErrCorruptedContextFile, "size: %d", size)
+	}
+	// Corrupted
+	corrupted := append([]byte{}, data...)
+	corrupted[len(corrupted)-10] ^= 0xff
+	require.NoError(t, os.WriteFile(fileName, corrupted, 0644))
+	_, err = readContextFile(fileName)
+	assert.ErrorIs(t, err, ErrCorruptedContextFile)
+}
+
+func TestLegacyContextFile(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "context")
+	ec := newTestExecutionContext()
+	data, err := json.Marshal(ec)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(fileName, data, 0644))
+
+	loaded, err := readContextFile(fileName)
+	require.NoError(t, err)
+	assert.Equal(t, ec.BucketNames, loaded.BucketNames)
+	assert.True(t, loaded.Workers[0].BucketsWithObject[0].ObjectMeta.Exist(ec.Workers[0].BucketsWithObject[0].ObjectMeta.GetObjectByID(3).Key))
+
+	require.NoError(t, os.WriteFile(fileName, data[:len(data)/2], 0644))
+	_, err = readContextFile(fileName)
+	assert.ErrorIs(t, err, ErrCorruptedContextFile)
+}
@@ -0,0 +1,340 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/peng225/oval/internal/object"
+	"github.com/peng225/oval/internal/pattern"
+)
+
+const (
+	// The name of the file to which the corruption records are appended in the JSON Lines format.
+	corruptionLogFileName = "corruptions.jsonl"
+)
+
+// CorruptionRecord is the machine-readable record of a validation failure of the data of an object.
+type CorruptionRecord struct {
+	Time       time.Time    `json:"time"`
+	Class      FindingClass `json:"class"`
+	Operation  string       `json:"operation"`
+	Phase      string       `json:"phase,omitempty"`
+	WorkerID   int          `json:"workerID"`
+	BucketName string       `json:"bucketName"`
+	Key        string       `json:"key"`
+	VersionID  string       `json:"versionID,omitempty"`
+	Expected   string       `json:"expected,omitempty"`
+	Actual     string       `json:"actual,omitempty"`
+	// DataUnit is set if a data unit had the unexpected contents.
+	DataUnit *DataUnitRecord `json:"dataUnit,omitempty"`
+	// DumpFile is the file which has the raw bytes exactly as they were read by the failed validation.
+	DumpFile string `json:"dumpFile,omitempty"`
+	// DumpRange is the HTTP range of the data in the dump file if only a part of the object was read.
+	DumpRange string `json:"dumpRange,omitempty"`
+	// Analysis is the classification of the whole data in the dump file. It is not set for a part of the object.
+	Analysis *pattern.Analysis `json:"analysis,omitempty"`
+	// RereadDumpFile is the file which has the whole object read again after the failure.
+	// It is only set if the re-read is enabled, and the data may differ from the one which failed the validation.
+	RereadDumpFile string `json:"rereadDumpFile,omitempty"`
+	Message        string `json:"message"`
+}
+
+// DataUnitRecord describes the first data unit which had the unexpected contents.
+type DataUnitRecord struct {
+	// Offset is the byte offset of the data unit in the object.
+	Offset     int                     `json:"offset"`
+	Mismatches []pattern.FieldMismatch `json:"mismatches"`
+	Expected   *pattern.DataUnitHeader `json:"expected"`
+	Actual     *pattern.DataUnitHeader `json:"actual"`
+}
+
+// corruptionRecorder writes the corruption records and the dump files to the directory `dir`.
+type corruptionRecorder struct {
+	mu  sync.Mutex
+	dir string
+	// reread is true if the object is also read again and dumped after each failure.
+	reread bool
+	// captureFiles is the list of the capture files which are not in use.
+	captureFiles []*os.File
+}
+
+func newCorruptionRecorder(dir string, reread bool) (*corruptionRecorder, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	return &corruptionRecorder{
+		dir:    dir,
+		reread: reread,
+	}, nil
+}
+
+// record appends `rec` to the corruption log file.
+func (cr *corruptionRecorder) record(rec *CorruptionRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	f, err := os.OpenFile(filepath.Join(cr.dir, corruptionLogFileName), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(data, '\n'))
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// dump writes the data read from `body` to a new dump file and returns its name.
+func (cr *corruptionRecorder) dump(body io.Reader) (string, error) {
+	f, err := os.CreateTemp(cr.dir, "dump-*.bin")
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(f, body)
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	err = f.Close()
+	if err != nil {
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// captureFile returns a capture file to which the data being validated is copied.
+func (cr *corruptionRecorder) captureFile() (*os.File, error) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	if len(cr.captureFiles) == 0 {
+		return os.CreateTemp(cr.dir, "capture-*.tmp")
+	}
+	f := cr.captureFiles[len(cr.captureFiles)-1]
+	cr.captureFiles = cr.captureFiles[:len(cr.captureFiles)-1]
+	return f, nil
+}
+
+// releaseCaptureFile empties the capture file `f` to reuse it for the next validation.
+func (cr *corruptionRecorder) releaseCaptureFile(f *os.File) {
+	_, err := f.Seek(0, io.SeekStart)
+	if err == nil {
+		err = f.Truncate(0)
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return
+	}
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	cr.captureFiles = append(cr.captureFiles, f)
+}
+
+// keepCaptureFile turns the capture file `f` into a new dump file and returns its name.
+func (cr *corruptionRecorder) keepCaptureFile(f *os.File) (string, error) {
+	err := f.Close()
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	dumpFile, err := os.CreateTemp(cr.dir, "dump-*.bin")
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	dumpFile.Close()
+	err = os.Rename(f.Name(), dumpFile.Name())
+	if err != nil {
+		os.Remove(f.Name())
+		os.Remove(dumpFile.Name())
+		return "", err
+	}
+	return dumpFile.Name(), nil
+}
+
+// removeCaptureFiles removes the capture files after all validations finished.
+func (cr *corruptionRecorder) removeCaptureFiles() {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	for _, f := range cr.captureFiles {
+		f.Close()
+		os.Remove(f.Name())
+	}
+	cr.captureFiles = nil
+}
+
+// capture is the data read for a validation, which is copied to a capture file
+// so that the data which failed the validation can be dumped exactly as it was read.
+type capture struct {
+	io.Reader
+	cr *corruptionRecorder
+	// f is nil if the data is not copied.
+	f *os.File
+}
+
+// captureBody returns the capture of the data read from `body`.
+// The data is not copied if the validation failures are not recorded.
+// The capture should be released after the validation unless it is kept.
+func (w *Worker) captureBody(body io.Reader) *capture {
+	c := &capture{Reader: body}
+	if w.corruptions == nil {
+		return c
+	}
+	f, err := w.corruptions.captureFile()
+	if err != nil {
+		w.logger.Error("Failed to create the capture file.", "err", err)
+		return c
+	}
+	c.Reader = io.TeeReader(body, f)
+	c.cr = w.corruptions
+	c.f = f
+	return c
+}
+
+// keepCapture reads the rest of the data of `c` and saves the whole data read from the body to a new dump file.
+// It returns the name of the dump file, or an empty string if the data was not copied.
+func (w *Worker) keepCapture(c *capture) string {
+	if c.f == nil {
+		return ""
+	}
+	// The failure may be found before the end of the data.
+	_, err := io.Copy(io.Discard, c.Reader)
+	if err != nil {
+		w.logger.Warn("Failed to read the rest of the data to dump.", "err", err)
+	}
+	f := c.f
+	c.f = nil
+	dumpFile, err := c.cr.keepCaptureFile(f)
+	if err != nil {
+		w.logger.Error("Failed to keep the captured data.", "err", err)
+		return ""
+	}
+	return dumpFile
+}
+
+// release makes the capture file available for the next validation.
+func (c *capture) release() {
+	if c.f == nil {
+		return
+	}
+	c.cr.releaseCaptureFile(c.f)
+	c.f = nil
+}
+
+// RecordCorruptions makes the workers record the validation failures of the data of the objects
+// to the directory `dir`. If `reread` is true, the object is also read again and dumped after each failure.
+func (r *Runner) RecordCorruptions(dir string, reread bool) error {
+	cr, err := newCorruptionRecorder(dir, reread)
+	if err != nil {
+		return err
+	}
+	r.corruptions = cr
+	for i := range r.execContext.Workers {
+		r.execContext.Workers[i].corruptions = cr
+	}
+	return nil
+}
+
+// recordCorruption records the error `err` of the operation `opeName` if it is a validation failure
+// of the data, the checksum or the metadata of an object, and returns the analysis of the data which failed it.
+// The failures of the recording are only logged, and nil is returned if the data could not be analyzed.
+func (w *Worker) recordCorruption(ctx context.Context, opeName string, err error) *pattern.Analysis {
+	if w.corruptions == nil {
+		return nil
+	}
+	var objErr *objectError
+	if !errors.As(err, &objErr) || objErr.key == "" ||
+		(objErr.class != FindingCorrupted && objErr.class != FindingStale) {
+		return nil
+	}
+	rec := &CorruptionRecord{
+		Time:       time.Now(),
+		Class:      objErr.class,
+		Operation:  opeName,
+		Phase:      objErr.phase,
+		WorkerID:   w.id,
+		BucketName: objErr.bucketName,
+		Key:        objErr.key,
+		VersionID:  objErr.versionID,
+		Expected:   objErr.expected,
+		Actual:     objErr.actual,
+		Message:    err.Error(),
+	}
+	var duErr *pattern.DataUnitError
+	if errors.As(err, &duErr) {
+		rec.DataUnit = &DataUnitRecord{
+			Offset:     duErr.Offset,
+			Mismatches: duErr.Mismatches,
+			Expected:   duErr.Expected,
+			Actual:     duErr.Actual,
+		}
+	}
+
+	rec.DumpFile = objErr.dumpFile
+	rec.DumpRange = objErr.dumpRange
+	if rec.DumpFile != "" && rec.DumpRange == "" {
+		var analysisErr error
+		rec.Analysis, analysisErr = w.analyzeDumpFile(rec.DumpFile, objErr.bucketName, &objErr.data)
+		if analysisErr != nil {
+			w.logger.Error("Failed to analyze the dumped data.", "err", analysisErr)
+		}
+	}
+	if w.corruptions.reread {
+		rereadDumpFile, dumpErr := w.dumpObject(ctx, objErr.bucketName, objErr.key, objErr.versionID)
+		if dumpErr != nil {
+			w.logger.Error("Failed to dump the object read again.", "err", dumpErr)
+		} else {
+			rec.RereadDumpFile = rereadDumpFile
+		}
+	}
+	recErr := w.corruptions.record(rec)
+	if recErr != nil {
+		w.logger.Error("Failed to record the corruption.", "err", recErr)
+		return rec.Analysis
+	}
+	w.logger.Info("Recorded the corruption.", "bucket", rec.BucketName, "key", rec.Key,
+		"dumpFile", rec.DumpFile, "rereadDumpFile", rec.RereadDumpFile)
+	if rec.Analysis != nil {
+		w.logger.Info(rec.Analysis.String())
+	}
+	return rec.Analysis
+}
+
+// analyzeDumpFile classifies the data in the dump file `dumpFile` against the expected data of `obj`.
+func (w *Worker) analyzeDumpFile(dumpFile, bucketName string, obj *object.Object) (*pattern.Analysis, error) {
+	f, err := os.Open(dumpFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return pattern.Analyze(w.id, bucketName, obj, f)
+}
+
+// dumpObject reads the object again and writes the whole data to a dump file.
+// The data may differ from the one which failed the validation.
+func (w *Worker) dumpObject(ctx context.Context, bucketName, key, versionID string) (string, error) {
+	var body io.ReadCloser
+	var err error
+	if versionID != "" {
+		body, err = w.client.GetObjectVersion(ctx, bucketName, key, versionID)
+	} else {
+		body, err = w.client.GetObject(ctx, bucketName, key)
+	}
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	return w.corruptions.dump(body)
+}
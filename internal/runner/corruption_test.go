@@ -0,0 +1,160 @@
+package runner
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/peng225/oval/internal/object"
+	"github.com/peng225/oval/internal/pattern"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCorruptionRecorder(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "corruptions")
+	cr, err := newCorruptionRecorder(dir, false)
+	require.NoError(t, err)
+
+	obj := &object.Object{
+		Key:        "ov0000000001",
+		Size:       2 * pattern.DataUnitSize,
+		WriteCount: 3,
+	}
+	data, err := pattern.Generate(obj.Size, 1, "bucket", obj)
+	require.NoError(t, err)
+	dumpFile, err := cr.dump(strings.NewReader(string(data)))
+	require.NoError(t, err)
+	assert.Equal(t, dir, filepath.Dir(dumpFile))
+	dumped, err := os.ReadFile(dumpFile)
+	require.NoError(t, err)
+	assert.Equal(t, data, dumped)
+	w := &Worker{id: 1}
+	analysis, err := w.analyzeDumpFile(dumpFile, "bucket", obj)
+	require.NoError(t, err)
+	assert.Equal(t, pattern.AnalysisValid, analysis.Class)
+	obj.WriteCount++
+	analysis, err = w.analyzeDumpFile(dumpFile, "bucket", obj)
+	require.NoError(t, err)
+	assert.Equal(t, pattern.AnalysisStaleGeneration, analysis.Class)
+
+	require.NoError(t, cr.record(&CorruptionRecord{Class: FindingCorrupted, Key: "ov0000000001", DumpFile: dumpFile}))
+	require.NoError(t, cr.record(&CorruptionRecord{Class: FindingStale, Key: "ov0000000002"}))
+	f, err := os.Open(filepath.Join(dir, corruptionLogFileName))
+	require.NoError(t, err)
+	defer f.Close()
+	records := make([]CorruptionRecord, 0)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		rec := CorruptionRecord{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &rec))
+		records = append(records, rec)
+	}
+	require.NoError(t, scanner.Err())
+	require.Len(t, records, 2)
+	assert.Equal(t, FindingCorrupted, records[0].Class)
+	assert.Equal(t, dumpFile, records[0].DumpFile)
+	assert.Equal(t, "ov0000000002", records[1].Key)
+	assert.Nil(t, records[1].DataUnit)
+}
+
+func TestCapture(t *testing.T) {
+	dir := t.TempDir()
+	cr, err := newCorruptionRecorder(dir, false)
+	require.NoError(t, err)
+	w := &Worker{id: 1, corruptions: cr, logger: slog.Default()}
+
+	obj := &object.Object{
+		Key:        "ov0000000001",
+		Size:       4 * pattern.DataUnitSize,
+		WriteCount: 3,
+	}
+	data, err := pattern.Generate(obj.Size, 1, "bucket", obj)
+	require.NoError(t, err)
+
+	// The capture file is reused after the successful validation.
+	c := w.captureBody(strings.NewReader(string(data)))
+	require.NoError(t, pattern.Valid(1, "bucket", obj, c))
+	captureFileName := c.f.Name()
+	c.release()
+	c = w.captureBody(strings.NewReader(string(data)))
+	assert.Equal(t, captureFileName, c.f.Name())
+	info, err := c.f.Stat()
+	require.NoError(t, err)
+	assert.Zero(t, info.Size())
+	c.release()
+
+	// The data which failed the validation is dumped as it was read, including the rest of it.
+	data[pattern.DataUnitSize+object.MaxBucketNameLength+object.MaxKeyLength]--
+	c = w.captureBody(strings.NewReader(string(data)))
+	require.Error(t, pattern.Valid(1, "bucket", obj, c))
+	dumpFile := w.keepCapture(c)
+	assert.Equal(t, dir, filepath.Dir(dumpFile))
+	dumped, err := os.ReadFile(dumpFile)
+	require.NoError(t, err)
+	assert.Equal(t, data, dumped)
+	c.release()
+
+	objErr := newObjectError(FindingCorrupted, "get", "bucket", obj, objectState(obj), "data mismatch", io.ErrUnexpectedEOF)
+	objErr.dumpFile = dumpFile
+	assert.NotNil(t, w.recordCorruption(t.Context(), "get", objErr))
+	// A part of the object is not analyzed.
+	objErr.dumpRange = "bytes=0-1"
+	assert.Nil(t, w.recordCorruption(t.Context(), "range-get", objErr))
+
+	cr.removeCaptureFiles()
+	captureFiles, err := filepath.Glob(filepath.Join(dir, "capture-*"))
+	require.NoError(t, err)
+	assert.Empty(t, captureFiles)
+	_, err = os.Stat(dumpFile)
+	assert.NoError(t, err)
+
+	// The data is not copied if the validation failures are not recorded.
+	w.corruptions = nil
+	c = w.captureBody(strings.NewReader(string(data)))
+	assert.Empty(t, w.keepCapture(c))
+}
+
+func TestDataUnitRecordJSON(t *testing.T) {
+	obj := &object.Object{
+		Key:        "ov0000000001",
+		Size:       2 * pattern.DataUnitSize,
+		WriteCount: 3,
+	}
+	data, err := pattern.Generate(obj.Size, 1, "bucket", obj)
+	require.NoError(t, err)
+	// Make the second data unit look like the one of an older write.
+	data[pattern.DataUnitSize+object.MaxBucketNameLength+object.MaxKeyLength]--
+	validErr := pattern.Valid(1, "bucket", obj, strings.NewReader(string(data)))
+	require.Error(t, validErr)
+
+	w := &Worker{id: 1, corruptions: &corruptionRecorder{dir: t.TempDir()}}
+	// The errors other than the corruptions are not recorded, so the client is never used.
+	w.recordCorruption(t.Context(), "get", newObjectError(FindingLost, "get", "bucket", obj, stateExists, stateNotFound, validErr))
+	_, err = os.Stat(filepath.Join(w.corruptions.dir, corruptionLogFileName))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	var duErr *pattern.DataUnitError
+	require.ErrorAs(t, validErr, &duErr)
+	out, err := json.Marshal(&DataUnitRecord{
+		Offset:     duErr.Offset,
+		Mismatches: duErr.Mismatches,
+		Expected:   duErr.Expected,
+		Actual:     duErr.Actual,
+	})
+	require.NoError(t, err)
+	rec := DataUnitRecord{}
+	require.NoError(t, json.Unmarshal(out, &rec))
+	assert.Equal(t, pattern.DataUnitSize, rec.Offset)
+	require.Len(t, rec.Mismatches, 1)
+	assert.Equal(t, "writeCount", rec.Mismatches[0].Field)
+	assert.Equal(t, "3", rec.Mismatches[0].Expected)
+	assert.Equal(t, "2", rec.Mismatches[0].Actual)
+	assert.Equal(t, 2, rec.Actual.WriteCount)
+	assert.Equal(t, "bucket", rec.Actual.BucketName)
+}
@@ -0,0 +1,215 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/peng225/oval/internal/object"
+	"github.com/peng225/oval/internal/pattern"
+)
+
+const (
+	stateExists   = "exists"
+	stateNotFound = "not found"
+)
+
+// objectError is the error about the state of an object, from which the error event is made.
+type objectError struct {
+	class      FindingClass
+	phase      string
+	bucketName string
+	key        string
+	// versionID is set if the error is about a version of the object.
+	versionID string
+	// data is the copy of the object which has the expected data.
+	data     object.Object
+	expected string
+	actual   string
+	// dumpFile is the file which has the data which failed the validation exactly as it was read.
+	dumpFile string
+	// dumpRange is the HTTP range of the data in dumpFile if only a part of the object was read.
+	dumpRange string
+	// quarantineKeys is the list of the keys in `bucketName` which should be quarantined in addition to `key`.
+	quarantineKeys []string
+	err            error
+}
+
+// newObjectError returns the error of the class `class` about `obj` found at `phase`.
+// `phase` is like "before-put", "after-delete" or "get".
+func newObjectError(class FindingClass, phase, bucketName string, obj *object.Object,
+	expected, actual string, err error) *objectError {
+	return &objectError{
+		class:      class,
+		phase:      phase,
+		bucketName: bucketName,
+		key:        obj.Key,
+		data:       *obj,
+		expected:   expected,
+		actual:     actual,
+		err:        err,
+	}
+}
+
+// withVersion sets the version which the error is about.
+// `version` is the object which has the expected data of the version.
+func (e *objectError) withVersion(versionID string, version *object.Object) *objectError {
+	e.versionID = versionID
+	e.data = *version
+	return e
+}
+
+func (e *objectError) Error() string {
+	return e.err.Error()
+}
+
+func (e *objectError) Unwrap() error {
+	return e.err
+}
+
+// phaseName converts the description of the timing like "before put" to the phase name like "before-put".
+func phaseName(timing string) string {
+	return strings.ReplaceAll(timing, " ", "-")
+}
+
+// objectState describes the expected state of the existing object.
+func objectState(obj *object.Object) string {
+	return fmt.Sprintf("%s (size = %d, writeCount = %d)", stateExists, obj.Size, obj.WriteCount)
+}
+
+// ErrorEvent is the record of an error which occurred during the workload.
+type ErrorEvent struct {
+	Class      FindingClass `json:"class"`
+	Operation  string       `json:"operation"`
+	Phase      string       `json:"phase,omitempty"`
+	WorkerID   int          `json:"workerID"`
+	BucketName string       `json:"bucketName,omitempty"`
+	Key        string       `json:"key,omitempty"`
+	Expected   string       `json:"expected,omitempty"`
+	Actual     string       `json:"actual,omitempty"`
+	// Dump is the annotated hexdump of the data unit which had the unexpected contents.
+	Dump    string `json:"dump,omitempty"`
+	Message string `json:"message"`
+	// Quarantined is the map from the bucket names to the keys quarantined due to the error.
+	Quarantined map[string][]string `json:"quarantined,omitempty"`
+}
+
+// newErrorEvent makes the error event from the error `err` returned by the operation `opeName`.
+// The errors other than objectError are classified as FindingFailed.
+func newErrorEvent(opeName string, workerID int, err error) *ErrorEvent {
+	event := &ErrorEvent{
+		Class:     FindingFailed,
+		Operation: opeName,
+		WorkerID:  workerID,
+		Message:   err.Error(),
+	}
+	var objErr *objectError
+	if errors.As(err, &objErr) {
+		event.Class = objErr.class
+		event.Phase = objErr.phase
+		event.BucketName = objErr.bucketName
+		event.Key = objErr.key
+		event.Expected = objErr.expected
+		event.Actual = objErr.actual
+	}
+	var duErr *pattern.DataUnitError
+	if errors.As(err, &duErr) {
+		event.Dump = duErr.Dump
+	}
+	return event
+}
+
+// errorEvents is the list of the error events shared by all workers.
+type errorEvents struct {
+	mu     sync.Mutex
+	events []ErrorEvent
+}
+
+// add records `event` and returns the number of the events recorded so far.
+func (ee *errorEvents) add(event *ErrorEvent) int {
+	ee.mu.Lock()
+	defer ee.mu.Unlock()
+	ee.events = append(ee.events, *event)
+	return len(ee.events)
+}
+
+func (ee *errorEvents) report() *ErrorReport {
+	ee.mu.Lock()
+	defer ee.mu.Unlock()
+	return &ErrorReport{
+		Events: slices.Clone(ee.events),
+	}
+}
+
+// ErrorReport is the error returned by the workload which continued on the errors.
+// It holds all error events.
+type ErrorReport struct {
+	Events []ErrorEvent
+}
+
+// Error returns the list of the events grouped by the class.
+// The empty fields of the events are omitted.
+func (rep *ErrorReport) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d errors occurred.\n", len(rep.Events))
+	for _, class := range findingClasses {
+		events := make([]ErrorEvent, 0)
+		for _, e := range rep.Events {
+			if e.Class == class {
+				events = append(events, e)
+			}
+		}
+		if len(events) == 0 {
+			continue
+		}
+		fmt.Fprintf(&sb, "%s: %d\n", class, len(events))
+		for _, e := range events {
+			fields := []string{fmt.Sprintf("operation = %s", e.Operation), fmt.Sprintf("workerID = %#x", e.WorkerID)}
+			for _, f := range [][2]string{
+				{"phase", e.Phase}, {"bucket", e.BucketName}, {"key", e.Key}, {"expected", e.Expected}, {"actual", e.Actual},
+			} {
+				if f[1] != "" {
+					fields = append(fields, f[0]+" = "+f[1])
+				}
+			}
+			fmt.Fprintf(&sb, "  %s\n", strings.Join(fields, ", "))
+			for _, bucketName := range slices.Sorted(maps.Keys(e.Quarantined)) {
+				fmt.Fprintf(&sb, "    quarantined: bucket = %s, keys = %v\n", bucketName, e.Quarantined[bucketName])
+			}
+			fmt.Fprintf(&sb, "    %s\n", strings.ReplaceAll(strings.TrimRight(e.Message, "\n"), "\n", "\n    "))
+		}
+	}
+	return sb.String()
+}
+
+// quarantine excludes the objects affected by the error `err` of the last operation from the workload
+// and returns the quarantined keys. The objects used by the last operation are quarantined as well
+// unless it was read-only, because their states are unknown.
+func (w *Worker) quarantine(readOnly bool, err error) map[string][]string {
+	quarantined := make(map[string][]string)
+	var objErr *objectError
+	if errors.As(err, &objErr) {
+		for _, bucketWithObj := range w.BucketsWithObject {
+			if bucketWithObj.BucketName != objErr.bucketName {
+				continue
+			}
+			for _, key := range append([]string{objErr.key}, objErr.quarantineKeys...) {
+				if bucketWithObj.ObjectMeta.Quarantine(key) {
+					quarantined[bucketWithObj.BucketName] = append(quarantined[bucketWithObj.BucketName], key)
+				}
+			}
+		}
+	}
+	if !readOnly {
+		for _, bucketWithObj := range w.BucketsWithObject {
+			keys := bucketWithObj.ObjectMeta.QuarantineInUse()
+			if len(keys) != 0 {
+				quarantined[bucketWithObj.BucketName] = append(quarantined[bucketWithObj.BucketName], keys...)
+			}
+		}
+	}
+	return quarantined
+}
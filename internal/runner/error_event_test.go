@@ -0,0 +1,92 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/peng225/oval/internal/object"
+	"github.com/peng225/oval/internal/pattern"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewErrorEvent(t *testing.T) {
+	obj := &object.Object{
+		Key:        "ov0000000001",
+		Size:       pattern.DataUnitSize,
+		WriteCount: 2,
+	}
+	data, err := pattern.Generate(obj.Size, 1, "bucket", obj)
+	require.NoError(t, err)
+	data[pattern.DataUnitSize-1] ^= 0xff
+	validErr := pattern.Valid(1, "bucket", obj, strings.NewReader(string(data)))
+	require.Error(t, validErr)
+
+	err = newObjectError(FindingCorrupted, phaseName("before put"), "bucket", obj, objectState(obj), "data mismatch",
+		fmt.Errorf("data validation error occurred before put.\n%w", validErr))
+	event := newErrorEvent(Put.String(), 0x10, fmt.Errorf("wrapped\n%w", err))
+	assert.Equal(t, FindingCorrupted, event.Class)
+	assert.Equal(t, "put", event.Operation)
+	assert.Equal(t, "before-put", event.Phase)
+	assert.Equal(t, 0x10, event.WorkerID)
+	assert.Equal(t, "bucket", event.BucketName)
+	assert.Equal(t, obj.Key, event.Key)
+	assert.Equal(t, "exists (size = 256, writeCount = 2)", event.Expected)
+	assert.Equal(t, "data mismatch", event.Actual)
+	assert.Contains(t, event.Dump, "bucket name")
+	assert.Contains(t, event.Message, "Data body is wrong.")
+
+	event = newErrorEvent(BatchDelete.String(), 0x10, errors.New("connection refused"))
+	assert.Equal(t, FindingFailed, event.Class)
+	assert.Equal(t, "batch delete", event.Operation)
+	assert.Empty(t, event.Key)
+}
+
+func TestErrorReport(t *testing.T) {
+	ee := &errorEvents{}
+	assert.Equal(t, 1, ee.add(&ErrorEvent{Class: FindingFailed, Operation: "put", Message: "timeout"}))
+	assert.Equal(t, 2, ee.add(&ErrorEvent{
+		Class: FindingLost, Operation: "get", Phase: "get", WorkerID: 1, BucketName: "bucket", Key: "ov0000000001",
+		Expected: stateExists, Actual: stateNotFound, Message: "lost\nobj",
+		Quarantined: map[string][]string{"bucket": {"ov0000000001"}},
+	}))
+	report := ee.report()
+	assert.Equal(t, `2 errors occurred.
+lost: 1
+  operation = get, workerID = 0x1, phase = get, bucket = bucket, key = ov0000000001, expected = exists, actual = not found
+    quarantined: bucket = bucket, keys = [ov0000000001]
+    lost
+    obj
+failed: 1
+  operation = put, workerID = 0x0
+    timeout
+`, report.Error())
+}
+
+func TestQuarantine(t *testing.T) {
+	ec := newTestExecutionContext()
+	w := &ec.Workers[0]
+	om := w.BucketsWithObject[0].ObjectMeta
+	obj := om.GetObjectByID(3)
+	other := om.GetObjectByID(4)
+
+	// The objects used by the read-only operation are not quarantined.
+	err := newObjectError(FindingLost, "get", "test-bucket", obj, objectState(obj), stateNotFound, errors.New("lost"))
+	assert.Equal(t, map[string][]string{"test-bucket": {obj.Key}}, w.quarantine(true, err))
+	assert.False(t, om.IsQuarantined(other.Key))
+
+	// All objects used by the write operation are quarantined.
+	assert.Equal(t, map[string][]string{"test-bucket": {other.Key}}, w.quarantine(false, errors.New("timeout")))
+	w.releaseObjects()
+	assert.True(t, om.IsQuarantined(obj.Key))
+	assert.True(t, om.IsQuarantined(other.Key))
+	assert.False(t, om.Exist(obj.Key))
+}
+
+func TestOperationString(t *testing.T) {
+	assert.Equal(t, "put", Put.String())
+	assert.Equal(t, "batch delete", BatchDelete.String())
+	assert.Equal(t, "Operation(13)", NumOperation.String())
+}
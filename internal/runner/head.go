@@ -0,0 +1,67 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/peng225/oval/internal/s3client"
+)
+
+// Head checks that the information of a random object returned by HeadObject
+// matches the expected state of the object.
+func (w *Worker) Head(ctx context.Context) error {
+	bucketWithObj := w.selectBucketWithObject()
+	obj := bucketWithObj.ObjectMeta.GetRandomObject()
+	if obj == nil {
+		return nil
+	}
+
+	info, err := w.client.HeadObject(ctx, bucketWithObj.BucketName, obj.Key)
+	if err != nil {
+		if !errors.Is(err, s3client.ErrNoSuchKey) {
+			w.logger.Error(err.Error())
+			return err
+		}
+		if bucketWithObj.ObjectMeta.Exist(obj.Key) {
+			err = newObjectError(FindingLost, "head", bucketWithObj.BucketName, obj, objectState(obj), stateNotFound,
+				fmt.Errorf("object lost at head.\nerr: %w\nobj: %v", err, obj))
+			w.logger.Error(err.Error())
+			return err
+		}
+		w.st.AddHeadCount()
+		return nil
+	}
+	if !bucketWithObj.ObjectMeta.Exist(obj.Key) {
+		err = newObjectError(FindingUnexpected, "head", bucketWithObj.BucketName, obj, stateNotFound, stateExists,
+			fmt.Errorf("an unexpected object was found at head. (key = %s)", obj.Key))
+		w.logger.Error(err.Error())
+		return err
+	}
+
+	errMsg := ""
+	if info.Size != int64(obj.Size) {
+		errMsg += fmt.Sprintf("- Size is wrong. (expected = %d, actual = %d)\n", obj.Size, info.Size)
+	}
+	if obj.ETag != "" && info.ETag != obj.ETag {
+		errMsg += fmt.Sprintf("- ETag is wrong. (expected = %s, actual = %s)\n", obj.ETag, info.ETag)
+	}
+	lastModified := info.LastModified.Unix()
+	if lastModified < obj.LastModified {
+		errMsg += fmt.Sprintf("- Last modified time went backward. (previous = %s, actual = %s)\n",
+			time.Unix(obj.LastModified, 0).UTC(), info.LastModified.UTC())
+	}
+	// The tags are not checked because HeadObject may not return the tag count.
+	errMsg += validMetadataHeaders(obj, &info.MetadataHeaders)
+	if errMsg != "" {
+		err = newObjectError(FindingInconsistent, "head", bucketWithObj.BucketName, obj, objectState(obj),
+			fmt.Sprintf("%s (size = %d, etag = %s)", stateExists, info.Size, info.ETag),
+			fmt.Errorf("head validation error occurred.\n%sobj: %v", errMsg, obj))
+		w.logger.Error(err.Error())
+		return err
+	}
+	obj.LastModified = lastModified
+	w.st.AddHeadCount()
+	return nil
+}
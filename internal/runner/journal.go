@@ -0,0 +1,234 @@
+package runner
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+const (
+	// The journal is the file next to the context file to which the keys used by the mutating operations
+	// are appended before the operations are issued.
+	journalFileSuffix = ".journal"
+	// The journal is moved to this file while a checkpoint is taken, and removed when the checkpoint is saved.
+	oldJournalFileSuffix = ".journal.old"
+)
+
+// journalEntry is the record of an object which may be modified after the last checkpoint.
+type journalEntry struct {
+	WorkerIndex int    `json:"workerIndex"`
+	BucketName  string `json:"bucketName"`
+	Key         string `json:"key"`
+}
+
+// journal records the objects used by the mutating operations since the last checkpoint,
+// so that the objects written after it are not regarded as corrupted when the context file is loaded.
+type journal struct {
+	mu       sync.Mutex
+	fileName string
+	f        *os.File
+}
+
+// openJournal opens the journal of the context file `contextFileName`.
+// The existing entries are kept because they are still needed until the next checkpoint is saved.
+func openJournal(contextFileName string) (*journal, error) {
+	j := &journal{
+		fileName: contextFileName + journalFileSuffix,
+	}
+	err := j.open()
+	if err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+func (j *journal) open() error {
+	f, err := os.OpenFile(j.fileName, os.O_WRONLY|os.O_APPEND|os.O_CREATE, contextFilePermission)
+	if err != nil {
+		return err
+	}
+	j.f = f
+	return nil
+}
+
+// record appends `entry` to the journal and flushes it to the storage device,
+// so that the entry survives the power loss as well as the kill of the process.
+func (j *journal) record(entry *journalEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	_, err = j.f.Write(append(data, '\n'))
+	if err != nil {
+		return err
+	}
+	return j.f.Sync()
+}
+
+// rotate moves the entries recorded so far to the old journal before a checkpoint is taken.
+// The old journal is appended to if the previous checkpoint failed.
+func (j *journal) rotate() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	err := j.f.Close()
+	if err != nil {
+		return err
+	}
+	oldFileName := j.oldFileName()
+	_, err = os.Stat(oldFileName)
+	if errors.Is(err, os.ErrNotExist) {
+		err = os.Rename(j.fileName, oldFileName)
+	} else if err == nil {
+		err = appendFile(oldFileName, j.fileName)
+	}
+	if err != nil {
+		return err
+	}
+	return j.open()
+}
+
+// commit removes the old journal after the checkpoint is saved.
+func (j *journal) commit() error {
+	err := os.Remove(j.oldFileName())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// remove closes and removes the journal when no operation follows the last checkpoint.
+func (j *journal) remove() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	err := j.f.Close()
+	if err != nil {
+		return err
+	}
+	err = os.Remove(j.fileName)
+	if err != nil {
+		return err
+	}
+	return j.commit()
+}
+
+func (j *journal) oldFileName() string {
+	return j.fileName[:len(j.fileName)-len(journalFileSuffix)] + oldJournalFileSuffix
+}
+
+// appendFile appends the contents of the file `src` to the file `dst` and removes `src`.
+func appendFile(dst, src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_APPEND, contextFilePermission)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if err == nil {
+		// The entries must be durable before `src` is removed.
+		err = out.Sync()
+	}
+	if err != nil {
+		out.Close()
+		return err
+	}
+	err = out.Close()
+	if err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
+// readJournal returns the entries of the journals of the context file `contextFileName`.
+// It returns no entries if there are no journals.
+func readJournal(contextFileName string) ([]journalEntry, error) {
+	entries := make([]journalEntry, 0)
+	for _, fileName := range []string{contextFileName + oldJournalFileSuffix, contextFileName + journalFileSuffix} {
+		f, err := os.Open(fileName)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			entry := journalEntry{}
+			err = json.Unmarshal(scanner.Bytes(), &entry)
+			if err != nil {
+				// The last entry may be torn by the crash.
+				break
+			}
+			entries = append(entries, entry)
+		}
+		f.Close()
+		if scanner.Err() != nil {
+			return nil, scanner.Err()
+		}
+	}
+	return entries, nil
+}
+
+// applyJournal marks the objects recorded in the journals of the context file `contextFileName` as uncertain,
+// because the operations on them after the checkpoint may or may not have taken effect.
+func applyJournal(ec *ExecutionContext, contextFileName string) error {
+	entries, err := readJournal(contextFileName)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.WorkerIndex < 0 || len(ec.Workers) <= entry.WorkerIndex {
+			return fmt.Errorf("invalid worker index in the journal: %d", entry.WorkerIndex)
+		}
+		found := false
+		for _, bucketWithObj := range ec.Workers[entry.WorkerIndex].BucketsWithObject {
+			if bucketWithObj.BucketName != entry.BucketName {
+				continue
+			}
+			found = bucketWithObj.ObjectMeta.MarkUncertainKey(entry.Key)
+			bucketWithObj.ObjectMeta.Release()
+		}
+		if !found {
+			return fmt.Errorf("unknown object in the journal. (bucket = %s, key = %s)", entry.BucketName, entry.Key)
+		}
+	}
+	return nil
+}
+
+// startJournal makes the workers record the objects used by the mutating operations to the journal.
+func (r *Runner) startJournal() error {
+	j, err := openJournal(r.saveFileName)
+	if err != nil {
+		return err
+	}
+	r.journal = j
+	for i := range r.execContext.Workers {
+		w := &r.execContext.Workers[i]
+		for _, bucketWithObj := range w.BucketsWithObject {
+			bucketName := bucketWithObj.BucketName
+			bucketWithObj.ObjectMeta.SetUseHook(func(key string) {
+				if !w.mutating {
+					return
+				}
+				err := j.record(&journalEntry{
+					WorkerIndex: i,
+					BucketName:  bucketName,
+					Key:         key,
+				})
+				if err != nil {
+					w.logger.Error("Failed to record the journal entry.", "err", err)
+				}
+			})
+		}
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"slices"
+	"strings"
+
+	"github.com/peng225/oval/internal/object"
+	"github.com/peng225/oval/internal/s3client"
+)
+
+const (
+	// The maximum number of the entries per page of the LIST operation, which is the limit of S3.
+	maxListPageSize = 1000
+	// The maximum page size of the LIST operations with the tiny pages.
+	// They exercise the pagination boundaries at the cost of many requests, so only some LIST operations use them.
+	maxTinyListPageSize = 8
+	// One in this number of the LIST operations uses the tiny pages.
+	tinyListPageRatio = 8
+	// The candidates of the delimiter.
+	// The hex digits roll up some of the flat keys into the common prefixes.
+	listDelimiters = "0123456789abcdef/"
+)
+
+// randomListOptions returns the options of the LIST operation of the keys of `bucketWithObj`
+// with a random page size, API version and either a random start-after key or a random delimiter.
+func randomListOptions(bucketWithObj *BucketWithObject) *s3client.ListOptions {
+	opts := &s3client.ListOptions{
+		Prefix:  bucketWithObj.ObjectMeta.KeyPrefix,
+		MaxKeys: int32(1 + rand.Intn(maxListPageSize)),
+		UseV1:   rand.Intn(2) == 0,
+	}
+	if rand.Intn(tinyListPageRatio) == 0 {
+		opts.MaxKeys = int32(1 + rand.Intn(maxTinyListPageSize))
+	}
+	switch rand.Intn(3) {
+	case 1:
+		if obj := bucketWithObj.ObjectMeta.GetRandomObject(); obj != nil {
+			opts.StartAfter = obj.Key
+		}
+	case 2:
+		// The quarantined keys, which may or may not exist, could be rolled up into the common prefixes.
+		if len(bucketWithObj.ObjectMeta.QuarantinedObjects()) == 0 {
+			opts.Delimiter = string(listDelimiters[rand.Intn(len(listDelimiters))])
+		}
+	}
+	return opts
+}
+
+// expectedListResult returns the objects and the common prefixes which should be listed with `opts`
+// in the lexicographic order.
+func expectedListResult(om *object.ObjectMeta, opts *s3client.ListOptions) ([]object.ObjectSummary, []string) {
+	existing := om.ExistingObjectSummaries()
+
+	objs := make([]object.ObjectSummary, 0, len(existing))
+	commonPrefixes := make([]string, 0)
+	for _, obj := range existing {
+		if !strings.HasPrefix(obj.Key, opts.Prefix) || obj.Key <= opts.StartAfter {
+			continue
+		}
+		if opts.Delimiter != "" {
+			rest := obj.Key[len(opts.Prefix):]
+			if i := strings.Index(rest, opts.Delimiter); i >= 0 {
+				cp := opts.Prefix + rest[:i+len(opts.Delimiter)]
+				if len(commonPrefixes) == 0 || commonPrefixes[len(commonPrefixes)-1] != cp {
+					commonPrefixes = append(commonPrefixes, cp)
+				}
+				continue
+			}
+		}
+		objs = append(objs, obj)
+	}
+	return objs, commonPrefixes
+}
+
+// validList lists the keys of `bucketWithObj` with random options and checks that
+// the result matches the existing objects in the lexicographic order.
+func (w *Worker) validList(ctx context.Context, bucketWithObj *BucketWithObject) error {
+	opts := randomListOptions(bucketWithObj)
+	res, err := w.client.ListObjectsWithOptions(ctx, bucketWithObj.BucketName, opts)
+	if err != nil {
+		w.logger.Error(err.Error())
+		return err
+	}
+
+	errMsg := ""
+	for i, pageSize := range res.PageSizes {
+		if pageSize > int(opts.MaxKeys) {
+			errMsg += fmt.Sprintf("- Page %d has too many entries. (max = %d, actual = %d)\n", i, opts.MaxKeys, pageSize)
+		}
+	}
+	// The quarantined objects may be in any state.
+	res.Objects = slices.DeleteFunc(res.Objects, func(o s3client.ListedObject) bool {
+		return bucketWithObj.ObjectMeta.IsQuarantined(o.Key)
+	})
+	actualKeys := make([]string, 0, len(res.Objects))
+	for _, o := range res.Objects {
+		actualKeys = append(actualKeys, o.Key)
+	}
+	if !isStrictlySorted(actualKeys) {
+		errMsg += fmt.Sprintf("- Keys are not in the lexicographic order. (keys = %v)\n", actualKeys)
+	}
+	if !isStrictlySorted(res.CommonPrefixes) {
+		errMsg += fmt.Sprintf("- Common prefixes are not in the lexicographic order. (commonPrefixes = %v)\n", res.CommonPrefixes)
+	}
+
+	expectedObjs, expectedCommonPrefixes := expectedListResult(bucketWithObj.ObjectMeta, opts)
+	expectedKeys := make([]string, 0, len(expectedObjs))
+	for _, obj := range expectedObjs {
+		expectedKeys = append(expectedKeys, obj.Key)
+	}
+	if !slices.Equal(expectedKeys, actualKeys) {
+		errMsg += fmt.Sprintf("- Keys are wrong. (expected = %v, actual = %v)\n", expectedKeys, actualKeys)
+	} else {
+		for i, o := range res.Objects {
+			obj := expectedObjs[i]
+			if o.Size != int64(obj.Size) {
+				errMsg += fmt.Sprintf("- Size is wrong. (key = %s, expected = %d, actual = %d)\n", o.Key, obj.Size, o.Size)
+			}
+			if obj.ETag != "" && o.ETag != obj.ETag {
+				errMsg += fmt.Sprintf("- ETag is wrong. (key = %s, expected = %s, actual = %s)\n", o.Key, obj.ETag, o.ETag)
+			}
+		}
+	}
+	if !slices.Equal(expectedCommonPrefixes, res.CommonPrefixes) {
+		errMsg += fmt.Sprintf("- Common prefixes are wrong. (expected = %v, actual = %v)\n",
+			expectedCommonPrefixes, res.CommonPrefixes)
+	}
+
+	if errMsg != "" {
+		err = &objectError{
+			class:      FindingInconsistent,
+			phase:      "list",
+			bucketName: bucketWithObj.BucketName,
+			expected:   fmt.Sprintf("%d keys", len(expectedKeys)),
+			actual:     fmt.Sprintf("%d keys", len(actualKeys)),
+			// The keys listed or not listed unexpectedly.
+			quarantineKeys: symmetricDifference(expectedKeys, actualKeys),
+			err:            fmt.Errorf("invalid result of the LIST operation. (options = %+v, workerID = %#x)\n%s", *opts, w.id, errMsg),
+		}
+		w.logger.Error(err.Error())
+		return err
+	}
+	return nil
+}
+
+// symmetricDifference returns the elements which are contained in only one of `a` and `b`.
+func symmetricDifference(a, b []string) []string {
+	diff := make([]string, 0)
+	for _, e := range a {
+		if !slices.Contains(b, e) {
+			diff = append(diff, e)
+		}
+	}
+	for _, e := range b {
+		if !slices.Contains(a, e) {
+			diff = append(diff, e)
+		}
+	}
+	return diff
+}
+
+func isStrictlySorted(s []string) bool {
+	for i := 1; i < len(s); i++ {
+		if s[i-1] >= s[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,98 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strconv"
+	"strings"
+
+	"github.com/peng225/oval/internal/object"
+	"github.com/peng225/oval/internal/s3client"
+)
+
+const (
+	// The prefix of the user metadata keys and the tag keys attached by Oval.
+	metadataKeyPrefix = "oval-"
+)
+
+// objectMetadata returns the user metadata, the tags and the content type
+// which encode the write identified by `md`.
+func objectMetadata(md *object.Metadata) *s3client.ObjectMetadata {
+	values := map[string]string{
+		metadataKeyPrefix + "bucket":      md.BucketName,
+		metadataKeyPrefix + "key":         md.Key,
+		metadataKeyPrefix + "write-count": strconv.Itoa(md.WriteCount),
+		metadataKeyPrefix + "worker-id":   strconv.Itoa(md.WorkerID),
+	}
+	return &s3client.ObjectMetadata{
+		ContentType: fmt.Sprintf("application/x-oval; bucket=%s; key=%s; write-count=%d; worker-id=%d",
+			md.BucketName, md.Key, md.WriteCount, md.WorkerID),
+		UserMetadata: values,
+		Tags:         maps.Clone(values),
+	}
+}
+
+// validMetadataHeaders checks that the content type and the user metadata returned with the object
+// match the ones attached at write, and returns the description of the mismatches.
+func validMetadataHeaders(obj *object.Object, headers *s3client.MetadataHeaders) string {
+	errMsg := ""
+	if obj.Metadata == nil {
+		for k, v := range headers.UserMetadata {
+			if strings.HasPrefix(k, metadataKeyPrefix) {
+				errMsg += fmt.Sprintf("- Unexpected user metadata was found. (key = %s, value = %s)\n", k, v)
+			}
+		}
+		return errMsg
+	}
+
+	expected := objectMetadata(obj.Metadata)
+	if headers.ContentType != expected.ContentType {
+		errMsg += fmt.Sprintf("- Content type is wrong. (expected = \"%s\", actual = \"%s\")\n",
+			expected.ContentType, headers.ContentType)
+	}
+	for k, v := range expected.UserMetadata {
+		if headers.UserMetadata[k] != v {
+			errMsg += fmt.Sprintf("- User metadata is wrong. (key = %s, expected = \"%s\", actual = \"%s\")\n",
+				k, v, headers.UserMetadata[k])
+		}
+	}
+	return errMsg
+}
+
+// validMetadata checks that the user metadata, the tags and the content type of the object
+// match the ones attached at write, and returns the description of the mismatches.
+// If `versionID` is not empty, the tags of the version are checked.
+// The error is returned only if the tags could not be read,
+// and it is errCanceled if the read was interrupted by the context cancellation.
+func (w *Worker) validMetadata(ctx context.Context, bucketName string, obj *object.Object, versionID string,
+	body *s3client.ObjectReader) (string, error) {
+	errMsg := validMetadataHeaders(obj, &body.MetadataHeaders)
+	if obj.Metadata == nil {
+		if body.TagCount != 0 {
+			errMsg += fmt.Sprintf("- Unexpected tags were found. (count = %d)\n", body.TagCount)
+		}
+		return strings.TrimSuffix(errMsg, "\n"), nil
+	}
+
+	expected := objectMetadata(obj.Metadata)
+	if body.TagCount != len(expected.Tags) {
+		errMsg += fmt.Sprintf("- Tag count is wrong. (expected = %d, actual = %d)\n", len(expected.Tags), body.TagCount)
+	}
+	tags, err := w.client.GetObjectTagging(ctx, bucketName, obj.Key, versionID)
+	if err != nil {
+		if ctx.Err() == context.Canceled {
+			w.logger.Warn("Detected the canceled context.")
+			return "", errCanceled
+		}
+		return "", fmt.Errorf("GetObjectTagging failed during the metadata validation. (bucket = %s, key = %s, versionID = %s)\n%w",
+			bucketName, obj.Key, versionID, err)
+	}
+	if !maps.Equal(expected.Tags, tags) {
+		errMsg += fmt.Sprintf("- Tags are wrong. (expected = %v, actual = %v)\n", expected.Tags, tags)
+	}
+	if errMsg != "" {
+		return fmt.Sprintf("%s(expected writer = %+v)", errMsg, *obj.Metadata), nil
+	}
+	return "", nil
+}
@@ -0,0 +1,30 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/peng225/oval/internal/object"
+	"github.com/peng225/oval/internal/s3client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidMetadataHeaders(t *testing.T) {
+	obj := &object.Object{Key: "ov0000000001", WriteCount: 2}
+	headers := &s3client.MetadataHeaders{}
+	assert.Empty(t, validMetadataHeaders(obj, headers))
+
+	obj.Metadata = &object.Metadata{BucketName: "bucket", Key: obj.Key, WriteCount: 2, WorkerID: 1}
+	expected := objectMetadata(obj.Metadata)
+	headers.ContentType = expected.ContentType
+	headers.UserMetadata = expected.UserMetadata
+	assert.Empty(t, validMetadataHeaders(obj, headers))
+
+	// The metadata of an older write is found.
+	obj.Metadata = &object.Metadata{BucketName: "bucket", Key: obj.Key, WriteCount: 3, WorkerID: 1}
+	errMsg := validMetadataHeaders(obj, headers)
+	assert.Contains(t, errMsg, "Content type is wrong.")
+	assert.Contains(t, errMsg, "key = oval-write-count")
+
+	obj.Metadata = nil
+	assert.Contains(t, validMetadataHeaders(obj, headers), "Unexpected user metadata was found.")
+}
@@ -2,10 +2,8 @@ package runner
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"log"
 	"log/slog"
 	"math/rand"
@@ -24,14 +22,22 @@ const (
 )
 
 type ExecutionContext struct {
-	Endpoint      string   `json:"endpoint"`
-	BucketNames   []string `json:"bucketNames"`
-	NumObj        int      `json:"numObj"`
-	NumWorker     int      `json:"numWorker"`
-	MinSize       int      `json:"minSize"`
-	MaxSize       int      `json:"maxSize"`
-	StartWorkerID int      `json:"startWorkerID"`
-	Workers       []Worker `json:"workers"`
+	Endpoint    string   `json:"endpoint"`
+	BucketNames []string `json:"bucketNames"`
+	NumObj      int      `json:"numObj"`
+	NumWorker   int      `json:"numWorker"`
+	MinSize     int      `json:"minSize"`
+	MaxSize     int      `json:"maxSize"`
+	Versioning  bool     `json:"versioning"`
+	// KeyScheme decides the key names of the objects.
+	KeyScheme object.KeyScheme `json:"keyScheme,omitempty"`
+	// SharedKey enables the shared-key mode, in which all workers of all processes write the same keys.
+	SharedKey bool `json:"sharedKey,omitempty"`
+	// SharedKeyEpoch is the unix time in microseconds when the workload was configured.
+	// The data of the shared keys generated before it was written by the previous runs.
+	SharedKeyEpoch int64    `json:"sharedKeyEpoch,omitempty"`
+	StartWorkerID  int      `json:"startWorkerID"`
+	Workers        []Worker `json:"workers"`
 }
 
 type Runner struct {
@@ -43,12 +49,25 @@ type Runner struct {
 	client          *s3client.S3Client
 	st              stat.Stat
 	runnerID        int
-	multipartThresh int
+	multipartConfig s3client.MultipartConfig
+	checksumConfig  s3client.ChecksumConfig
 	caCertFileName  string
+	// saveFileName and checkpointInterval are set if the checkpoints are enabled.
+	saveFileName       string
+	checkpointInterval time.Duration
+	// journal is set while the workload runs with the checkpoints.
+	journal *journal
+	// continueOnError and maxErrors are set if the workload continues on the errors.
+	continueOnError bool
+	maxErrors       int
+	events          errorEvents
+	// corruptions is set if the validation failures are recorded.
+	corruptions *corruptionRecorder
 }
 
 func NewRunner(execContext *ExecutionContext, opeRatio []float64, timeInMs int64,
-	profiler bool, loadFileName string, processID, multipartThresh int,
+	profiler bool, loadFileName string, processID int,
+	multipartConfig s3client.MultipartConfig, checksumConfig s3client.ChecksumConfig,
 	caCertFileName string) *Runner {
 	if len(execContext.BucketNames) == 0 {
 		slog.Error("bucket list is empty.")
@@ -61,7 +80,8 @@ func NewRunner(execContext *ExecutionContext, opeRatio []float64, timeInMs int64
 		profiler:        profiler,
 		loadFileName:    loadFileName,
 		runnerID:        processID,
-		multipartThresh: multipartThresh,
+		multipartConfig: multipartConfig,
+		checksumConfig:  checksumConfig,
 		caCertFileName:  caCertFileName,
 	}
 	runner.init()
@@ -69,7 +89,8 @@ func NewRunner(execContext *ExecutionContext, opeRatio []float64, timeInMs int64
 }
 
 func NewRunnerFromLoadFile(loadFileName string, opeRatio []float64, timeInMs int64,
-	profiler bool, multipartThresh int, caCertFileName string) *Runner {
+	profiler bool, multipartConfig s3client.MultipartConfig, checksumConfig s3client.ChecksumConfig,
+	caCertFileName string) *Runner {
 	if loadFileName == "" {
 		log.Fatal("loadFileName is empty.")
 	}
@@ -79,23 +100,16 @@ func NewRunnerFromLoadFile(loadFileName string, opeRatio []float64, timeInMs int
 		os.Exit(1)
 	}
 	ec := loadSavedContext(loadFileName)
-	return NewRunner(ec, opeRatio, timeInMs, profiler, loadFileName, 0, multipartThresh, caCertFileName)
+	return NewRunner(ec, opeRatio, timeInMs, profiler, loadFileName, 0, multipartConfig, checksumConfig, caCertFileName)
 }
 
 func loadSavedContext(loadFileName string) *ExecutionContext {
-	f, err := os.Open(loadFileName)
-	if err != nil {
-		slog.Error(err.Error())
-		os.Exit(1)
-	}
-	defer f.Close()
-	savedContext, err := io.ReadAll(f)
+	ec, err := readContextFile(loadFileName)
 	if err != nil {
 		slog.Error(err.Error())
 		os.Exit(1)
 	}
-	ec := &ExecutionContext{}
-	err = json.Unmarshal(savedContext, ec)
+	err = applyJournal(ec, loadFileName)
 	if err != nil {
 		slog.Error(err.Error())
 		os.Exit(1)
@@ -104,15 +118,20 @@ func loadSavedContext(loadFileName string) *ExecutionContext {
 }
 
 func (r *Runner) init() {
-	r.client = s3client.NewS3Client(r.execContext.Endpoint, r.caCertFileName, r.multipartThresh)
+	r.client = s3client.NewS3Client(r.execContext.Endpoint, r.caCertFileName, r.multipartConfig, r.checksumConfig)
 	if r.loadFileName == "" {
 		r.execContext.Workers = make([]Worker, r.execContext.NumWorker)
 		r.execContext.StartWorkerID = rand.Intn(maxWorkerID)
 	}
+	var sharedKeys *sharedKeyModel
+	if r.execContext.SharedKey {
+		sharedKeys = newSharedKeyModel(r.execContext.BucketNames, r.execContext.NumObj, r.execContext.SharedKeyEpoch)
+	}
 	for i := range r.execContext.Workers {
 		r.execContext.Workers[i].id = (r.execContext.StartWorkerID + i) % maxWorkerID
 		r.execContext.Workers[i].minSize = r.execContext.MinSize
 		r.execContext.Workers[i].maxSize = r.execContext.MaxSize
+		r.execContext.Workers[i].versioning = r.execContext.Versioning
 		if r.loadFileName == "" {
 			r.execContext.Workers[i].BucketsWithObject = make([]*BucketWithObject, len(r.execContext.BucketNames))
 			for j, bucketName := range r.execContext.BucketNames {
@@ -120,7 +139,8 @@ func (r *Runner) init() {
 					BucketName: bucketName,
 					ObjectMeta: object.NewObjectMeta(
 						r.execContext.NumObj/r.execContext.NumWorker,
-						(int64(r.runnerID)<<32)+(int64(i)<<24)),
+						r.runnerID, i, r.execContext.NumWorker,
+						r.execContext.KeyScheme),
 				}
 			}
 		} else {
@@ -128,7 +148,14 @@ func (r *Runner) init() {
 				r.execContext.Workers[i].BucketsWithObject[j].ObjectMeta.TidyUp()
 			}
 		}
+		for _, bucketWithObj := range r.execContext.Workers[i].BucketsWithObject {
+			bucketWithObj.ObjectMeta.SetWriter(bucketWithObj.BucketName, r.execContext.Workers[i].id)
+		}
 		r.execContext.Workers[i].client = r.client
+		if sharedKeys != nil {
+			r.execContext.Workers[i].sharedKeys = sharedKeys
+			sharedKeys.localWorkerIDs[r.execContext.Workers[i].id] = struct{}{}
+		}
 		r.execContext.Workers[i].st = &r.st
 		r.execContext.Workers[i].logger = slog.Default().With("runnerID", r.runnerID,
 			"workerID", fmt.Sprintf("%#x", r.execContext.Workers[i].id))
@@ -162,13 +189,38 @@ func (r *Runner) InitBucket(ctx context.Context) error {
 		} else {
 			if r.loadFileName == "" {
 				slog.Info("Clearing bucket.", "bucket", bucketName)
-				err = r.client.ClearBucket(ctx, bucketName, fmt.Sprintf("%s%02x", object.KeyShortPrefix, r.runnerID))
+				prefix := fmt.Sprintf("%s%02x", object.KeyShortPrefix, r.runnerID)
+				if r.execContext.Versioning {
+					err = r.client.ClearBucketVersions(ctx, bucketName, prefix)
+				} else {
+					err = r.client.ClearBucket(ctx, bucketName, prefix)
+				}
 				if err != nil {
 					return err
 				}
 				slog.Info("Bucket cleared successfully.")
 			}
 		}
+		if r.execContext.Versioning && r.loadFileName == "" {
+			err = r.client.EnableVersioning(ctx, bucketName)
+			if err != nil {
+				return err
+			}
+			slog.Info("Versioning enabled.", "bucket", bucketName)
+		}
+	}
+	if r.loadFileName != "" {
+		for i := range r.execContext.Workers {
+			w := &r.execContext.Workers[i]
+			findings, err := w.resolveUncertainObjects(ctx)
+			if err != nil {
+				return err
+			}
+			// The workload continues from the reset objects, and the findings are reported at the end.
+			for _, finding := range findings {
+				r.events.add(newErrorEvent("load", w.id, finding))
+			}
+		}
 	}
 	return nil
 }
@@ -183,10 +235,17 @@ func (r *Runner) Run(ctx context.Context) error {
 	var err error
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
+	if r.checkpointInterval != 0 {
+		err = r.startJournal()
+		if err != nil {
+			return err
+		}
+	}
 	for i := 0; i < r.execContext.NumWorker; i++ {
 		wg.Add(1)
 		go func(workerID int) {
 			defer wg.Done()
+			w := &r.execContext.Workers[workerID]
 			for err == nil && (r.timeInMs == 0 || time.Since(now).Milliseconds() < r.timeInMs) {
 				select {
 				case <-ctx.Done():
@@ -195,35 +254,137 @@ func (r *Runner) Run(ctx context.Context) error {
 				default:
 				}
 
+				// The lock is held during each operation so that the checkpoints see no operation in flight.
+				w.mu.Lock()
 				operation := r.selectOperation()
+				w.mutating = !operation.readOnly()
+				var opeErr error
 				switch operation {
 				case Put:
-					err = r.execContext.Workers[workerID].Put(ctx)
+					opeErr = w.Put(ctx)
 				case Get:
-					err = r.execContext.Workers[workerID].Get(ctx)
+					opeErr = w.Get(ctx)
 				case Delete:
-					err = r.execContext.Workers[workerID].Delete(ctx)
+					opeErr = w.Delete(ctx)
 				case List:
-					err = r.execContext.Workers[workerID].List(ctx)
+					opeErr = w.List(ctx)
+				case RangeGet:
+					opeErr = w.RangeGet(ctx)
+				case Copy:
+					opeErr = w.Copy(ctx)
+				case Compose:
+					opeErr = w.Compose(ctx)
+				case AbortUpload:
+					opeErr = w.AbortUpload(ctx)
+				case AbandonUpload:
+					opeErr = w.AbandonUpload(ctx)
+				case OverwritePart:
+					opeErr = w.OverwritePart(ctx)
+				case ConditionalPut:
+					opeErr = w.ConditionalPut(ctx)
+				case Head:
+					opeErr = w.Head(ctx)
+				case BatchDelete:
+					opeErr = w.BatchDelete(ctx)
 				}
-				if err != nil {
+				w.mutating = false
+				if (opeErr != nil || ctx.Err() != nil) && !operation.readOnly() {
+					// The operation may or may not have taken effect.
+					w.markUncertain()
+				}
+				if opeErr != nil && ctx.Err() == nil {
+					w.recordCorruption(ctx, operation.String(), opeErr)
+				}
+				if opeErr != nil && r.continueOnError && ctx.Err() == nil {
+					stopping := r.recordErrorEvent(w, operation, opeErr)
+					w.releaseObjects()
+					w.mu.Unlock()
+					if stopping {
+						cancel()
+						return
+					}
+					continue
+				}
+				w.releaseObjects()
+				w.mu.Unlock()
+				if opeErr != nil {
+					err = opeErr
 					cancel()
 					return
 				}
 			}
 		}(i)
 	}
+	stopCheckpoint := r.startCheckpoint()
 	wg.Wait()
+	stopCheckpoint()
+	if r.corruptions != nil {
+		r.corruptions.removeCaptureFiles()
+	}
 	slog.Info("Validation finished.")
 	r.st.Report()
 
+	if r.checkpointInterval != 0 {
+		// The last checkpoint records the objects of the interrupted operations as uncertain.
+		cpErr := r.checkpoint()
+		if cpErr == nil {
+			// No operation follows the last checkpoint.
+			cpErr = r.journal.remove()
+		}
+		if cpErr != nil {
+			slog.Error("Checkpoint failed.", "err", cpErr)
+			if err == nil {
+				return cpErr
+			}
+		}
+	}
+
+	if r.continueOnError {
+		report := r.events.report()
+		if len(report.Events) != 0 {
+			slog.Error(report.Error())
+			return report
+		}
+	}
+
 	if err != nil {
 		return err
 	}
 
+	// The uncertain objects reset at the load are reported even if the workload does not continue on the errors.
+	report := r.events.report()
+	if len(report.Events) != 0 {
+		slog.Error(report.Error())
+		return report
+	}
 	return nil
 }
 
+// ContinueOnError makes the workload continue on the errors until `maxErrors` errors occur.
+// The value 0 of `maxErrors` means no limit.
+// The objects affected by each error are quarantined and all errors are reported at the end.
+func (r *Runner) ContinueOnError(maxErrors int) {
+	r.continueOnError = true
+	r.maxErrors = maxErrors
+}
+
+// recordErrorEvent records the error `opeErr` of the operation `ope` by the worker `w`
+// and quarantines the affected objects.
+// It returns true if the number of the errors reached the limit.
+func (r *Runner) recordErrorEvent(w *Worker, ope Operation, opeErr error) bool {
+	event := newErrorEvent(ope.String(), w.id, opeErr)
+	event.Quarantined = w.quarantine(ope.readOnly(), opeErr)
+	numErrors := r.events.add(event)
+	w.logger.Warn("Recorded the error event. Continuing the workload.",
+		"class", event.Class, "operation", event.Operation, "phase", event.Phase,
+		"bucket", event.BucketName, "key", event.Key, "numErrors", numErrors)
+	if r.maxErrors != 0 && numErrors >= r.maxErrors {
+		slog.Error("The number of the errors reached the limit.", "maxErrors", r.maxErrors)
+		return true
+	}
+	return false
+}
+
 type Operation int
 
 const (
@@ -231,43 +392,58 @@ const (
 	Get
 	Delete
 	List
+	RangeGet
+	Copy
+	Compose
+	AbortUpload
+	AbandonUpload
+	OverwritePart
+	ConditionalPut
+	Head
+	BatchDelete
 	NumOperation
 )
 
-func (r *Runner) selectOperation() Operation {
-	randVal := rand.Float64()
-	if randVal < r.opeRatio[0] {
-		return Put
-	} else if randVal < r.opeRatio[0]+r.opeRatio[1] {
-		return Get
-	} else if randVal < r.opeRatio[0]+r.opeRatio[1]+r.opeRatio[2] {
-		return Delete
-	} else {
-		return List
-	}
+var operationNames = [NumOperation]string{
+	"put", "get", "delete", "list", "range get", "copy", "compose", "abort upload",
+	"abandon upload", "overwrite part", "conditional put", "head", "batch delete",
 }
 
-func (r *Runner) SaveContext(saveFileName string) error {
-	f, err := os.Create(saveFileName)
-	if err != nil {
-		return err
+func (o Operation) String() string {
+	if o < 0 || NumOperation <= o {
+		return fmt.Sprintf("Operation(%d)", int(o))
 	}
-	defer f.Close()
+	return operationNames[o]
+}
 
-	data, err := json.Marshal(r.execContext)
-	if err != nil {
-		return err
+// readOnly returns true if the operation never changes the objects.
+func (o Operation) readOnly() bool {
+	switch o {
+	case Get, List, RangeGet, Head:
+		return true
 	}
-	for {
-		n, err := f.Write(data)
-		if err != nil {
-			if n != len(data) {
-				data = data[:n]
-				continue
-			}
-			return err
+	return false
+}
+
+func (r *Runner) selectOperation() Operation {
+	randVal := rand.Float64()
+	sum := 0.0
+	lastCandidate := Put
+	for i, ratio := range r.opeRatio {
+		if ratio == 0 {
+			continue
 		}
-		break
+		sum += ratio
+		if randVal < sum {
+			return Operation(i)
+		}
+		lastCandidate = Operation(i)
 	}
-	return nil
+	// Reach here only due to the rounding error.
+	return lastCandidate
+}
+
+// SaveContext saves the execution context to the file `saveFileName` atomically.
+func (r *Runner) SaveContext(saveFileName string) error {
+	return writeContextFile(saveFileName, r.execContext)
 }
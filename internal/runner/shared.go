@@ -0,0 +1,336 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+
+	"github.com/peng225/oval/internal/object"
+	"github.com/peng225/oval/internal/pattern"
+	"github.com/peng225/oval/internal/s3client"
+)
+
+// sharedKeyModel is the in-memory model of the objects written by all workers of a runner in the shared-key mode.
+// Each write is recorded with the logical time when it started and completed,
+// so that it can be decided which writes a read may return.
+type sharedKeyModel struct {
+	// clock is the logical clock which is incremented at each event.
+	clock atomic.Int64
+	// epoch is the unix time in microseconds before which the data was written by the previous runs.
+	epoch          int64
+	localWorkerIDs map[int]struct{}
+	objects        map[string][]*sharedObject
+}
+
+type sharedObject struct {
+	mu         sync.Mutex
+	key        string
+	writeCount int
+	// writes is the list of the writes which a read may still return from the oldest one.
+	// The first one may be the initial state of the object, which is unknown.
+	writes []*sharedWrite
+	// activeReads is the multiset of the start time of the reads in progress.
+	activeReads map[int64]int
+	// observed is the latest write of each worker of the other processes found by the reads.
+	observed map[int]observedWrite
+}
+
+// observedWrite is the write of a worker of the other processes found by a read.
+// The writes of a worker to a key are sequential and their write counts increase,
+// so the write count is the logical clock of the writes of the worker.
+type observedWrite struct {
+	writeCount int
+	// end is the logical time when the read which found the write ended.
+	end int64
+}
+
+type sharedWrite struct {
+	initial    bool
+	workerID   int
+	writeCount int
+	size       int
+	checksum   *object.Checksum
+	start      int64
+	end        int64
+	// done is false while the write is in flight.
+	done bool
+}
+
+func newSharedKeyModel(bucketNames []string, numObj int, epoch int64) *sharedKeyModel {
+	m := &sharedKeyModel{
+		epoch:          epoch,
+		localWorkerIDs: make(map[int]struct{}),
+		objects:        make(map[string][]*sharedObject),
+	}
+	for _, bucketName := range bucketNames {
+		objects := make([]*sharedObject, numObj)
+		for i := range objects {
+			objects[i] = &sharedObject{
+				// The shared keys are in the form of "ovffXXXXXXXX".
+				key: object.NewObject(int64(object.SharedKeyRunnerID)*object.MaxObjPerRunner + int64(i)).Key,
+				// The object may have been written by the previous runs or the other processes.
+				writes: []*sharedWrite{
+					{
+						initial: true,
+						done:    true,
+					},
+				},
+				activeReads: make(map[int64]int),
+				observed:    make(map[int]observedWrite),
+			}
+		}
+		m.objects[bucketName] = objects
+	}
+	return m
+}
+
+func (m *sharedKeyModel) getRandomObject(bucketName string) *sharedObject {
+	objects := m.objects[bucketName]
+	return objects[rand.Intn(len(objects))]
+}
+
+func (m *sharedKeyModel) beginWrite(so *sharedObject, workerID, size int) *sharedWrite {
+	so.mu.Lock()
+	defer so.mu.Unlock()
+	so.writeCount++
+	sw := &sharedWrite{
+		workerID:   workerID,
+		writeCount: so.writeCount,
+		size:       size,
+		start:      m.clock.Add(1),
+	}
+	so.writes = append(so.writes, sw)
+	return sw
+}
+
+func (m *sharedKeyModel) endWrite(so *sharedObject, sw *sharedWrite, checksum *object.Checksum) {
+	so.mu.Lock()
+	defer so.mu.Unlock()
+	sw.end = m.clock.Add(1)
+	sw.done = true
+	sw.checksum = checksum
+	m.prune(so)
+}
+
+func (m *sharedKeyModel) beginRead(so *sharedObject) int64 {
+	so.mu.Lock()
+	defer so.mu.Unlock()
+	start := m.clock.Add(1)
+	so.activeReads[start]++
+	return start
+}
+
+func (m *sharedKeyModel) endRead(so *sharedObject, start int64) {
+	so.mu.Lock()
+	defer so.mu.Unlock()
+	so.activeReads[start]--
+	if so.activeReads[start] == 0 {
+		delete(so.activeReads, start)
+	}
+	m.prune(so)
+}
+
+// observe records that the read in progress since `start` found the data of the write `gen` of the other processes.
+// It returns the write count of the latest write of the same worker found by the reads which ended before `start`,
+// and false if there is no such read.
+func (m *sharedKeyModel) observe(so *sharedObject, gen *pattern.Generation, start int64) (int, bool) {
+	so.mu.Lock()
+	defer so.mu.Unlock()
+	latest, ok := so.observed[gen.WorkerID]
+	if !ok || latest.writeCount < gen.WriteCount {
+		so.observed[gen.WorkerID] = observedWrite{
+			writeCount: gen.WriteCount,
+			end:        m.clock.Add(1),
+		}
+	}
+	if !ok || start < latest.end {
+		return 0, false
+	}
+	return latest.writeCount, true
+}
+
+// readableWrites returns the writes which the read in progress since `start` may return.
+// They are the writes in flight during the read and the ones which were the latest at the read start.
+func (m *sharedKeyModel) readableWrites(so *sharedObject, start int64) []*sharedWrite {
+	so.mu.Lock()
+	defer so.mu.Unlock()
+	end := m.clock.Add(1)
+	writes := make([]*sharedWrite, 0, len(so.writes))
+	for _, sw := range so.writes {
+		if sw.start < end && !superseded(so.writes, sw, start) {
+			writes = append(writes, sw)
+		}
+	}
+	return writes
+}
+
+// superseded returns true if `sw` had been overwritten by another write before the time `t`.
+func superseded(writes []*sharedWrite, sw *sharedWrite, t int64) bool {
+	if !sw.done {
+		return false
+	}
+	for _, other := range writes {
+		if other.done && other.end < t && sw.end < other.start {
+			return true
+		}
+	}
+	return false
+}
+
+// prune forgets the writes which no read in progress or in the future can return.
+// so.mu must be held.
+func (m *sharedKeyModel) prune(so *sharedObject) {
+	oldestRead := m.clock.Load() + 1
+	for start := range so.activeReads {
+		oldestRead = min(oldestRead, start)
+	}
+	writes := make([]*sharedWrite, 0, len(so.writes))
+	for _, sw := range so.writes {
+		if !superseded(so.writes, sw, oldestRead) {
+			writes = append(writes, sw)
+		}
+	}
+	so.writes = writes
+}
+
+// SharedPut writes a random shared object in the shared-key mode.
+func (w *Worker) SharedPut(ctx context.Context) error {
+	bucketName := w.BucketsWithObject[rand.Intn(len(w.BucketsWithObject))].BucketName
+	so := w.sharedKeys.getRandomObject(bucketName)
+
+	size, err := pattern.DecideSize(w.minSize, w.maxSize)
+	if err != nil {
+		w.logger.Error(err.Error())
+		return err
+	}
+	sw := w.sharedKeys.beginWrite(so, w.id, size)
+	body, err := pattern.NewReader(size, w.id, bucketName, &object.Object{
+		Key:        so.key,
+		WriteCount: sw.writeCount,
+	})
+	if err != nil {
+		w.logger.Error(err.Error())
+		return err
+	}
+	// If the write fails, it remains in flight in the model
+	// because it is unknown whether the data was stored.
+	res, err := w.client.PutObject(ctx, bucketName, so.key, body, int64(size))
+	if err != nil {
+		w.logger.Error(err.Error())
+		return err
+	}
+	w.sharedKeys.endWrite(so, sw, writtenChecksum(res))
+
+	w.st.AddUploadedPartCount(int64(res.PartCount))
+	w.st.AddPutCount()
+	return nil
+}
+
+// SharedGet reads a random shared object in the shared-key mode.
+// The data must be the complete data of one of the writes which were in flight during the read
+// or were the latest at the read start.
+func (w *Worker) SharedGet(ctx context.Context) error {
+	bucketName := w.BucketsWithObject[rand.Intn(len(w.BucketsWithObject))].BucketName
+	so := w.sharedKeys.getRandomObject(bucketName)
+
+	start := w.sharedKeys.beginRead(so)
+	defer w.sharedKeys.endRead(so, start)
+	body, err := w.client.GetObject(ctx, bucketName, so.key)
+	if err != nil {
+		if !errors.Is(err, s3client.ErrNoSuchKey) {
+			w.logger.Error(err.Error())
+			return err
+		}
+		body = nil
+	} else {
+		defer body.Close()
+	}
+	var gen *pattern.Generation
+	if body != nil {
+		gen, err = pattern.ValidGeneration(bucketName, so.key, body)
+		if err != nil {
+			if ctx.Err() == context.Canceled {
+				w.logger.Warn("Detected the canceled context.")
+				return nil
+			}
+			err = fmt.Errorf("data validation error occurred at shared get. (key = %s)\n%w", so.key, err)
+			w.logger.Error(err.Error())
+			return err
+		}
+	}
+	w.st.AddGetCount()
+
+	writes := w.sharedKeys.readableWrites(so, start)
+	err = w.validSharedRead(so, gen, body, start, writes)
+	if err != nil {
+		err = fmt.Errorf("%w\nreadable writes: %s", err, formatSharedWrites(writes))
+		w.logger.Error(err.Error())
+		return err
+	}
+	return nil
+}
+
+// validSharedRead checks that the generation `gen` returned by the read in progress since `start` is one of `writes`.
+// `gen` is nil if the object was not found.
+func (w *Worker) validSharedRead(so *sharedObject, gen *pattern.Generation, body *s3client.ObjectReader,
+	start int64, writes []*sharedWrite) error {
+	initialReadable := len(writes) != 0 && writes[0].initial
+	if gen == nil {
+		if !initialReadable {
+			return fmt.Errorf("an object has been lost. (key = %s)", so.key)
+		}
+		return nil
+	}
+	if gen.UnixMicro < w.sharedKeys.epoch {
+		if !initialReadable {
+			return fmt.Errorf("the data written by the previous run was found. (key = %s, workerID = %#x, writeCount = %d)",
+				so.key, gen.WorkerID, gen.WriteCount)
+		}
+		return nil
+	}
+	if _, ok := w.sharedKeys.localWorkerIDs[gen.WorkerID]; !ok {
+		// The writes of the other processes are not recorded in the model.
+		// Only the data older than the one of the same worker found by the earlier reads is detected,
+		// because the writes of the different workers may be concurrent and cannot be ordered.
+		latest, ok := w.sharedKeys.observe(so, gen, start)
+		if ok && gen.WriteCount < latest {
+			return fmt.Errorf("the data older than the one found by the earlier read was found. (key = %s, workerID = %#x, writeCount = %d, found writeCount = %d)",
+				so.key, gen.WorkerID, gen.WriteCount, latest)
+		}
+		return nil
+	}
+	for _, sw := range writes {
+		if sw.initial || sw.workerID != gen.WorkerID || sw.writeCount != gen.WriteCount {
+			continue
+		}
+		if sw.size != gen.Size {
+			return fmt.Errorf("the size of the object is wrong. (key = %s, expected = %d, actual = %d)",
+				so.key, sw.size, gen.Size)
+		}
+		err := w.validChecksum(&object.Object{Checksum: sw.checksum}, body)
+		if err != nil {
+			return fmt.Errorf("checksum validation error occurred at shared get. (key = %s)\n%w", so.key, err)
+		}
+		return nil
+	}
+	return fmt.Errorf("the data of the write which was neither in flight nor the latest was found. (key = %s, workerID = %#x, writeCount = %d)",
+		so.key, gen.WorkerID, gen.WriteCount)
+}
+
+func formatSharedWrites(writes []*sharedWrite) string {
+	s := "["
+	for i, sw := range writes {
+		if i != 0 {
+			s += ", "
+		}
+		if sw.initial {
+			s += "initial"
+			continue
+		}
+		s += fmt.Sprintf("{workerID: %#x, writeCount: %d, size: %d, done: %v}", sw.workerID, sw.writeCount, sw.size, sw.done)
+	}
+	return s + "]"
+}
@@ -0,0 +1,42 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/peng225/oval/internal/pattern"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObserveForeignWrites(t *testing.T) {
+	m := newSharedKeyModel([]string{"bucket"}, 1, 0)
+	so := m.getRandomObject("bucket")
+
+	// The first read of the worker has nothing to compare with.
+	start := m.beginRead(so)
+	_, ok := m.observe(so, &pattern.Generation{WorkerID: 0x10, WriteCount: 3}, start)
+	assert.False(t, ok)
+	m.endRead(so, start)
+
+	// The read concurrent with the earlier one may find the older data.
+	_, ok = m.observe(so, &pattern.Generation{WorkerID: 0x10, WriteCount: 2}, start)
+	assert.False(t, ok)
+
+	start = m.beginRead(so)
+	latest, ok := m.observe(so, &pattern.Generation{WorkerID: 0x10, WriteCount: 2}, start)
+	assert.True(t, ok)
+	assert.Equal(t, 3, latest)
+	m.endRead(so, start)
+
+	// The writes of the different workers are not ordered.
+	start = m.beginRead(so)
+	_, ok = m.observe(so, &pattern.Generation{WorkerID: 0x20, WriteCount: 1}, start)
+	assert.False(t, ok)
+	m.endRead(so, start)
+
+	start = m.beginRead(so)
+	latest, ok = m.observe(so, &pattern.Generation{WorkerID: 0x10, WriteCount: 5}, start)
+	assert.True(t, ok)
+	assert.Equal(t, 3, latest)
+	m.endRead(so, start)
+	assert.Equal(t, 5, so.observed[0x10].writeCount)
+}
@@ -0,0 +1,292 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"slices"
+
+	"github.com/peng225/oval/internal/object"
+	"github.com/peng225/oval/internal/pattern"
+	"github.com/peng225/oval/internal/s3client"
+)
+
+const (
+	// The maximum number of the multipart uploads intentionally left in progress for each bucket.
+	// The oldest ones are aborted when the number exceeds it.
+	maxNumDanglingUploads = 4
+)
+
+// AbortUpload starts a multipart upload of a random object, uploads some parts
+// and then aborts it. The object must remain unchanged.
+func (w *Worker) AbortUpload(ctx context.Context) error {
+	bucketWithObj := w.selectBucketWithObject()
+	obj := bucketWithObj.ObjectMeta.GetRandomObject()
+	if obj == nil {
+		return nil
+	}
+
+	uploadID, err := w.startUpload(ctx, bucketWithObj, obj)
+	if err != nil {
+		return err
+	}
+	err = w.client.AbortMultipartUpload(ctx, bucketWithObj.BucketName, obj.Key, uploadID)
+	if err != nil {
+		w.logger.Error(err.Error())
+		return err
+	}
+	w.st.AddAbortUploadCount()
+
+	err = w.validUploads(ctx, bucketWithObj, obj.Key)
+	if err != nil {
+		return err
+	}
+	err = w.validCurrentState(ctx, bucketWithObj, obj, "after upload abort")
+	if err != nil && !errors.Is(err, errCanceled) {
+		return err
+	}
+	return nil
+}
+
+// AbandonUpload starts a multipart upload of a random object, uploads some parts
+// and then leaves it in progress. The object must remain unchanged.
+func (w *Worker) AbandonUpload(ctx context.Context) error {
+	bucketWithObj := w.selectBucketWithObject()
+	obj := bucketWithObj.ObjectMeta.GetRandomObject()
+	if obj == nil {
+		return nil
+	}
+
+	uploadID, err := w.startUpload(ctx, bucketWithObj, obj)
+	if err != nil {
+		return err
+	}
+	bucketWithObj.DanglingUploads = append(bucketWithObj.DanglingUploads, s3client.MultipartUpload{
+		Key:      obj.Key,
+		UploadID: uploadID,
+	})
+	w.st.AddAbandonUploadCount()
+
+	for len(bucketWithObj.DanglingUploads) > maxNumDanglingUploads {
+		oldest := bucketWithObj.DanglingUploads[0]
+		err = w.client.AbortMultipartUpload(ctx, bucketWithObj.BucketName, oldest.Key, oldest.UploadID)
+		if err != nil {
+			w.logger.Error(err.Error())
+			return err
+		}
+		bucketWithObj.DanglingUploads = slices.Delete(bucketWithObj.DanglingUploads, 0, 1)
+		w.st.AddAbortUploadCount()
+		err = w.validUploads(ctx, bucketWithObj, oldest.Key)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = w.validUploads(ctx, bucketWithObj, obj.Key)
+	if err != nil {
+		return err
+	}
+	err = w.validCurrentState(ctx, bucketWithObj, obj, "after upload abandonment")
+	if err != nil && !errors.Is(err, errCanceled) {
+		return err
+	}
+	return nil
+}
+
+// startUpload starts a multipart upload of `obj` and uploads some of its parts.
+// The uploaded data is the one of a generation which must never be visible,
+// so the write count of `obj` is consumed.
+func (w *Worker) startUpload(ctx context.Context, bucketWithObj *BucketWithObject, obj *object.Object) (string, error) {
+	size, err := pattern.DecideSize(w.minSize, w.maxSize)
+	if err != nil {
+		w.logger.Error(err.Error())
+		return "", err
+	}
+	obj.SkipWriteCount(bucketWithObj.BucketName)
+	body, err := pattern.NewReader(size, w.id, bucketWithObj.BucketName, &object.Object{
+		Key:        obj.Key,
+		KeyID:      obj.KeyID,
+		WriteCount: obj.WriteCount,
+	})
+	if err != nil {
+		w.logger.Error(err.Error())
+		return "", err
+	}
+
+	uploadID, err := w.client.CreateMultipartUpload(ctx, bucketWithObj.BucketName, obj.Key)
+	if err != nil {
+		w.logger.Error(err.Error())
+		return "", err
+	}
+	partSizes := w.decidePartSizes(size)
+	numParts := 1 + rand.Intn(len(partSizes))
+	offset := 0
+	for i := 0; i < numParts; i++ {
+		_, err = w.client.UploadPart(ctx, bucketWithObj.BucketName, obj.Key, uploadID, int32(i+1),
+			io.NewSectionReader(body, int64(offset), int64(partSizes[i])), int64(partSizes[i]))
+		if err != nil {
+			err = w.abortFailedUpload(bucketWithObj, obj.Key, uploadID, err)
+			w.logger.Error(err.Error())
+			return "", err
+		}
+		offset += partSizes[i]
+	}
+	w.st.AddUploadedPartCount(int64(numParts))
+	return uploadID, nil
+}
+
+// OverwritePart creates a random object by the multipart upload in which a part is uploaded twice
+// with different data before the completion. Only the data uploaded last must be visible.
+func (w *Worker) OverwritePart(ctx context.Context) error {
+	bucketWithObj := w.selectBucketWithObject()
+	obj := bucketWithObj.ObjectMeta.GetRandomObject()
+	if obj == nil {
+		return nil
+	}
+
+	err := w.validBeforeWrite(ctx, bucketWithObj, obj, "part overwrite")
+	if err != nil {
+		if errors.Is(err, errCanceled) {
+			return nil
+		}
+		return err
+	}
+
+	size, err := pattern.DecideSize(w.minSize, w.maxSize)
+	if err != nil {
+		w.logger.Error(err.Error())
+		return err
+	}
+	// The overwritten data is the one of a generation which must never be visible.
+	obj.SkipWriteCount(bucketWithObj.BucketName)
+	staleBody, err := pattern.NewReader(size, w.id, bucketWithObj.BucketName, &object.Object{
+		Key:        obj.Key,
+		KeyID:      obj.KeyID,
+		WriteCount: obj.WriteCount,
+	})
+	if err != nil {
+		w.logger.Error(err.Error())
+		return err
+	}
+	obj.Size = size
+	bucketWithObj.ObjectMeta.RegisterToExistingList(obj.Key)
+	obj.WriteCount++
+	obj.Segments = nil
+	body, err := pattern.NewReader(size, w.id, bucketWithObj.BucketName, obj)
+	if err != nil {
+		w.logger.Error(err.Error())
+		return err
+	}
+
+	uploadID, err := w.client.CreateMultipartUpload(ctx, bucketWithObj.BucketName, obj.Key)
+	if err != nil {
+		w.logger.Error(err.Error())
+		return err
+	}
+	partSizes := w.decidePartSizes(size)
+	overwrittenPart := rand.Intn(len(partSizes))
+	parts := make([]s3client.CompletedPart, 0, len(partSizes))
+	offset := 0
+	for i, partSize := range partSizes {
+		pn := int32(i + 1)
+		if i == overwrittenPart {
+			_, err = w.client.UploadPart(ctx, bucketWithObj.BucketName, obj.Key, uploadID, pn,
+				io.NewSectionReader(staleBody, int64(offset), int64(partSize)), int64(partSize))
+			if err != nil {
+				err = w.abortFailedUpload(bucketWithObj, obj.Key, uploadID, err)
+				w.logger.Error(err.Error())
+				return err
+			}
+		}
+		part, err := w.client.UploadPart(ctx, bucketWithObj.BucketName, obj.Key, uploadID, pn,
+			io.NewSectionReader(body, int64(offset), int64(partSize)), int64(partSize))
+		if err != nil {
+			err = w.abortFailedUpload(bucketWithObj, obj.Key, uploadID, err)
+			w.logger.Error(err.Error())
+			return err
+		}
+		parts = append(parts, *part)
+		offset += partSize
+	}
+	res, err := w.client.CompleteMultipartUpload(ctx, bucketWithObj.BucketName, obj.Key, uploadID, parts)
+	if err != nil {
+		err = w.abortFailedUpload(bucketWithObj, obj.Key, uploadID, err)
+		w.logger.Error(err.Error())
+		return err
+	}
+	obj.Checksum = writtenChecksum(res)
+	obj.ETag = writtenETag(res)
+	obj.Metadata = nil
+	w.st.AddUploadedPartCount(int64(len(parts) + 1))
+	w.st.AddOverwritePartCount()
+
+	err = w.recordVersion(ctx, bucketWithObj, obj, res.VersionID)
+	if err != nil {
+		return err
+	}
+
+	err = w.validAfterWrite(ctx, bucketWithObj, obj, "part overwrite")
+	if err != nil && !errors.Is(err, errCanceled) {
+		return err
+	}
+	return nil
+}
+
+// decidePartSizes splits `size` bytes into the parts of the multipart upload.
+func (w *Worker) decidePartSizes(size int) []int {
+	partSizes := make([]int, 0)
+	for offset := 0; offset < size; {
+		partSize := min(w.client.DecidePartSize(), size-offset)
+		partSizes = append(partSizes, partSize)
+		offset += partSize
+	}
+	return partSizes
+}
+
+// abortFailedUpload aborts the multipart upload `uploadID` of `key` which failed with `err`,
+// and returns `err` joined with the error of the abort, if any.
+// If the abort fails, the upload is registered as a dangling one so that it is aborted later.
+func (w *Worker) abortFailedUpload(bucketWithObj *BucketWithObject, key, uploadID string, err error) error {
+	// `ctx` cannot be used for the abort because the failed request may have failed
+	// due to the cancellation of `ctx`.
+	abortErr := w.client.AbortMultipartUpload(context.Background(), bucketWithObj.BucketName, key, uploadID)
+	if abortErr != nil {
+		bucketWithObj.DanglingUploads = append(bucketWithObj.DanglingUploads, s3client.MultipartUpload{
+			Key:      key,
+			UploadID: uploadID,
+		})
+		return errors.Join(err, fmt.Errorf("failed to abort multipart upload. %w", abortErr))
+	}
+	return err
+}
+
+// validUploads checks that the in-progress multipart uploads of `key` found by ListMultipartUploads
+// match the dangling uploads recorded in `bucketWithObj`.
+func (w *Worker) validUploads(ctx context.Context, bucketWithObj *BucketWithObject, key string) error {
+	uploads, err := w.client.ListMultipartUploads(ctx, bucketWithObj.BucketName, key)
+	if err != nil {
+		w.logger.Error(err.Error())
+		return err
+	}
+	actual := make([]string, 0, len(uploads))
+	for _, u := range uploads {
+		actual = append(actual, u.UploadID)
+	}
+	expected := make([]string, 0)
+	for _, u := range bucketWithObj.DanglingUploads {
+		if u.Key == key {
+			expected = append(expected, u.UploadID)
+		}
+	}
+	slices.Sort(actual)
+	slices.Sort(expected)
+	if !slices.Equal(expected, actual) {
+		err = fmt.Errorf("the in-progress multipart uploads found by the LIST operation are wrong. (key = %s, expected = %v, actual = %v)",
+			key, expected, actual)
+		w.logger.Error(err.Error())
+		return err
+	}
+	return nil
+}
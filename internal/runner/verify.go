@@ -0,0 +1,269 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/peng225/oval/internal/object"
+	"github.com/peng225/oval/internal/pattern"
+	"github.com/peng225/oval/internal/s3client"
+)
+
+// FindingClass is the class of the problem found by the verification.
+type FindingClass string
+
+const (
+	// FindingLost means that the object should exist, but it does not.
+	FindingLost FindingClass = "lost"
+	// FindingStale means that the object has the data of an older write.
+	FindingStale FindingClass = "stale"
+	// FindingCorrupted means that the data, the checksum or the metadata of the object is wrong.
+	FindingCorrupted FindingClass = "corrupted"
+	// FindingUnexpected means that the object should not exist, but it does.
+	FindingUnexpected FindingClass = "unexpected"
+	// FindingUncertain means that the object is not in the expected state,
+	// but the last operation on it was interrupted and thus it may or may not have taken effect.
+	FindingUncertain FindingClass = "uncertain"
+	// FindingInconsistent means that the result of the LIST or the HEAD operation does not match the objects.
+	FindingInconsistent FindingClass = "inconsistent"
+	// FindingQuarantined means that the object was excluded from the workload due to an error.
+	FindingQuarantined FindingClass = "quarantined"
+	// FindingFailed means that the object could not be verified because of an error other than the above.
+	FindingFailed FindingClass = "failed"
+)
+
+var findingClasses = []FindingClass{
+	FindingLost, FindingStale, FindingCorrupted, FindingUnexpected, FindingInconsistent,
+	FindingUncertain, FindingQuarantined, FindingFailed,
+}
+
+// Finding is the problem of an object found by the verification.
+type Finding struct {
+	Class      FindingClass
+	BucketName string
+	Key        string
+	WriteCount int
+	Detail     string
+}
+
+// Inventory is the result of the verification of all objects.
+type Inventory struct {
+	mu          sync.Mutex
+	NumVerified int
+	Findings    []Finding
+}
+
+func (inv *Inventory) add(f *Finding) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	inv.NumVerified++
+	if f != nil {
+		inv.Findings = append(inv.Findings, *f)
+	}
+}
+
+// NumProblems returns the number of the findings except the uncertain ones.
+func (inv *Inventory) NumProblems() int {
+	n := 0
+	for _, f := range inv.Findings {
+		if f.Class != FindingUncertain {
+			n++
+		}
+	}
+	return n
+}
+
+// Print writes the findings grouped by the class to `out`.
+// The classes without the findings are omitted.
+func (inv *Inventory) Print(out io.Writer) {
+	sort.SliceStable(inv.Findings, func(i, j int) bool {
+		if inv.Findings[i].BucketName != inv.Findings[j].BucketName {
+			return inv.Findings[i].BucketName < inv.Findings[j].BucketName
+		}
+		return inv.Findings[i].Key < inv.Findings[j].Key
+	})
+	fmt.Fprintf(out, "Verified %d objects. Found %d problems.\n", inv.NumVerified, inv.NumProblems())
+	for _, class := range findingClasses {
+		findings := make([]Finding, 0)
+		for _, f := range inv.Findings {
+			if f.Class == class {
+				findings = append(findings, f)
+			}
+		}
+		if len(findings) == 0 {
+			continue
+		}
+		fmt.Fprintf(out, "%s: %d\n", class, len(findings))
+		for _, f := range findings {
+			fmt.Fprintf(out, "  bucket = %s, key = %s, writeCount = %d\n", f.BucketName, f.Key, f.WriteCount)
+			if f.Detail != "" {
+				fmt.Fprintf(out, "    %s\n", strings.ReplaceAll(strings.TrimRight(f.Detail, "\n"), "\n", "\n    "))
+			}
+		}
+	}
+}
+
+type verifyTask struct {
+	w          *Worker
+	bucketName string
+	// obj is the copy of the object, so that it can be read without the lock.
+	obj   object.Object
+	exist bool
+}
+
+// Verify walks all objects of all workers in all buckets and checks that
+// each existing object has the expected data and each non-existing object is not found.
+// The objects are verified by `numWorker` goroutines concurrently.
+// Only the current versions of the objects are verified.
+func (r *Runner) Verify(ctx context.Context, numWorker int) (*Inventory, error) {
+	if r.execContext.SharedKey {
+		return nil, errors.New("the verification is not supported in the shared-key mode")
+	}
+	slog.Info("Verification start.")
+	inv := &Inventory{}
+	tasks := make(chan *verifyTask, numWorker)
+	go func() {
+		defer close(tasks)
+		for i := range r.execContext.Workers {
+			w := &r.execContext.Workers[i]
+			for _, bucketWithObj := range w.BucketsWithObject {
+				om := bucketWithObj.ObjectMeta
+				for objID := range int64(om.NumObj()) {
+					obj := om.GetObjectByID(objID)
+					task := &verifyTask{
+						w:          w,
+						bucketName: bucketWithObj.BucketName,
+						obj:        *obj,
+						exist:      om.Exist(obj.Key),
+					}
+					om.Release()
+					select {
+					case tasks <- task:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	wg := &sync.WaitGroup{}
+	for range numWorker {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for task := range tasks {
+				f := task.w.verifyObject(ctx, task.bucketName, &task.obj, task.exist)
+				if ctx.Err() != nil {
+					return
+				}
+				inv.add(f)
+			}
+		}()
+	}
+	wg.Wait()
+	if r.corruptions != nil {
+		r.corruptions.removeCaptureFiles()
+	}
+	if ctx.Err() != nil {
+		slog.Warn("Verification was canceled.")
+		return nil, ctx.Err()
+	}
+	slog.Info("Verification finished.", "numVerified", inv.NumVerified, "numProblems", inv.NumProblems())
+	return inv, nil
+}
+
+// verifyObject checks that the object is in the expected state and returns the finding if it is not.
+// The object is expected to exist if `exist` is true.
+func (w *Worker) verifyObject(ctx context.Context, bucketName string, obj *object.Object, exist bool) *Finding {
+	f := w.findProblem(ctx, bucketName, obj, exist)
+	if f == nil {
+		return nil
+	}
+	f.BucketName = bucketName
+	f.Key = obj.Key
+	f.WriteCount = obj.WriteCount
+	if obj.Uncertain && f.Class != FindingQuarantined {
+		f.Detail = fmt.Sprintf("The last operation was interrupted. It would be %s otherwise.\n%s", f.Class, f.Detail)
+		f.Class = FindingUncertain
+	}
+	w.logger.Debug("Found a problem.", "class", f.Class, "bucket", bucketName, "key", obj.Key)
+	return f
+}
+
+func (w *Worker) findProblem(ctx context.Context, bucketName string, obj *object.Object, exist bool) *Finding {
+	if obj.Quarantined {
+		// The object may be in any state.
+		return &Finding{Class: FindingQuarantined}
+	}
+	body, err := w.client.GetObject(ctx, bucketName, obj.Key)
+	if err != nil {
+		if errors.Is(err, s3client.ErrNoSuchKey) {
+			if exist {
+				return &Finding{Class: FindingLost}
+			}
+			return nil
+		}
+		return &Finding{Class: FindingFailed, Detail: err.Error()}
+	}
+	defer body.Close()
+	if !exist {
+		return &Finding{Class: FindingUnexpected}
+	}
+
+	data := w.captureBody(body)
+	defer data.release()
+	corrupted := func(class FindingClass, err error) *Finding {
+		f := &Finding{Class: class, Detail: err.Error()}
+		objErr := newObjectError(class, "verify", bucketName, obj, objectState(obj), "", err)
+		objErr.dumpFile = w.keepCapture(data)
+		analysis := w.recordCorruption(ctx, "verify", objErr)
+		if analysis != nil {
+			f.Detail = strings.TrimRight(f.Detail, "\n") + "\n" + analysis.String()
+		}
+		return f
+	}
+	// The first data unit is kept to tell if the data is of an older write.
+	head := make([]byte, pattern.DataUnitSize)
+	n, _ := io.ReadFull(data, head)
+	err = pattern.Valid(w.id, bucketName, obj, io.MultiReader(bytes.NewReader(head[:n]), data))
+	if err != nil {
+		if n == pattern.DataUnitSize && isStale(bucketName, obj, pattern.ParseDataUnitHeader(head)) {
+			return corrupted(FindingStale, err)
+		}
+		return corrupted(FindingCorrupted, err)
+	}
+	err = w.validChecksum(obj, body)
+	if err != nil {
+		return corrupted(FindingCorrupted, err)
+	}
+	mismatch, err := w.validMetadata(ctx, bucketName, obj, "", body)
+	if err != nil {
+		return &Finding{Class: FindingFailed, Detail: err.Error()}
+	}
+	if mismatch != "" {
+		return corrupted(FindingCorrupted, errors.New(mismatch))
+	}
+	w.st.AddGetForValidCount()
+	return nil
+}
+
+// isStale returns true if the data unit with `header` was written by an older write of `obj`.
+func isStale(bucketName string, obj *object.Object, header *pattern.DataUnitHeader) bool {
+	if len(obj.Segments) != 0 {
+		// The data was copied from the other objects.
+		return false
+	}
+	if len(bucketName) > object.MaxBucketNameLength {
+		bucketName = bucketName[:object.MaxBucketNameLength]
+	}
+	return header.BucketName == bucketName && header.Key == obj.EmbeddedKey() &&
+		header.WriteCount < obj.WriteCount
+}
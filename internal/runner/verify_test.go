@@ -0,0 +1,51 @@
+package runner
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/peng225/oval/internal/object"
+	"github.com/peng225/oval/internal/pattern"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInventory(t *testing.T) {
+	inv := &Inventory{}
+	inv.add(nil)
+	inv.add(&Finding{Class: FindingCorrupted, BucketName: "bucket", Key: "ov0000000002", Detail: "line1\nline2\n"})
+	inv.add(&Finding{Class: FindingLost, BucketName: "bucket", Key: "ov0000000001"})
+	inv.add(&Finding{Class: FindingUncertain, BucketName: "bucket", Key: "ov0000000003"})
+	assert.Equal(t, 4, inv.NumVerified)
+	assert.Equal(t, 2, inv.NumProblems())
+
+	out := &bytes.Buffer{}
+	inv.Print(out)
+	assert.Equal(t, `Verified 4 objects. Found 2 problems.
+lost: 1
+  bucket = bucket, key = ov0000000001, writeCount = 0
+corrupted: 1
+  bucket = bucket, key = ov0000000002, writeCount = 0
+    line1
+    line2
+uncertain: 1
+  bucket = bucket, key = ov0000000003, writeCount = 0
+`, out.String())
+}
+
+func TestIsStale(t *testing.T) {
+	obj := &object.Object{
+		Key:        "ov0000000001",
+		WriteCount: 2,
+	}
+	data, err := pattern.Generate(pattern.DataUnitSize, 1, "bucket", obj)
+	require.NoError(t, err)
+	header := pattern.ParseDataUnitHeader(data)
+	assert.False(t, isStale("bucket", obj, header))
+
+	obj.WriteCount = 3
+	assert.True(t, isStale("bucket", obj, header))
+	assert.False(t, isStale("other-bucket", obj, header))
+	obj.Segments = obj.DataSegments("bucket", 0, pattern.DataUnitSize)
+	assert.False(t, isStale("bucket", obj, header))
+}
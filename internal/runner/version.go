@@ -0,0 +1,205 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"slices"
+
+	"github.com/peng225/oval/internal/object"
+	"github.com/peng225/oval/internal/s3client"
+)
+
+const (
+	// The maximum number of versions retained for each object.
+	// The oldest versions are permanently deleted when the number of versions exceeds it.
+	maxNumVersions = 8
+)
+
+// recordVersion records the current data of `obj` as the version `versionID`
+// if the bucket is versioning-enabled.
+func (w *Worker) recordVersion(ctx context.Context, bucketWithObj *BucketWithObject, obj *object.Object, versionID string) error {
+	if !w.versioning {
+		return nil
+	}
+	if versionID == "" {
+		err := fmt.Errorf("version ID was not returned from the versioning-enabled bucket. (key = %s)", obj.Key)
+		w.logger.Error(err.Error())
+		return err
+	}
+	obj.AddVersion(versionID)
+	return w.pruneVersions(ctx, bucketWithObj, obj)
+}
+
+// recordDeleteMarker records the delete marker `versionID` as the latest version of `obj`,
+// and checks that the previous version is still readable.
+func (w *Worker) recordDeleteMarker(ctx context.Context, bucketWithObj *BucketWithObject, obj *object.Object, versionID string) error {
+	if versionID == "" {
+		err := fmt.Errorf("version ID of the delete marker was not returned from the versioning-enabled bucket. (key = %s)", obj.Key)
+		w.logger.Error(err.Error())
+		return err
+	}
+	obj.AddDeleteMarker(versionID)
+	if len(obj.Versions) >= 2 && !obj.Versions[len(obj.Versions)-2].DeleteMarker {
+		err := w.validVersion(ctx, bucketWithObj, obj, len(obj.Versions)-2, "delete")
+		if err != nil {
+			return err
+		}
+	}
+	return w.pruneVersions(ctx, bucketWithObj, obj)
+}
+
+// pruneVersions permanently deletes the oldest versions of `obj`
+// until the number of versions is less than or equal to maxNumVersions.
+func (w *Worker) pruneVersions(ctx context.Context, bucketWithObj *BucketWithObject, obj *object.Object) error {
+	for len(obj.Versions) > maxNumVersions {
+		oldest := obj.Versions[0]
+		err := w.client.DeleteObjectVersion(ctx, bucketWithObj.BucketName, obj.Key, oldest.VersionID)
+		if err != nil {
+			w.logger.Error(err.Error())
+			return err
+		}
+		obj.Versions = slices.Delete(obj.Versions, 0, 1)
+		w.st.AddDeleteVersionCount()
+		if oldest.DeleteMarker {
+			continue
+		}
+
+		// Validation after version deletion
+		body, err := w.client.GetObjectVersion(ctx, bucketWithObj.BucketName, obj.Key, oldest.VersionID)
+		if err != nil {
+			if errors.Is(err, s3client.ErrNoSuchVersion) || errors.Is(err, s3client.ErrNoSuchKey) {
+				continue
+			}
+			err = fmt.Errorf("unexpected error occurred. (err = %w)", err)
+			w.logger.Error(err.Error())
+			return err
+		}
+		body.Close()
+		err = fmt.Errorf("expected: version not found, actual: version found. (key = %s, versionID = %s)",
+			obj.Key, oldest.VersionID)
+		w.logger.Error(err.Error())
+		return err
+	}
+	return nil
+}
+
+// getVersion reads a random version of a random object by the version ID and validates it.
+func (w *Worker) getVersion(ctx context.Context) error {
+	bucketWithObj := w.selectBucketWithObject()
+	obj := bucketWithObj.ObjectMeta.GetRandomObject()
+	if obj == nil {
+		return nil
+	}
+	candidates := make([]int, 0, len(obj.Versions))
+	for i, v := range obj.Versions {
+		if !v.DeleteMarker {
+			candidates = append(candidates, i)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	err := w.validVersion(ctx, bucketWithObj, obj, candidates[rand.Intn(len(candidates))], "get")
+	if err != nil {
+		if errors.Is(err, errCanceled) {
+			return nil
+		}
+		return err
+	}
+	w.st.AddGetVersionCount()
+	return nil
+}
+
+// validVersion reads the `i`-th version of `obj` by the version ID and validates it.
+// It returns errCanceled if the validation was interrupted by the context cancellation.
+func (w *Worker) validVersion(ctx context.Context, bucketWithObj *BucketWithObject, obj *object.Object, i int, opName string) error {
+	versionID := obj.Versions[i].VersionID
+	body, err := w.client.GetObjectVersion(ctx, bucketWithObj.BucketName, obj.Key, versionID)
+	if err != nil {
+		if errors.Is(err, s3client.ErrNoSuchVersion) || errors.Is(err, s3client.ErrNoSuchKey) {
+			err = newObjectError(FindingLost, phaseName(opName+" version"), bucketWithObj.BucketName, obj,
+				fmt.Sprintf("%s (versionID = %s)", stateExists, versionID), stateNotFound,
+				fmt.Errorf("a version has been lost at %s.\nerr: %w\nkey: %s, versionID: %s",
+					opName, err, obj.Key, versionID)).withVersion(versionID, obj.VersionObject(i))
+		}
+		w.logger.Error(err.Error())
+		return err
+	}
+	defer body.Close()
+	err = w.validObjectBody(ctx, bucketWithObj, obj.VersionObject(i), versionID, body, phaseName(opName+" version"))
+	if err != nil {
+		return err
+	}
+	w.st.AddGetForValidCount()
+	return nil
+}
+
+// listVersions checks that the result of ListObjectVersions matches the recorded versions.
+func (w *Worker) listVersions(ctx context.Context, bucketWithObj *BucketWithObject) error {
+	versions, err := w.client.ListObjectVersions(ctx, bucketWithObj.BucketName, bucketWithObj.ObjectMeta.KeyPrefix)
+	if err != nil {
+		w.logger.Error(err.Error())
+		return err
+	}
+
+	// The versions of the quarantined objects may be in any state.
+	versions = slices.DeleteFunc(versions, func(v s3client.ObjectVersion) bool {
+		return bucketWithObj.ObjectMeta.IsQuarantined(v.Key)
+	})
+	expectedNumVersions := bucketWithObj.ObjectMeta.NumVersions()
+	if expectedNumVersions != len(versions) {
+		err = &objectError{
+			class:      FindingInconsistent,
+			phase:      "list-versions",
+			bucketName: bucketWithObj.BucketName,
+			expected:   fmt.Sprintf("%d versions", expectedNumVersions),
+			actual:     fmt.Sprintf("%d versions", len(versions)),
+			err: fmt.Errorf("invalid number of versions found as a result of the LIST operation. expected = %d, actual = %d",
+				expectedNumVersions, len(versions)),
+		}
+		w.logger.Error(err.Error())
+		return err
+	}
+
+	for _, v := range versions {
+		obj, err := bucketWithObj.ObjectMeta.FindObject(v.Key)
+		if err != nil {
+			err = &objectError{
+				class:      FindingUnexpected,
+				phase:      "list-versions",
+				bucketName: bucketWithObj.BucketName,
+				key:        v.Key,
+				versionID:  v.VersionID,
+				expected:   stateNotFound,
+				actual:     fmt.Sprintf("%s (versionID = %s)", stateExists, v.VersionID),
+				err: fmt.Errorf("a version of an unknown key was found in the result of the LIST operation. (key = %s, versionID = %s)\n%w",
+					v.Key, v.VersionID, err),
+			}
+			w.logger.Error(err.Error())
+			return err
+		}
+		i := slices.IndexFunc(obj.Versions, func(ov object.Version) bool {
+			return ov.VersionID == v.VersionID
+		})
+		if i < 0 {
+			err = newObjectError(FindingInconsistent, "list-versions", bucketWithObj.BucketName, obj,
+				stateNotFound, fmt.Sprintf("%s (versionID = %s)", stateExists, v.VersionID),
+				fmt.Errorf("unexpected version found in the result of the LIST operation. (key = %s, versionID = %s)",
+					v.Key, v.VersionID))
+			w.logger.Error(err.Error())
+			return err
+		}
+		if obj.Versions[i].DeleteMarker != v.DeleteMarker || (i == len(obj.Versions)-1) != v.IsLatest {
+			err = newObjectError(FindingInconsistent, "list-versions", bucketWithObj.BucketName, obj,
+				fmt.Sprintf("deleteMarker = %t, isLatest = %t", obj.Versions[i].DeleteMarker, i == len(obj.Versions)-1),
+				fmt.Sprintf("deleteMarker = %t, isLatest = %t", v.DeleteMarker, v.IsLatest),
+				fmt.Errorf("invalid version found in the result of the LIST operation. expected = {deleteMarker: %t, isLatest: %t}, actual = %+v",
+					obj.Versions[i].DeleteMarker, i == len(obj.Versions)-1, v))
+			w.logger.Error(err.Error())
+			return err
+		}
+	}
+	return nil
+}
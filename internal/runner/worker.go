@@ -4,8 +4,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"math/rand"
+	"sync"
 
 	"github.com/peng225/oval/internal/object"
 	"github.com/peng225/oval/internal/pattern"
@@ -13,19 +15,40 @@ import (
 	"github.com/peng225/oval/internal/stat"
 )
 
+const (
+	// The maximum size of the object which can be copied by a single CopyObject request.
+	maxCopyObjectSize = 5 * 1024 * 1024 * 1024
+)
+
+var (
+	errCanceled = errors.New("canceled")
+)
+
 type Worker struct {
+	// mu is held during each operation.
+	mu                sync.Mutex
 	id                int
 	minSize           int
 	maxSize           int
+	versioning        bool
 	BucketsWithObject []*BucketWithObject `json:"bucketsWithObject"`
 	client            *s3client.S3Client
-	st                *stat.Stat
-	logger            *slog.Logger
+	// sharedKeys is not nil in the shared-key mode.
+	sharedKeys *sharedKeyModel
+	// corruptions is not nil if the validation failures are recorded.
+	corruptions *corruptionRecorder
+	// mutating is true during the operations which may modify the objects.
+	mutating bool
+	st       *stat.Stat
+	logger   *slog.Logger
 }
 
 type BucketWithObject struct {
 	BucketName string             `json:"bucketName"`
 	ObjectMeta *object.ObjectMeta `json:"objectMeta"`
+	// DanglingUploads is the list of the multipart uploads intentionally left in progress
+	// from the oldest one.
+	DanglingUploads []s3client.MultipartUpload `json:"danglingUploads,omitempty"`
 }
 
 func (w *Worker) ShowInfo() {
@@ -35,43 +58,36 @@ func (w *Worker) ShowInfo() {
 	w.logger.Info("Worker info", slog.Group("key", "head", head, "tail", tail))
 }
 
+// markUncertain marks the objects used by the last operation as uncertain.
+func (w *Worker) markUncertain() {
+	for _, bucketWithObj := range w.BucketsWithObject {
+		bucketWithObj.ObjectMeta.MarkUncertain()
+	}
+}
+
+// releaseObjects packs the objects used by the last operation.
+func (w *Worker) releaseObjects() {
+	for _, bucketWithObj := range w.BucketsWithObject {
+		bucketWithObj.ObjectMeta.Release()
+	}
+}
+
 func (w *Worker) Put(ctx context.Context) error {
+	if w.sharedKeys != nil {
+		return w.SharedPut(ctx)
+	}
 	bucketWithObj := w.selectBucketWithObject()
 	obj := bucketWithObj.ObjectMeta.GetRandomObject()
+	if obj == nil {
+		return nil
+	}
 
-	// Validation before write
-	getBeforeBody, err := w.client.GetObject(ctx, bucketWithObj.BucketName, obj.Key)
+	err := w.validBeforeWrite(ctx, bucketWithObj, obj, "put")
 	if err != nil {
-		if errors.Is(err, s3client.ErrNoSuchKey) {
-			if bucketWithObj.ObjectMeta.Exist(obj.Key) {
-				// expect: exists, actual: does not exist
-				err = fmt.Errorf("an object has been lost. (key = %s)", obj.Key)
-				w.logger.Error(err.Error())
-				return err
-			}
-		} else {
-			w.logger.Error(err.Error())
-			return err
-		}
-	} else {
-		defer getBeforeBody.Close()
-		if !bucketWithObj.ObjectMeta.Exist(obj.Key) {
-			// expect: does not exist, actual: exists
-			err = fmt.Errorf("an unexpected object was found. (key = %s)", obj.Key)
-			w.logger.Error(err.Error())
-			return err
-		}
-		err := pattern.Valid(w.id, bucketWithObj.BucketName, obj, getBeforeBody)
-		if err != nil {
-			if ctx.Err() == context.Canceled {
-				w.logger.Warn("Detected the canceled context.")
-				return nil
-			}
-			err = fmt.Errorf("data validation error occurred before put.\n%w", err)
-			w.logger.Error(err.Error())
-			return err
+		if errors.Is(err, errCanceled) {
+			return nil
 		}
-		w.st.AddGetForValidCount()
+		return err
 	}
 
 	size, err := pattern.DecideSize(w.minSize, w.maxSize)
@@ -82,45 +98,355 @@ func (w *Worker) Put(ctx context.Context) error {
 	obj.Size = size
 	bucketWithObj.ObjectMeta.RegisterToExistingList(obj.Key)
 	obj.WriteCount++
-	body, err := pattern.Generate(size, w.id, bucketWithObj.BucketName, obj)
+	obj.Segments = nil
+	obj.Metadata = &object.Metadata{
+		BucketName: bucketWithObj.BucketName,
+		Key:        obj.EmbeddedKey(),
+		WriteCount: obj.WriteCount,
+		WorkerID:   w.id,
+	}
+	body, err := pattern.NewReader(size, w.id, bucketWithObj.BucketName, obj)
 	if err != nil {
 		w.logger.Error(err.Error())
 		return err
 	}
-	partCount, err := w.client.PutObject(ctx, bucketWithObj.BucketName, obj.Key, body)
+	res, err := w.client.PutObjectWithOptions(ctx, bucketWithObj.BucketName, obj.Key, body, int64(size),
+		&s3client.PutOptions{Metadata: objectMetadata(obj.Metadata)})
 	if err != nil {
 		w.logger.Error(err.Error())
 		return err
 	}
+	obj.Checksum = writtenChecksum(res)
+	obj.ETag = writtenETag(res)
 
-	w.st.AddUploadedPartCount(int64(partCount))
+	w.st.AddUploadedPartCount(int64(res.PartCount))
 	w.st.AddPutCount()
 
-	// Validation after write
-	getAfterBody, err := w.client.GetObject(ctx, bucketWithObj.BucketName, obj.Key)
+	err = w.recordVersion(ctx, bucketWithObj, obj, res.VersionID)
+	if err != nil {
+		return err
+	}
+
+	err = w.validAfterWrite(ctx, bucketWithObj, obj, "put")
+	if err != nil && !errors.Is(err, errCanceled) {
+		return err
+	}
+	return nil
+}
+
+func (w *Worker) Copy(ctx context.Context) error {
+	srcBucketWithObj := w.selectBucketWithObject()
+	srcObj := srcBucketWithObj.ObjectMeta.GetExistingRandomObject()
+	if srcObj == nil || srcObj.Size > maxCopyObjectSize {
+		return nil
+	}
+	dstBucketWithObj := w.selectBucketWithObject()
+	dstObj := dstBucketWithObj.ObjectMeta.GetRandomObject()
+	if dstObj == nil {
+		return nil
+	}
+	if dstBucketWithObj == srcBucketWithObj && dstObj == srcObj {
+		// An object cannot be copied onto itself without changing its metadata.
+		return nil
+	}
+
+	err := w.validBeforeWrite(ctx, dstBucketWithObj, dstObj, "copy")
+	if err != nil {
+		if errors.Is(err, errCanceled) {
+			return nil
+		}
+		return err
+	}
+
+	dstObj.Size = srcObj.Size
+	dstBucketWithObj.ObjectMeta.RegisterToExistingList(dstObj.Key)
+	dstObj.WriteCount++
+	dstObj.Segments = srcObj.DataSegments(srcBucketWithObj.BucketName, 0, srcObj.Size)
+	res, err := w.client.CopyObject(ctx, srcBucketWithObj.BucketName, srcObj.Key,
+		dstBucketWithObj.BucketName, dstObj.Key)
 	if err != nil {
 		if errors.Is(err, s3client.ErrNoSuchKey) {
-			err = fmt.Errorf("object lost after put.\nerr: %w\nobj: %v", err, obj)
+			err = newObjectError(FindingLost, "copy", srcBucketWithObj.BucketName, srcObj, objectState(srcObj), stateNotFound,
+				fmt.Errorf("object lost before copy.\nerr: %w\nobj: %v", err, srcObj))
 		}
 		w.logger.Error(err.Error())
 		return err
 	}
-	defer getAfterBody.Close()
-	err = pattern.Valid(w.id, bucketWithObj.BucketName, obj, getAfterBody)
+	// The copy has the same checksum as the source unless it is of the composite type,
+	// because the checksum is recalculated for the whole data.
+	dstObj.Checksum = nil
+	if srcObj.Checksum != nil && !srcObj.Checksum.IsComposite() &&
+		srcObj.Checksum.Algorithm == w.client.ChecksumAlgorithm() {
+		dstObj.Checksum = srcObj.Checksum
+	}
+	dstObj.ETag = writtenETag(res)
+	// The metadata and the tags are copied from the source as well.
+	dstObj.Metadata = srcObj.Metadata
+	w.st.AddCopyCount()
+
+	err = w.recordVersion(ctx, dstBucketWithObj, dstObj, res.VersionID)
+	if err != nil {
+		return err
+	}
+
+	err = w.validAfterWrite(ctx, dstBucketWithObj, dstObj, "copy")
+	if err != nil && !errors.Is(err, errCanceled) {
+		return err
+	}
+	return nil
+}
+
+// Compose creates an object by the multipart upload whose parts are
+// either copied from the ranges of the existing objects or freshly generated.
+func (w *Worker) Compose(ctx context.Context) error {
+	bucketWithObj := w.selectBucketWithObject()
+	obj := bucketWithObj.ObjectMeta.GetRandomObject()
+	if obj == nil {
+		return nil
+	}
+
+	err := w.validBeforeWrite(ctx, bucketWithObj, obj, "compose")
+	if err != nil {
+		if errors.Is(err, errCanceled) {
+			return nil
+		}
+		return err
+	}
+
+	size, err := pattern.DecideSize(w.minSize, w.maxSize)
+	if err != nil {
+		w.logger.Error(err.Error())
+		return err
+	}
+	// The fresh parts are generated as the data of the next generation of `obj`.
+	nextObj := &object.Object{
+		Key:        obj.Key,
+		WriteCount: obj.WriteCount + 1,
+	}
+	freshData, err := pattern.NewReader(size, w.id, bucketWithObj.BucketName, nextObj)
+	if err != nil {
+		w.logger.Error(err.Error())
+		return err
+	}
+	parts := make([]s3client.PartSource, 0)
+	segments := make([]object.Segment, 0)
+	copiedPartCount := 0
+	for offset := 0; offset < size; {
+		partSize := min(w.client.DecidePartSize(), size-offset)
+		srcBucketWithObj := w.selectBucketWithObject()
+		srcObj := srcBucketWithObj.ObjectMeta.GetExistingRandomObject()
+		if rand.Intn(2) == 0 && srcObj != nil && partSize <= srcObj.Size {
+			srcStart := rand.Intn(srcObj.Size - partSize + 1)
+			parts = append(parts, s3client.PartSource{
+				SrcBucketName: srcBucketWithObj.BucketName,
+				SrcKey:        srcObj.Key,
+				SrcStart:      int64(srcStart),
+				Length:        int64(partSize),
+			})
+			segments = append(segments, srcObj.DataSegments(srcBucketWithObj.BucketName, srcStart, partSize)...)
+			copiedPartCount++
+		} else {
+			parts = append(parts, s3client.PartSource{
+				Body:   io.NewSectionReader(freshData, int64(offset), int64(partSize)),
+				Length: int64(partSize),
+			})
+			segments = append(segments, nextObj.DataSegments(bucketWithObj.BucketName, offset, partSize)...)
+		}
+		offset += partSize
+	}
+
+	obj.Size = size
+	bucketWithObj.ObjectMeta.RegisterToExistingList(obj.Key)
+	obj.WriteCount++
+	obj.Segments = nil
+	if copiedPartCount != 0 {
+		obj.Segments = segments
+	}
+	res, err := w.client.ComposeObject(ctx, bucketWithObj.BucketName, obj.Key, parts)
+	if err != nil {
+		if errors.Is(err, s3client.ErrNoSuchKey) {
+			// The source object is unknown.
+			err = &objectError{
+				class:    FindingLost,
+				phase:    "compose",
+				expected: stateExists,
+				actual:   stateNotFound,
+				err:      fmt.Errorf("the source object of a part lost at compose.\nerr: %w\nobj: %v", err, obj),
+			}
+		}
+		w.logger.Error(err.Error())
+		return err
+	}
+	obj.Checksum = writtenChecksum(res)
+	obj.ETag = writtenETag(res)
+	obj.Metadata = nil
+	w.st.AddUploadedPartCount(int64(len(parts) - copiedPartCount))
+	w.st.AddCopiedPartCount(int64(copiedPartCount))
+	w.st.AddComposeCount()
+
+	err = w.recordVersion(ctx, bucketWithObj, obj, res.VersionID)
+	if err != nil {
+		return err
+	}
+
+	err = w.validAfterWrite(ctx, bucketWithObj, obj, "compose")
+	if err != nil && !errors.Is(err, errCanceled) {
+		return err
+	}
+	return nil
+}
+
+// validBeforeWrite checks that the object which is about to be overwritten is in the expected state.
+// It returns errCanceled if the validation was interrupted by the context cancellation.
+func (w *Worker) validBeforeWrite(ctx context.Context, bucketWithObj *BucketWithObject, obj *object.Object, opName string) error {
+	return w.validCurrentState(ctx, bucketWithObj, obj, "before "+opName)
+}
+
+// validCurrentState checks that the object is in the expected state, whether it exists or not.
+// `timing` describes when the validation is done in the error message.
+// It returns errCanceled if the validation was interrupted by the context cancellation.
+func (w *Worker) validCurrentState(ctx context.Context, bucketWithObj *BucketWithObject, obj *object.Object, timing string) error {
+	body, err := w.client.GetObject(ctx, bucketWithObj.BucketName, obj.Key)
+	if err != nil {
+		if errors.Is(err, s3client.ErrNoSuchKey) {
+			if bucketWithObj.ObjectMeta.Exist(obj.Key) {
+				// expect: exists, actual: does not exist
+				err = newObjectError(FindingLost, phaseName(timing), bucketWithObj.BucketName, obj, objectState(obj), stateNotFound,
+					fmt.Errorf("an object has been lost. (key = %s)", obj.Key))
+				w.logger.Error(err.Error())
+				return err
+			}
+			return nil
+		}
+		w.logger.Error(err.Error())
+		return err
+	}
+	defer body.Close()
+	if !bucketWithObj.ObjectMeta.Exist(obj.Key) {
+		// expect: does not exist, actual: exists
+		err = newObjectError(FindingUnexpected, phaseName(timing), bucketWithObj.BucketName, obj, stateNotFound, stateExists,
+			fmt.Errorf("an unexpected object was found. (key = %s)", obj.Key))
+		w.logger.Error(err.Error())
+		return err
+	}
+	err = w.validObjectBody(ctx, bucketWithObj, obj, "", body, phaseName(timing))
+	if err != nil {
+		return err
+	}
+	w.st.AddGetForValidCount()
+	return nil
+}
+
+// writtenChecksum returns the checksum of the data written by the request which returned `res`.
+// It returns nil if the checksum is unknown.
+func writtenChecksum(res *s3client.WriteResult) *object.Checksum {
+	if res.Checksum == "" {
+		return nil
+	}
+	return &object.Checksum{
+		Algorithm: res.ChecksumAlgorithm,
+		Value:     res.Checksum,
+	}
+}
+
+// writtenETag returns the ETag which the object written by the request which returned `res` should have.
+func writtenETag(res *s3client.WriteResult) string {
+	if res.ExpectedETag != "" {
+		return res.ExpectedETag
+	}
+	return res.ETag
+}
+
+// validChecksum checks that the checksum returned by the storage matches the one computed at write.
+func (w *Worker) validChecksum(obj *object.Object, body *s3client.ObjectReader) error {
+	expected := obj.Checksum
+	if expected == nil || expected.Algorithm != w.client.ChecksumAlgorithm() {
+		// The checksum cannot be compared.
+		return nil
+	}
+	if body.ChecksumAlgorithm != expected.Algorithm || body.Checksum != expected.Value {
+		return fmt.Errorf("- Checksum is wrong. (expected = {algorithm: %s, value: %s}, actual = {algorithm: %s, value: %s})",
+			expected.Algorithm, expected.Value, body.ChecksumAlgorithm, body.Checksum)
+	}
+	return nil
+}
+
+// validObjectBody checks the data, the checksum and the metadata of `obj` read as `body`.
+// `versionID` is empty if the current version is read, and `obj` is the one of the version otherwise.
+// `phase` names the validation in the errors.
+// It returns errCanceled if the validation was interrupted by the context cancellation.
+func (w *Worker) validObjectBody(ctx context.Context, bucketWithObj *BucketWithObject, obj *object.Object,
+	versionID string, body *s3client.ObjectReader, phase string) error {
+	target := fmt.Sprintf("phase = %s", phase)
+	if versionID != "" {
+		target += fmt.Sprintf(", versionID = %s", versionID)
+	}
+	data := w.captureBody(body)
+	defer data.release()
+	invalid := func(actual, kind string, err error) error {
+		objErr := newObjectError(FindingCorrupted, phase, bucketWithObj.BucketName, obj, objectState(obj), actual,
+			fmt.Errorf("%s validation error occurred. (%s)\n%w", kind, target, err))
+		if versionID != "" {
+			objErr = objErr.withVersion(versionID, obj)
+		}
+		objErr.dumpFile = w.keepCapture(data)
+		w.logger.Error(objErr.Error())
+		return objErr
+	}
+
+	err := pattern.Valid(w.id, bucketWithObj.BucketName, obj, data)
 	if err != nil {
 		if ctx.Err() == context.Canceled {
 			w.logger.Warn("Detected the canceled context.")
-			return nil
+			return errCanceled
+		}
+		return invalid("data mismatch", "data", err)
+	}
+	err = w.validChecksum(obj, body)
+	if err != nil {
+		return invalid("checksum mismatch", "checksum", err)
+	}
+	mismatch, err := w.validMetadata(ctx, bucketWithObj.BucketName, obj, versionID, body)
+	if err != nil {
+		if !errors.Is(err, errCanceled) {
+			w.logger.Error(err.Error())
+		}
+		return err
+	}
+	if mismatch != "" {
+		return invalid("metadata mismatch", "metadata", errors.New(mismatch))
+	}
+	return nil
+}
+
+// validAfterWrite checks that the just-written object is in the expected state.
+// It returns errCanceled if the validation was interrupted by the context cancellation.
+func (w *Worker) validAfterWrite(ctx context.Context, bucketWithObj *BucketWithObject, obj *object.Object, opName string) error {
+	getAfterBody, err := w.client.GetObject(ctx, bucketWithObj.BucketName, obj.Key)
+	if err != nil {
+		if errors.Is(err, s3client.ErrNoSuchKey) {
+			err = newObjectError(FindingLost, phaseName("after "+opName), bucketWithObj.BucketName, obj, objectState(obj), stateNotFound,
+				fmt.Errorf("object lost after %s.\nerr: %w\nobj: %v", opName, err, obj))
 		}
-		err = fmt.Errorf("data validation error occurred after put.\n%w", err)
 		w.logger.Error(err.Error())
 		return err
 	}
+	defer getAfterBody.Close()
+	err = w.validObjectBody(ctx, bucketWithObj, obj, "", getAfterBody, phaseName("after "+opName))
+	if err != nil {
+		return err
+	}
 	w.st.AddGetForValidCount()
 	return nil
 }
 
 func (w *Worker) Get(ctx context.Context) error {
+	if w.sharedKeys != nil {
+		return w.SharedGet(ctx)
+	}
+	if w.versioning && rand.Intn(2) == 0 {
+		return w.getVersion(ctx)
+	}
 	bucketWithObj := w.selectBucketWithObject()
 	obj := bucketWithObj.ObjectMeta.GetExistingRandomObject()
 	if obj == nil {
@@ -131,47 +457,112 @@ func (w *Worker) Get(ctx context.Context) error {
 	body, err := w.client.GetObject(ctx, bucketWithObj.BucketName, obj.Key)
 	if err != nil {
 		if errors.Is(err, s3client.ErrNoSuchKey) {
-			err = fmt.Errorf("object lost before get.\nerr: %w\nobj: %v", err, obj)
+			err = newObjectError(FindingLost, "get", bucketWithObj.BucketName, obj, objectState(obj), stateNotFound,
+				fmt.Errorf("object lost before get.\nerr: %w\nobj: %v", err, obj))
 		}
 		w.logger.Error(err.Error())
 		return err
 	}
 	defer body.Close()
-	err = pattern.Valid(w.id, bucketWithObj.BucketName, obj, body)
+	err = w.validObjectBody(ctx, bucketWithObj, obj, "", body, "get")
 	if err != nil {
-		if ctx.Err() == context.Canceled {
-			w.logger.Warn("Detected the canceled context.")
+		if errors.Is(err, errCanceled) {
 			return nil
 		}
-		err = fmt.Errorf("data validation error occurred at get operation.\n%w", err)
-		w.logger.Error(err.Error())
 		return err
 	}
 	w.st.AddGetCount()
 	return nil
 }
 
-func (w *Worker) List(ctx context.Context) error {
+func (w *Worker) RangeGet(ctx context.Context) error {
 	bucketWithObj := w.selectBucketWithObject()
+	obj := bucketWithObj.ObjectMeta.GetExistingRandomObject()
+	if obj == nil {
+		return nil
+	}
 
-	objectNames, err := w.client.ListObjects(ctx, bucketWithObj.BucketName, bucketWithObj.ObjectMeta.KeyPrefix)
+	// Validation on range get
+	byteRange, start, length := decideRange(obj.Size)
+	body, err := w.client.GetObjectRange(ctx, bucketWithObj.BucketName, obj.Key, byteRange)
 	if err != nil {
+		if errors.Is(err, s3client.ErrNoSuchKey) {
+			err = newObjectError(FindingLost, "range-get", bucketWithObj.BucketName, obj, objectState(obj), stateNotFound,
+				fmt.Errorf("object lost before range get.\nerr: %w\nobj: %v", err, obj))
+		}
 		w.logger.Error(err.Error())
 		return err
 	}
+	defer body.Close()
+	data := w.captureBody(body)
+	defer data.release()
+	err = pattern.ValidRange(w.id, bucketWithObj.BucketName, obj, data, start, length)
+	if err == nil {
+		// The body should not contain any data beyond the requested range.
+		var n int64
+		n, err = io.Copy(io.Discard, data)
+		if err == nil && n != 0 {
+			err = fmt.Errorf("received %d bytes of extra data beyond the requested range", n)
+		}
+	}
+	if err != nil {
+		if ctx.Err() == context.Canceled {
+			w.logger.Warn("Detected the canceled context.")
+			return nil
+		}
+		objErr := newObjectError(FindingCorrupted, "range-get", bucketWithObj.BucketName, obj, objectState(obj), "data mismatch",
+			fmt.Errorf("data validation error occurred at range get operation. (range = \"%s\", obj = %v)\n%w",
+				byteRange, obj, err))
+		objErr.dumpFile = w.keepCapture(data)
+		objErr.dumpRange = byteRange
+		w.logger.Error(objErr.Error())
+		return objErr
+	}
+	w.st.AddRangeGetCount()
+	return nil
+}
 
-	if len(bucketWithObj.ObjectMeta.ExistingObjectIDs) != len(objectNames) {
-		err = fmt.Errorf("invalid number of objects found as a result of the LIST operation. expected = %d, actual = %d",
-			len(bucketWithObj.ObjectMeta.ExistingObjectIDs), len(objectNames))
-		w.logger.Error(err.Error())
+// decideRange randomly decides the range to read from the object of the size `size`.
+// It returns the range in the form of the HTTP Range header,
+// and the start offset and the length of the data which should be returned.
+func decideRange(size int) (string, int, int) {
+	start := decideOffset(size)
+	switch rand.Intn(3) {
+	case 0:
+		// The last byte position may exceed the object size.
+		// In that case, the data until the end of the object should be returned.
+		last := start + decideOffset(size-start+pattern.DataUnitSize)
+		return fmt.Sprintf("bytes=%d-%d", start, last), start, min(last+1, size) - start
+	case 1:
+		return fmt.Sprintf("bytes=%d-", start), start, size - start
+	default:
+		suffixLength := size - start
+		return fmt.Sprintf("bytes=-%d", suffixLength), start, suffixLength
+	}
+}
+
+// decideOffset returns a random value in [0, size).
+// Values around the boundaries of data units are chosen frequently
+// because off-by-one errors are likely to occur there.
+func decideOffset(size int) int {
+	if rand.Intn(2) == 0 {
+		return rand.Intn(size)
+	}
+	offset := rand.Intn(size/pattern.DataUnitSize+1)*pattern.DataUnitSize + rand.Intn(3) - 1
+	return min(max(offset, 0), size-1)
+}
+
+func (w *Worker) List(ctx context.Context) error {
+	bucketWithObj := w.selectBucketWithObject()
+
+	err := w.validList(ctx, bucketWithObj)
+	if err != nil {
 		return err
 	}
 
-	for _, objName := range objectNames {
-		if !bucketWithObj.ObjectMeta.Exist(objName) {
-			err = fmt.Errorf("invalid object key '%s' found in the result of the LIST operation. workerID = 0x%x",
-				objName, w.id)
-			w.logger.Error(err.Error())
+	if w.versioning {
+		err = w.listVersions(ctx, bucketWithObj)
+		if err != nil {
 			return err
 		}
 	}
@@ -188,28 +579,26 @@ func (w *Worker) Delete(ctx context.Context) error {
 	}
 
 	// Validation before delete
-	getBeforeBody, err := w.client.GetObject(ctx, bucketWithObj.BucketName, obj.Key)
+	body, err := w.client.GetObject(ctx, bucketWithObj.BucketName, obj.Key)
 	if err != nil {
 		if errors.Is(err, s3client.ErrNoSuchKey) {
-			err = fmt.Errorf("object lost before delete.\nerr: %w\nobj: %v", err, obj)
+			err = newObjectError(FindingLost, "before-delete", bucketWithObj.BucketName, obj, objectState(obj), stateNotFound,
+				fmt.Errorf("object lost before delete.\nerr: %w\nobj: %v", err, obj))
 		}
 		w.logger.Error(err.Error())
 		return err
 	}
-	defer getBeforeBody.Close()
-	err = pattern.Valid(w.id, bucketWithObj.BucketName, obj, getBeforeBody)
+	defer body.Close()
+	err = w.validObjectBody(ctx, bucketWithObj, obj, "", body, "before-delete")
 	if err != nil {
-		if ctx.Err() == context.Canceled {
-			w.logger.Warn("Detected the canceled context.")
+		if errors.Is(err, errCanceled) {
 			return nil
 		}
-		err = fmt.Errorf("data validation error occurred before delete.\n%w", err)
-		w.logger.Error(err.Error())
 		return err
 	}
 	w.st.AddGetForValidCount()
 
-	err = w.client.DeleteObject(ctx, bucketWithObj.BucketName, obj.Key)
+	versionID, err := w.client.DeleteObject(ctx, bucketWithObj.BucketName, obj.Key)
 	if err != nil {
 		w.logger.Error(err.Error())
 		return err
@@ -226,10 +615,14 @@ func (w *Worker) Delete(ctx context.Context) error {
 		}
 	} else {
 		defer getAfterBody.Close()
-		err = fmt.Errorf("expected: object not found, actual: object found. (obj = %v)", *obj)
+		err = newObjectError(FindingUnexpected, "after-delete", bucketWithObj.BucketName, obj, stateNotFound, stateExists,
+			fmt.Errorf("expected: object not found, actual: object found. (obj = %v)", *obj))
 		w.logger.Error(err.Error())
 		return err
 	}
+	if w.versioning {
+		return w.recordDeleteMarker(ctx, bucketWithObj, obj, versionID)
+	}
 	obj.Clear()
 	return nil
 }
@@ -0,0 +1,138 @@
+package s3client
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"hash/crc64"
+	"io"
+)
+
+const (
+	ChecksumCRC32     = "CRC32"
+	ChecksumCRC32C    = "CRC32C"
+	ChecksumCRC64NVME = "CRC64NVME"
+	ChecksumSHA1      = "SHA1"
+	ChecksumSHA256    = "SHA256"
+
+	ChecksumTypeFullObject = "FULL_OBJECT"
+	ChecksumTypeComposite  = "COMPOSITE"
+)
+
+var (
+	crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+	// The reversed representation of the CRC-64/NVME polynomial.
+	crc64NVMETable = crc64.MakeTable(0x9a6c9329ac4bc9b5)
+)
+
+// ChecksumConfig is the configuration of the flexible checksums.
+type ChecksumConfig struct {
+	// Algorithm is one of ChecksumCRC32, ChecksumCRC32C, ChecksumCRC64NVME, ChecksumSHA1 and ChecksumSHA256.
+	// The checksums are disabled if it is empty.
+	Algorithm string
+	// Type is the checksum type of the multipart uploads,
+	// ChecksumTypeFullObject or ChecksumTypeComposite.
+	Type string
+}
+
+func newChecksumHash(algorithm string) hash.Hash {
+	switch algorithm {
+	case ChecksumCRC32:
+		return crc32.NewIEEE()
+	case ChecksumCRC32C:
+		return crc32.New(crc32cTable)
+	case ChecksumCRC64NVME:
+		return crc64.New(crc64NVMETable)
+	case ChecksumSHA1:
+		return sha1.New()
+	case ChecksumSHA256:
+		return sha256.New()
+	}
+	panic(fmt.Sprintf("unknown checksum algorithm: %s", algorithm))
+}
+
+// computeChecksum returns the digest of the data read from `r`.
+func computeChecksum(algorithm string, r io.Reader) ([]byte, error) {
+	h := newChecksumHash(algorithm)
+	_, err := io.Copy(h, r)
+	if err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// compositeChecksum returns the checksum of the multipart object
+// whose parts have the digests `partDigests`.
+func compositeChecksum(algorithm string, partDigests [][]byte) string {
+	h := newChecksumHash(algorithm)
+	for _, digest := range partDigests {
+		h.Write(digest)
+	}
+	return fmt.Sprintf("%s-%d", encodeChecksum(h.Sum(nil)), len(partDigests))
+}
+
+func encodeChecksum(digest []byte) string {
+	return base64.StdEncoding.EncodeToString(digest)
+}
+
+// checksumFields points to the checksum fields of a request or a response.
+type checksumFields struct {
+	crc32     **string
+	crc32c    **string
+	crc64nvme **string
+	sha1      **string
+	sha256    **string
+}
+
+func (f checksumFields) field(algorithm string) **string {
+	switch algorithm {
+	case ChecksumCRC32:
+		return f.crc32
+	case ChecksumCRC32C:
+		return f.crc32c
+	case ChecksumCRC64NVME:
+		return f.crc64nvme
+	case ChecksumSHA1:
+		return f.sha1
+	case ChecksumSHA256:
+		return f.sha256
+	}
+	panic(fmt.Sprintf("unknown checksum algorithm: %s", algorithm))
+}
+
+func (f checksumFields) get(algorithm string) string {
+	value := *f.field(algorithm)
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+func (f checksumFields) set(algorithm, value string) {
+	*f.field(algorithm) = &value
+}
+
+// find returns the algorithm and the value of the checksum set in the fields.
+func (f checksumFields) find() (string, string) {
+	for _, algorithm := range []string{
+		ChecksumCRC32, ChecksumCRC32C, ChecksumCRC64NVME, ChecksumSHA1, ChecksumSHA256,
+	} {
+		if value := f.get(algorithm); value != "" {
+			return algorithm, value
+		}
+	}
+	return "", ""
+}
+
+// verifyChecksum checks that the checksum returned by the storage is the expected one.
+func verifyChecksum(algorithm, expected string, f checksumFields) error {
+	actual := f.get(algorithm)
+	if actual != expected {
+		return fmt.Errorf("%w. (algorithm = %s, expected = %s, actual = %s)",
+			ErrChecksumMismatch, algorithm, expected, actual)
+	}
+	return nil
+}
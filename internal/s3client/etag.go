@@ -0,0 +1,36 @@
+package s3client
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+)
+
+// singlePartETag returns the ETag of the object whose data is read from `r`
+// and which is written by a single request.
+func singlePartETag(r io.Reader) (string, error) {
+	h := md5.New()
+	_, err := io.Copy(h, r)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(`"%s"`, hex.EncodeToString(h.Sum(nil))), nil
+}
+
+// multipartETag returns the ETag of the object created by the multipart upload
+// of the parts whose data have the MD5 digests `partMD5s`.
+// It returns an empty string if the data of some of the parts is unknown.
+func multipartETag(partMD5s [][]byte) string {
+	if len(partMD5s) == 0 {
+		return ""
+	}
+	h := md5.New()
+	for _, digest := range partMD5s {
+		if len(digest) != md5.Size {
+			return ""
+		}
+		h.Write(digest)
+	}
+	return fmt.Sprintf(`"%s-%d"`, hex.EncodeToString(h.Sum(nil)), len(partMD5s))
+}
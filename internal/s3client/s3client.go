@@ -1,34 +1,179 @@
 package s3client
 
 import (
-	"bytes"
 	"context"
+	"crypto/md5"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"log/slog"
+	"math/rand"
 	"net/http"
+	"net/url"
 	"os"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
 )
 
 type S3Client struct {
 	client          *s3.Client
-	multipartThresh int
+	multipartConfig MultipartConfig
+	checksumConfig  ChecksumConfig
+}
+
+const (
+	maxPartNumber = 10000
+	// maxPartNumberGap is the maximum number of the part numbers
+	// skipped between two adjacent parts in the sparse part number mode.
+	maxPartNumberGap = 3
+	// maxDeleteObjectsKeys is the maximum number of the keys deleted by a DeleteObjects request.
+	maxDeleteObjectsKeys = 1000
+)
+
+// MultipartConfig is the configuration of the multipart upload.
+type MultipartConfig struct {
+	// Thresh is the threshold of the object size to switch to the multipart upload.
+	Thresh int
+	// PartSize decides the size of each part.
+	// If it is zero value, Thresh is used as the part size.
+	PartSize PartSize
+	// If SparsePartNumber is true, the part numbers are chosen sparsely (e.g. 1, 3, 7, ...).
+	SparsePartNumber bool
+	// PartConcurrency is the number of parts of an object uploaded concurrently.
+	PartConcurrency int
+}
+
+// PartSize specifies how the size of each part is decided.
+// If Candidates is not empty, the size is chosen randomly from it.
+// Otherwise, the size is chosen randomly from the range [Min, Max].
+type PartSize struct {
+	Min int
+	Max int
+	// Unit is the unit of the size chosen from the range. Any size is chosen if it is zero.
+	Unit       int
+	Candidates []int
+}
+
+// Decide returns the randomly chosen size of a part.
+func (p *PartSize) Decide() int {
+	if len(p.Candidates) != 0 {
+		return p.Candidates[rand.Intn(len(p.Candidates))]
+	}
+	if p.Unit != 0 {
+		return p.Min + p.Unit*rand.Intn((p.Max-p.Min)/p.Unit+1)
+	}
+	return p.Min + rand.Intn(p.Max-p.Min+1)
 }
 
 var (
-	ErrNotFound  = errors.New("not found")
-	ErrNoSuchKey = errors.New("no such key")
-	ErrConflict  = errors.New("conflict")
+	ErrNotFound      = errors.New("not found")
+	ErrNoSuchKey     = errors.New("no such key")
+	ErrNoSuchVersion = errors.New("no such version")
+	ErrConflict      = errors.New("conflict")
+	// ErrPreconditionFailed is returned if the condition of a conditional write was not satisfied.
+	ErrPreconditionFailed = errors.New("precondition failed")
+	// ErrChecksumMismatch is returned if the checksum returned by the storage
+	// differs from the one computed by Oval.
+	ErrChecksumMismatch = errors.New("checksum mismatch")
 )
 
+// WriteResult holds the information returned by the requests which write an object.
+type WriteResult struct {
+	PartCount int
+	// VersionID is empty if the bucket is not versioning-enabled.
+	VersionID string
+	ETag      string
+	// ExpectedETag is the ETag computed by Oval, which should be the same as ETag.
+	// It is the MD5 of the data for the single-part writes
+	// and the MD5 of the part ETags suffixed with the number of parts for the multipart ones.
+	// It is empty if it cannot be computed.
+	ExpectedETag string
+	// Checksum is the checksum of the written data computed by Oval
+	// with the algorithm ChecksumAlgorithm.
+	// It is empty if the checksums are disabled or the data was not wholly uploaded by Oval.
+	Checksum          string
+	ChecksumAlgorithm string
+}
+
+// WriteCondition specifies the conditional headers of a write request.
+type WriteCondition struct {
+	// IfMatch is the ETag which the current object must have.
+	IfMatch string
+	// IfNoneMatch should be "*" if the object must not exist.
+	IfNoneMatch string
+}
+
+// ObjectMetadata is the metadata attached to an object at write.
+type ObjectMetadata struct {
+	ContentType string
+	// UserMetadata is sent as the x-amz-meta-* headers.
+	UserMetadata map[string]string
+	Tags         map[string]string
+}
+
+// PutOptions holds the optional parameters of PutObjectWithOptions.
+type PutOptions struct {
+	// The request is conditional if Condition is not nil.
+	Condition *WriteCondition
+	Metadata  *ObjectMetadata
+}
+
+// ObjectReader is the body of an object returned by the GET requests.
+type ObjectReader struct {
+	io.ReadCloser
+	// Size is the size of the data in the Content-Length header.
+	Size int64
+	// Checksum is the checksum stored in the storage with the algorithm ChecksumAlgorithm.
+	// It is empty if the checksums are disabled or the storage did not return it.
+	Checksum          string
+	ChecksumAlgorithm string
+	MetadataHeaders
+	// TagCount is the number of the tags of the object.
+	// The tags themselves should be got by GetObjectTagging.
+	TagCount int
+}
+
+// MetadataHeaders is the content type and the user metadata returned with an object.
+type MetadataHeaders struct {
+	ContentType  string
+	UserMetadata map[string]string
+}
+
+// ObjectVersion is an entry of the result of ListObjectVersions.
+type ObjectVersion struct {
+	Key          string
+	VersionID    string
+	IsLatest     bool
+	DeleteMarker bool
+}
+
+// MultipartUpload is an entry of the result of ListMultipartUploads.
+type MultipartUpload struct {
+	Key      string `json:"key"`
+	UploadID string `json:"uploadID"`
+}
+
+// CompletedPart is a part passed to CompleteMultipartUpload.
+type CompletedPart struct {
+	PartNumber int32
+	ETag       string
+	// Checksum is empty if the checksums are disabled.
+	Checksum string
+	// MD5 is the MD5 digest of the uploaded data.
+	MD5 []byte
+}
+
 func getTLSClient(caCertFileName string) (*http.Client, error) {
 	cert, err := os.ReadFile(caCertFileName)
 	if err != nil {
@@ -62,9 +207,19 @@ func getTLSClient(caCertFileName string) (*http.Client, error) {
 	return client, nil
 }
 
-func NewS3Client(endpoint, caCertFileName string, multipartThresh int) *S3Client {
+func NewS3Client(endpoint, caCertFileName string, multipartConfig MultipartConfig, checksumConfig ChecksumConfig) *S3Client {
+	if multipartConfig.PartSize.Max == 0 && len(multipartConfig.PartSize.Candidates) == 0 {
+		multipartConfig.PartSize = PartSize{
+			Min: multipartConfig.Thresh,
+			Max: multipartConfig.Thresh,
+		}
+	}
+	if multipartConfig.PartConcurrency < 1 {
+		multipartConfig.PartConcurrency = 1
+	}
 	s := &S3Client{
-		multipartThresh: multipartThresh,
+		multipartConfig: multipartConfig,
+		checksumConfig:  checksumConfig,
 	}
 	var cfg aws.Config
 	var err error
@@ -88,6 +243,8 @@ func NewS3Client(endpoint, caCertFileName string, multipartThresh int) *S3Client
 		s.client = s3.NewFromConfig(cfg, func(o *s3.Options) {
 			o.BaseEndpoint = aws.String(endpoint)
 			o.UsePathStyle = true
+			// Oval validates the composite checksums by itself.
+			o.DisableLogOutputChecksumValidationSkipped = true
 		})
 	} else {
 		cfg, err = config.LoadDefaultConfig(context.Background(),
@@ -97,7 +254,9 @@ func NewS3Client(endpoint, caCertFileName string, multipartThresh int) *S3Client
 			os.Exit(1)
 		}
 		// Create an Amazon S3 service client
-		s.client = s3.NewFromConfig(cfg)
+		s.client = s3.NewFromConfig(cfg, func(o *s3.Options) {
+			o.DisableLogOutputChecksumValidationSkipped = true
+		})
 	}
 
 	return s
@@ -118,6 +277,10 @@ func (s *S3Client) CreateBucket(ctx context.Context, bucketName string) error {
 }
 
 func (s *S3Client) ClearBucket(ctx context.Context, bucketName, prefix string) error {
+	err := s.abortMultipartUploads(ctx, bucketName, prefix)
+	if err != nil {
+		return err
+	}
 	for {
 		listRes, err := s.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
 			Bucket: &bucketName,
@@ -129,116 +292,745 @@ func (s *S3Client) ClearBucket(ctx context.Context, bucketName, prefix string) e
 		if len(listRes.Contents) == 0 {
 			break
 		}
+		ids := make([]types.ObjectIdentifier, 0, len(listRes.Contents))
 		for _, obj := range listRes.Contents {
-			_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
-				Bucket: &bucketName,
-				Key:    obj.Key,
+			ids = append(ids, types.ObjectIdentifier{
+				Key: obj.Key,
 			})
-			if err != nil {
-				return err
-			}
+		}
+		err = s.clearObjects(ctx, bucketName, ids)
+		if err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
-func (s *S3Client) PutObject(ctx context.Context, bucketName, key string, body []byte) (int, error) {
-	var err error
-	var partCount int
-	if len(body) <= s.multipartThresh {
-		_, err = s.client.PutObject(ctx, &s3.PutObjectInput{
-			Bucket: &bucketName,
-			Key:    &key,
-			Body:   bytes.NewReader(body),
-		})
+// ClearBucketVersions deletes all versions and delete markers of the objects with `prefix`.
+func (s *S3Client) ClearBucketVersions(ctx context.Context, bucketName, prefix string) error {
+	err := s.abortMultipartUploads(ctx, bucketName, prefix)
+	if err != nil {
+		return err
+	}
+	for {
+		versions, err := s.ListObjectVersions(ctx, bucketName, prefix)
 		if err != nil {
-			return 0, err
+			return err
+		}
+		if len(versions) == 0 {
+			break
+		}
+		ids := make([]types.ObjectIdentifier, 0, len(versions))
+		for _, v := range versions {
+			ids = append(ids, types.ObjectIdentifier{
+				Key:       &v.Key,
+				VersionId: &v.VersionID,
+			})
+		}
+		err = s.clearObjects(ctx, bucketName, ids)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clearObjects deletes the objects or the versions identified by `ids` by DeleteObjects requests.
+func (s *S3Client) clearObjects(ctx context.Context, bucketName string, ids []types.ObjectIdentifier) error {
+	for start := 0; start < len(ids); start += maxDeleteObjectsKeys {
+		res, err := s.deleteObjects(ctx, bucketName, ids[start:min(start+maxDeleteObjectsKeys, len(ids))])
+		if err != nil {
+			return err
+		}
+		if len(res.Errors) != 0 {
+			return fmt.Errorf("failed to delete some objects. (errors = %+v)", res.Errors)
 		}
-		partCount = 1
-	} else {
-		partCount, err = s.multipartUpload(ctx, bucketName, key, body)
 	}
-	return partCount, err
+	return nil
 }
 
-func (s *S3Client) multipartUpload(ctx context.Context, bucketName, key string, body []byte) (int, error) {
-	cmuOutput, err := s.client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+// abortMultipartUploads aborts all in-progress multipart uploads of the objects with `prefix`.
+func (s *S3Client) abortMultipartUploads(ctx context.Context, bucketName, prefix string) error {
+	uploads, err := s.ListMultipartUploads(ctx, bucketName, prefix)
+	if err != nil {
+		return err
+	}
+	for _, upload := range uploads {
+		err = s.AbortMultipartUpload(ctx, bucketName, upload.Key, upload.UploadID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PutObject uploads the object whose data is read from `body`.
+// If `size` exceeds the multipart threshold, the object is uploaded by the multipart upload.
+func (s *S3Client) PutObject(ctx context.Context, bucketName, key string, body io.ReaderAt, size int64) (*WriteResult, error) {
+	return s.PutObjectWithOptions(ctx, bucketName, key, body, size, nil)
+}
+
+// PutObjectWithOptions is the same as PutObject except that the optional parameters `opts` are applied.
+// If the condition in `opts` is not satisfied, ErrPreconditionFailed is returned.
+func (s *S3Client) PutObjectWithOptions(ctx context.Context, bucketName, key string, body io.ReaderAt, size int64,
+	opts *PutOptions) (*WriteResult, error) {
+	if opts == nil {
+		opts = &PutOptions{}
+	}
+	if size > int64(s.multipartConfig.Thresh) {
+		return s.multipartUpload(ctx, bucketName, key, body, size, opts)
+	}
+	input := &s3.PutObjectInput{
+		Bucket:        &bucketName,
+		Key:           &key,
+		Body:          io.NewSectionReader(body, 0, size),
+		ContentLength: &size,
+	}
+	if opts.Condition != nil {
+		input.IfMatch = nonEmptyString(opts.Condition.IfMatch)
+		input.IfNoneMatch = nonEmptyString(opts.Condition.IfNoneMatch)
+	}
+	if md := opts.Metadata; md != nil {
+		input.ContentType = nonEmptyString(md.ContentType)
+		input.Metadata = md.UserMetadata
+		input.Tagging = nonEmptyString(encodeTags(md.Tags))
+	}
+	expectedETag, err := singlePartETag(io.NewSectionReader(body, 0, size))
+	if err != nil {
+		return nil, err
+	}
+	algorithm := s.checksumConfig.Algorithm
+	checksum := ""
+	if algorithm != "" {
+		digest, err := computeChecksum(algorithm, io.NewSectionReader(body, 0, size))
+		if err != nil {
+			return nil, err
+		}
+		checksum = encodeChecksum(digest)
+		input.ChecksumAlgorithm = types.ChecksumAlgorithm(algorithm)
+		checksumFields{&input.ChecksumCRC32, &input.ChecksumCRC32C, &input.ChecksumCRC64NVME,
+			&input.ChecksumSHA1, &input.ChecksumSHA256}.set(algorithm, checksum)
+	}
+	poOutput, err := s.client.PutObject(ctx, input)
+	if err != nil {
+		return nil, conditionalWriteError(err)
+	}
+	if algorithm != "" {
+		err = verifyChecksum(algorithm, checksum,
+			checksumFields{&poOutput.ChecksumCRC32, &poOutput.ChecksumCRC32C, &poOutput.ChecksumCRC64NVME,
+				&poOutput.ChecksumSHA1, &poOutput.ChecksumSHA256})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &WriteResult{
+		PartCount:         1,
+		VersionID:         aws.ToString(poOutput.VersionId),
+		ETag:              aws.ToString(poOutput.ETag),
+		ExpectedETag:      expectedETag,
+		Checksum:          checksum,
+		ChecksumAlgorithm: algorithm,
+	}, nil
+}
+
+func (s *S3Client) multipartUpload(ctx context.Context, bucketName, key string, body io.ReaderAt, size int64,
+	opts *PutOptions) (*WriteResult, error) {
+	algorithm := s.checksumConfig.Algorithm
+	checksum := ""
+	if algorithm != "" && s.checksumConfig.Type == ChecksumTypeFullObject {
+		digest, err := computeChecksum(algorithm, io.NewSectionReader(body, 0, size))
+		if err != nil {
+			return nil, err
+		}
+		checksum = encodeChecksum(digest)
+	}
+
+	uploadID, err := s.createMultipartUpload(ctx, bucketName, key, opts.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	type partRange struct {
+		offset int64
+		size   int64
+	}
+	partRanges := make([]partRange, 0)
+	for offset := int64(0); offset < size; {
+		partSize := min(size-offset, int64(s.multipartConfig.PartSize.Decide()))
+		partRanges = append(partRanges, partRange{
+			offset: offset,
+			size:   partSize,
+		})
+		offset += partSize
+	}
+	partNumbers := s.partNumbers(len(partRanges))
+
+	// Up to `PartConcurrency` parts are uploaded concurrently,
+	// so the parts may arrive at the storage out of order.
+	// `partList` is indexed by the order of the parts
+	// to complete the upload with the parts in order.
+	partList := make([]types.CompletedPart, len(partRanges))
+	partDigests := make([][]byte, len(partRanges))
+	partMD5s := make([][]byte, len(partRanges))
+	upCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	sem := make(chan struct{}, s.multipartConfig.PartConcurrency)
+	errCh := make(chan error, len(partList))
+	wg := &sync.WaitGroup{}
+	for i := range partList {
+		sem <- struct{}{}
+		if upCtx.Err() != nil {
+			<-sem
+			break
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			pr := partRanges[i]
+			part, err := s.uploadPart(upCtx, bucketName, key, uploadID, partNumbers[i],
+				io.NewSectionReader(body, pr.offset, pr.size), pr.size)
+			if err != nil {
+				errCh <- err
+				cancel()
+				return
+			}
+			partList[i] = part.CompletedPart
+			partDigests[i] = part.digest
+			partMD5s[i] = part.md5
+		}(i)
+	}
+	wg.Wait()
+	close(errCh)
+	// Only the first error is reported.
+	// The others are likely to be caused by the cancellation.
+	err = <-errCh
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		return nil, s.abortMultipartUpload(bucketName, key, &uploadID, err)
+	}
+
+	if algorithm != "" && s.checksumConfig.Type == ChecksumTypeComposite {
+		checksum = compositeChecksum(algorithm, partDigests)
+	}
+	res, err := s.completeMultipartUpload(ctx, bucketName, key, uploadID, partList, partMD5s, checksum, opts.Condition)
+	if err != nil {
+		return nil, s.abortMultipartUpload(bucketName, key, &uploadID, err)
+	}
+	return res, nil
+}
+
+// CreateMultipartUpload starts a multipart upload and returns its upload ID.
+func (s *S3Client) CreateMultipartUpload(ctx context.Context, bucketName, key string) (string, error) {
+	return s.createMultipartUpload(ctx, bucketName, key, nil)
+}
+
+func (s *S3Client) createMultipartUpload(ctx context.Context, bucketName, key string, md *ObjectMetadata) (string, error) {
+	input := &s3.CreateMultipartUploadInput{
 		Bucket: &bucketName,
 		Key:    &key,
-	})
+	}
+	if md != nil {
+		input.ContentType = nonEmptyString(md.ContentType)
+		input.Metadata = md.UserMetadata
+		input.Tagging = nonEmptyString(encodeTags(md.Tags))
+	}
+	if s.checksumConfig.Algorithm != "" {
+		input.ChecksumAlgorithm = types.ChecksumAlgorithm(s.checksumConfig.Algorithm)
+		input.ChecksumType = types.ChecksumType(s.checksumConfig.Type)
+	}
+	cmuOutput, err := s.client.CreateMultipartUpload(ctx, input)
 	if err != nil {
-		return 0, err
-	}
-
-	partList := make([]types.CompletedPart, 0)
-	remainingSize := int64(len(body))
-	partNumber := int32(1)
-	for remainingSize > 0 {
-		pn := partNumber
-		partSize := remainingSize
-		if int64(s.multipartThresh) < partSize {
-			partSize = int64(s.multipartThresh)
-		}
-		upOutput, err := s.client.UploadPart(ctx, &s3.UploadPartInput{
-			Bucket:        &bucketName,
-			Key:           &key,
-			Body:          bytes.NewReader(body[:partSize]),
-			PartNumber:    &pn,
-			UploadId:      cmuOutput.UploadId,
-			ContentLength: &partSize,
-		})
+		return "", err
+	}
+	return aws.ToString(cmuOutput.UploadId), nil
+}
+
+// uploadPart uploads a part with the configured checksum,
+// and returns the completed part and the digest of the part data.
+// The digest is nil if the checksums are disabled.
+func (s *S3Client) uploadPart(ctx context.Context, bucketName, key, uploadID string,
+	partNumber int32, body io.ReadSeeker, size int64) (*uploadedPart, error) {
+	input := &s3.UploadPartInput{
+		Bucket:        &bucketName,
+		Key:           &key,
+		Body:          body,
+		PartNumber:    &partNumber,
+		UploadId:      &uploadID,
+		ContentLength: &size,
+	}
+	// The digests are computed from the data to upload rather than taken from the response.
+	algorithm := s.checksumConfig.Algorithm
+	md5Hash := md5.New()
+	var checksumHash hash.Hash
+	var w io.Writer = md5Hash
+	if algorithm != "" {
+		checksumHash = newChecksumHash(algorithm)
+		w = io.MultiWriter(md5Hash, checksumHash)
+	}
+	_, err := io.Copy(w, body)
+	if err != nil {
+		return nil, err
+	}
+	_, err = body.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+	part := &uploadedPart{
+		md5: md5Hash.Sum(nil),
+	}
+	if algorithm != "" {
+		part.digest = checksumHash.Sum(nil)
+		input.ChecksumAlgorithm = types.ChecksumAlgorithm(algorithm)
+		checksumFields{&input.ChecksumCRC32, &input.ChecksumCRC32C, &input.ChecksumCRC64NVME,
+			&input.ChecksumSHA1, &input.ChecksumSHA256}.set(algorithm, encodeChecksum(part.digest))
+	}
+	upOutput, err := s.client.UploadPart(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	part.PartNumber = &partNumber
+	part.ETag = upOutput.ETag
+	if algorithm != "" {
+		err = verifyChecksum(algorithm, encodeChecksum(part.digest),
+			checksumFields{&upOutput.ChecksumCRC32, &upOutput.ChecksumCRC32C, &upOutput.ChecksumCRC64NVME,
+				&upOutput.ChecksumSHA1, &upOutput.ChecksumSHA256})
 		if err != nil {
-			// `ctx` cannot be used for AbortMultipartUpload()
-			// because UploadPart() may have failed due to
-			// the cancellation of `ctx`.
-			_, abortErr := s.client.AbortMultipartUpload(context.Background(), &s3.AbortMultipartUploadInput{
-				Bucket:   &bucketName,
-				Key:      &key,
-				UploadId: cmuOutput.UploadId,
-			})
-			if abortErr != nil {
-				err = errors.Join(err, fmt.Errorf("failed to abort multipart upload. %w", abortErr))
+			return nil, err
+		}
+		partChecksumFields(&part.CompletedPart).set(algorithm, encodeChecksum(part.digest))
+	}
+	return part, nil
+}
+
+// uploadedPart is a part uploaded by uploadPart.
+type uploadedPart struct {
+	types.CompletedPart
+	// digest is the checksum of the data. It is nil if the checksums are disabled.
+	digest []byte
+	// md5 is the MD5 digest of the data.
+	md5 []byte
+}
+
+// completeMultipartUpload completes the multipart upload `uploadID` with `parts`.
+// `partMD5s` is the MD5 digests of the data of the parts, which is nil if some of them are unknown.
+// If `checksum` is not empty, it is compared with the checksum returned by the storage.
+// If `cond` is not nil, the completion is conditional.
+func (s *S3Client) completeMultipartUpload(ctx context.Context, bucketName, key, uploadID string,
+	parts []types.CompletedPart, partMD5s [][]byte, checksum string, cond *WriteCondition) (*WriteResult, error) {
+	input := &s3.CompleteMultipartUploadInput{
+		Bucket:   &bucketName,
+		Key:      &key,
+		UploadId: &uploadID,
+		MultipartUpload: &types.CompletedMultipartUpload{
+			Parts: parts,
+		},
+	}
+	if cond != nil {
+		input.IfMatch = nonEmptyString(cond.IfMatch)
+		input.IfNoneMatch = nonEmptyString(cond.IfNoneMatch)
+	}
+	algorithm := s.checksumConfig.Algorithm
+	if algorithm != "" {
+		input.ChecksumType = types.ChecksumType(s.checksumConfig.Type)
+	}
+	cmpuOutput, err := s.client.CompleteMultipartUpload(ctx, input)
+	if err != nil {
+		return nil, conditionalWriteError(err)
+	}
+	if checksum != "" {
+		err = verifyChecksum(algorithm, checksum,
+			checksumFields{&cmpuOutput.ChecksumCRC32, &cmpuOutput.ChecksumCRC32C, &cmpuOutput.ChecksumCRC64NVME,
+				&cmpuOutput.ChecksumSHA1, &cmpuOutput.ChecksumSHA256})
+		if err != nil {
+			return nil, err
+		}
+	}
+	res := &WriteResult{
+		PartCount:    len(parts),
+		VersionID:    aws.ToString(cmpuOutput.VersionId),
+		ETag:         aws.ToString(cmpuOutput.ETag),
+		ExpectedETag: multipartETag(partMD5s),
+		Checksum:     checksum,
+	}
+	if checksum != "" {
+		res.ChecksumAlgorithm = algorithm
+	}
+	return res, nil
+}
+
+// conditionalWriteError joins the corresponding sentinel error
+// to the error returned by a conditional write.
+func conditionalWriteError(err error) error {
+	var ae smithy.APIError
+	if errors.As(err, &ae) {
+		switch ae.ErrorCode() {
+		case "PreconditionFailed":
+			return errors.Join(err, ErrPreconditionFailed)
+		case "NoSuchKey":
+			// Returned if the object with the ETag specified by If-Match does not exist.
+			return errors.Join(err, ErrNoSuchKey)
+		}
+	}
+	return err
+}
+
+// encodeTags encodes `tags` as the URL query parameters for the x-amz-tagging header.
+func encodeTags(tags map[string]string) string {
+	values := url.Values{}
+	for k, v := range tags {
+		values.Set(k, v)
+	}
+	return values.Encode()
+}
+
+func nonEmptyString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func partChecksumFields(part *types.CompletedPart) checksumFields {
+	return checksumFields{&part.ChecksumCRC32, &part.ChecksumCRC32C, &part.ChecksumCRC64NVME,
+		&part.ChecksumSHA1, &part.ChecksumSHA256}
+}
+
+// ChecksumAlgorithm returns the configured checksum algorithm.
+// It is empty if the checksums are disabled.
+func (s *S3Client) ChecksumAlgorithm() string {
+	return s.checksumConfig.Algorithm
+}
+
+// DecidePartSize returns the randomly chosen size of a part of the multipart upload.
+func (s *S3Client) DecidePartSize() int {
+	return s.multipartConfig.PartSize.Decide()
+}
+
+// UploadPart uploads a part of the multipart upload `uploadID`.
+func (s *S3Client) UploadPart(ctx context.Context, bucketName, key, uploadID string,
+	partNumber int32, body io.ReadSeeker, size int64) (*CompletedPart, error) {
+	part, err := s.uploadPart(ctx, bucketName, key, uploadID, partNumber, body, size)
+	if err != nil {
+		return nil, err
+	}
+	completedPart := &CompletedPart{
+		PartNumber: partNumber,
+		ETag:       aws.ToString(part.ETag),
+		MD5:        part.md5,
+	}
+	if part.digest != nil {
+		completedPart.Checksum = encodeChecksum(part.digest)
+	}
+	return completedPart, nil
+}
+
+// CompleteMultipartUpload completes the multipart upload `uploadID` with `parts`.
+func (s *S3Client) CompleteMultipartUpload(ctx context.Context, bucketName, key, uploadID string,
+	parts []CompletedPart) (*WriteResult, error) {
+	algorithm := s.checksumConfig.Algorithm
+	partList := make([]types.CompletedPart, len(parts))
+	partDigests := make([][]byte, len(parts))
+	partMD5s := make([][]byte, len(parts))
+	for i := range parts {
+		partList[i] = types.CompletedPart{
+			PartNumber: &parts[i].PartNumber,
+			ETag:       &parts[i].ETag,
+		}
+		partMD5s[i] = parts[i].MD5
+		if algorithm != "" {
+			partChecksumFields(&partList[i]).set(algorithm, parts[i].Checksum)
+			digest, err := base64.StdEncoding.DecodeString(parts[i].Checksum)
+			if err != nil {
+				return nil, err
 			}
-			return 0, err
+			partDigests[i] = digest
 		}
-		body = body[partSize:]
-		partList = append(partList, types.CompletedPart{
-			PartNumber: &pn,
-			ETag:       upOutput.ETag,
+	}
+	// The full object checksum cannot be computed only from the parts.
+	checksum := ""
+	if algorithm != "" && s.checksumConfig.Type == ChecksumTypeComposite {
+		checksum = compositeChecksum(algorithm, partDigests)
+	}
+	return s.completeMultipartUpload(ctx, bucketName, key, uploadID, partList, partMD5s, checksum, nil)
+}
+
+func (s *S3Client) AbortMultipartUpload(ctx context.Context, bucketName, key, uploadID string) error {
+	_, err := s.client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   &bucketName,
+		Key:      &key,
+		UploadId: &uploadID,
+	})
+	return err
+}
+
+// ListMultipartUploads returns all in-progress multipart uploads of the objects with `prefix`.
+func (s *S3Client) ListMultipartUploads(ctx context.Context, bucketName, prefix string) ([]MultipartUpload, error) {
+	var keyMarker, uploadIDMarker *string
+	uploads := make([]MultipartUpload, 0)
+	for {
+		listRes, err := s.client.ListMultipartUploads(ctx, &s3.ListMultipartUploadsInput{
+			Bucket:         &bucketName,
+			KeyMarker:      keyMarker,
+			UploadIdMarker: uploadIDMarker,
+			Prefix:         &prefix,
 		})
-		partNumber++
-		remainingSize -= partSize
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range listRes.Uploads {
+			uploads = append(uploads, MultipartUpload{
+				Key:      aws.ToString(u.Key),
+				UploadID: aws.ToString(u.UploadId),
+			})
+		}
+
+		if !aws.ToBool(listRes.IsTruncated) {
+			break
+		}
+		keyMarker = listRes.NextKeyMarker
+		uploadIDMarker = listRes.NextUploadIdMarker
 	}
+	return uploads, nil
+}
 
-	_, err = s.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+// partNumbers returns the ascending part numbers for `numParts` parts.
+func (s *S3Client) partNumbers(numParts int) []int32 {
+	partNumbers := make([]int32, numParts)
+	// slack is the number of the part numbers which can still be skipped.
+	slack := maxPartNumber - numParts
+	pn := 0
+	for i := range partNumbers {
+		pn++
+		if s.multipartConfig.SparsePartNumber && slack > 0 {
+			skip := rand.Intn(min(slack, maxPartNumberGap) + 1)
+			pn += skip
+			slack -= skip
+		}
+		partNumbers[i] = int32(pn)
+	}
+	return partNumbers
+}
+
+// abortMultipartUpload aborts the multipart upload which failed with `err`,
+// and returns `err` joined with the error of the abort, if any.
+func (s *S3Client) abortMultipartUpload(bucketName, key string, uploadID *string, err error) error {
+	// `ctx` cannot be used for AbortMultipartUpload()
+	// because the failed request may have failed due to
+	// the cancellation of `ctx`.
+	_, abortErr := s.client.AbortMultipartUpload(context.Background(), &s3.AbortMultipartUploadInput{
 		Bucket:   &bucketName,
 		Key:      &key,
-		UploadId: cmuOutput.UploadId,
-		MultipartUpload: &types.CompletedMultipartUpload{
-			Parts: partList,
+		UploadId: uploadID,
+	})
+	if abortErr != nil {
+		err = errors.Join(err, fmt.Errorf("failed to abort multipart upload. %w", abortErr))
+	}
+	return err
+}
+
+// PartSource specifies the data of a part of the object created by ComposeObject.
+// If Body is nil, the part is copied from the range [SrcStart, SrcStart+Length)
+// of the source object by UploadPartCopy.
+type PartSource struct {
+	Body          io.ReadSeeker
+	SrcBucketName string
+	SrcKey        string
+	SrcStart      int64
+	Length        int64
+}
+
+// ComposeObject creates the object by the multipart upload
+// in which each part is either uploaded or copied from another object.
+func (s *S3Client) ComposeObject(ctx context.Context, bucketName, key string, parts []PartSource) (*WriteResult, error) {
+	uploadID, err := s.CreateMultipartUpload(ctx, bucketName, key)
+	if err != nil {
+		return nil, err
+	}
+
+	algorithm := s.checksumConfig.Algorithm
+	// The checksum of the object is computed only if all parts are uploaded,
+	// because the data of the copied parts is not known to Oval.
+	copied := false
+	var fullObjectHash hash.Hash
+	if algorithm != "" && s.checksumConfig.Type == ChecksumTypeFullObject {
+		fullObjectHash = newChecksumHash(algorithm)
+	}
+	partNumbers := s.partNumbers(len(parts))
+	partList := make([]types.CompletedPart, 0, len(parts))
+	partDigests := make([][]byte, 0, len(parts))
+	partMD5s := make([][]byte, 0, len(parts))
+	for i, part := range parts {
+		pn := partNumbers[i]
+		if part.Body != nil {
+			if fullObjectHash != nil {
+				_, err = io.Copy(fullObjectHash, part.Body)
+				if err == nil {
+					_, err = part.Body.Seek(0, io.SeekStart)
+				}
+				if err != nil {
+					return nil, s.abortMultipartUpload(bucketName, key, &uploadID, err)
+				}
+			}
+			uploaded, err := s.uploadPart(ctx, bucketName, key, uploadID, pn, part.Body, part.Length)
+			if err != nil {
+				return nil, s.abortMultipartUpload(bucketName, key, &uploadID, err)
+			}
+			partList = append(partList, uploaded.CompletedPart)
+			partDigests = append(partDigests, uploaded.digest)
+			partMD5s = append(partMD5s, uploaded.md5)
+		} else {
+			copied = true
+			source := copySource(part.SrcBucketName, part.SrcKey)
+			sourceRange := fmt.Sprintf("bytes=%d-%d", part.SrcStart, part.SrcStart+part.Length-1)
+			upcOutput, err := s.client.UploadPartCopy(ctx, &s3.UploadPartCopyInput{
+				Bucket:          &bucketName,
+				Key:             &key,
+				CopySource:      &source,
+				CopySourceRange: &sourceRange,
+				PartNumber:      &pn,
+				UploadId:        &uploadID,
+			})
+			if err != nil {
+				var nsk *types.NoSuchKey
+				if errors.As(err, &nsk) {
+					err = errors.Join(err, ErrNoSuchKey)
+				}
+				return nil, s.abortMultipartUpload(bucketName, key, &uploadID, err)
+			}
+			completedPart := types.CompletedPart{
+				PartNumber: &pn,
+				ETag:       upcOutput.CopyPartResult.ETag,
+			}
+			if algorithm != "" {
+				cpr := upcOutput.CopyPartResult
+				partChecksumFields(&completedPart).set(algorithm,
+					checksumFields{&cpr.ChecksumCRC32, &cpr.ChecksumCRC32C, &cpr.ChecksumCRC64NVME,
+						&cpr.ChecksumSHA1, &cpr.ChecksumSHA256}.get(algorithm))
+			}
+			partList = append(partList, completedPart)
+		}
+	}
+
+	if copied {
+		// The ETag cannot be computed because the data of the copied parts is not known.
+		partMD5s = nil
+	}
+	checksum := ""
+	if algorithm != "" && !copied {
+		if fullObjectHash != nil {
+			checksum = encodeChecksum(fullObjectHash.Sum(nil))
+		} else {
+			checksum = compositeChecksum(algorithm, partDigests)
+		}
+	}
+	res, err := s.completeMultipartUpload(ctx, bucketName, key, uploadID, partList, partMD5s, checksum, nil)
+	if err != nil {
+		return nil, s.abortMultipartUpload(bucketName, key, &uploadID, err)
+	}
+	return res, nil
+}
+
+// GetObject gets the object.
+// If the checksums are enabled, the checksum stored in the storage is also returned.
+func (s *S3Client) GetObject(ctx context.Context, bucketName, key string) (*ObjectReader, error) {
+	input := &s3.GetObjectInput{
+		Bucket: &bucketName,
+		Key:    &key,
+	}
+	if s.checksumConfig.Algorithm != "" {
+		input.ChecksumMode = types.ChecksumModeEnabled
+	}
+	res, err := s.client.GetObject(ctx, input)
+	if err != nil {
+		var nsk *types.NoSuchKey
+		if errors.As(err, &nsk) {
+			err = errors.Join(err, ErrNoSuchKey)
+		}
+		return nil, err
+	}
+	return newObjectReader(res), nil
+}
+
+func newObjectReader(res *s3.GetObjectOutput) *ObjectReader {
+	algorithm, checksum := checksumFields{&res.ChecksumCRC32, &res.ChecksumCRC32C, &res.ChecksumCRC64NVME,
+		&res.ChecksumSHA1, &res.ChecksumSHA256}.find()
+	return &ObjectReader{
+		ReadCloser:        res.Body,
+		Size:              aws.ToInt64(res.ContentLength),
+		Checksum:          checksum,
+		ChecksumAlgorithm: algorithm,
+		MetadataHeaders: MetadataHeaders{
+			ContentType:  aws.ToString(res.ContentType),
+			UserMetadata: res.Metadata,
 		},
+		TagCount: int(aws.ToInt32(res.TagCount)),
+	}
+}
+
+// GetObjectTagging gets the tags of the object.
+// If `versionID` is not empty, the tags of the version are got.
+func (s *S3Client) GetObjectTagging(ctx context.Context, bucketName, key, versionID string) (map[string]string, error) {
+	res, err := s.client.GetObjectTagging(ctx, &s3.GetObjectTaggingInput{
+		Bucket:    &bucketName,
+		Key:       &key,
+		VersionId: nonEmptyString(versionID),
 	})
 	if err != nil {
-		// `ctx` cannot be used for AbortMultipartUpload()
-		// because UploadPart() may have failed due to
-		// the cancellation of `ctx`.
-		_, abortErr := s.client.AbortMultipartUpload(context.Background(), &s3.AbortMultipartUploadInput{
-			Bucket:   &bucketName,
-			Key:      &key,
-			UploadId: cmuOutput.UploadId,
-		})
-		if abortErr != nil {
-			err = errors.Join(err, fmt.Errorf("failed to abort multipart upload. %w", abortErr))
+		var nsk *types.NoSuchKey
+		if errors.As(err, &nsk) {
+			err = errors.Join(err, ErrNoSuchKey)
+		}
+		return nil, err
+	}
+	tags := make(map[string]string, len(res.TagSet))
+	for _, tag := range res.TagSet {
+		tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	return tags, nil
+}
+
+// ObjectInfo is the information of an object returned by HeadObject.
+type ObjectInfo struct {
+	Size         int64
+	ETag         string
+	LastModified time.Time
+	MetadataHeaders
+}
+
+// HeadObject gets the information of the object without its data.
+func (s *S3Client) HeadObject(ctx context.Context, bucketName, key string) (*ObjectInfo, error) {
+	res, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: &bucketName,
+		Key:    &key,
+	})
+	if err != nil {
+		var nf *types.NotFound
+		if errors.As(err, &nf) {
+			err = errors.Join(err, ErrNoSuchKey)
 		}
-		return 0, err
+		return nil, err
 	}
-	return int(partNumber - 1), nil
+	return &ObjectInfo{
+		Size:         aws.ToInt64(res.ContentLength),
+		ETag:         aws.ToString(res.ETag),
+		LastModified: aws.ToTime(res.LastModified),
+		MetadataHeaders: MetadataHeaders{
+			ContentType:  aws.ToString(res.ContentType),
+			UserMetadata: res.Metadata,
+		},
+	}, nil
 }
 
-func (s *S3Client) GetObject(ctx context.Context, bucketName, key string) (io.ReadCloser, error) {
+// GetObjectRange gets the part of the object specified by `byteRange`,
+// which should be in the form of the HTTP Range header. e.g. "bytes=0-255"
+func (s *S3Client) GetObjectRange(ctx context.Context, bucketName, key, byteRange string) (io.ReadCloser, error) {
 	res, err := s.client.GetObject(ctx, &s3.GetObjectInput{
 		Bucket: &bucketName,
 		Key:    &key,
+		Range:  &byteRange,
 	})
 	if err != nil {
 		var nsk *types.NoSuchKey
@@ -274,17 +1066,316 @@ func (s *S3Client) ListObjects(ctx context.Context, bucketName, prefix string) (
 	return objectNames, nil
 }
 
-func (s *S3Client) DeleteObject(ctx context.Context, bucketName, key string) error {
-	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+// ListOptions holds the parameters of ListObjectsWithOptions.
+type ListOptions struct {
+	Prefix     string
+	Delimiter  string
+	StartAfter string
+	// MaxKeys is the maximum number of the entries per page.
+	// The default of the storage is used if it is 0.
+	MaxKeys int32
+	// UseV1 makes the legacy ListObjects API with the markers used instead of ListObjectsV2.
+	UseV1 bool
+}
+
+// ListedObject is an entry of the objects returned by ListObjectsWithOptions.
+type ListedObject struct {
+	Key  string
+	Size int64
+	ETag string
+}
+
+// ListResult holds all pages returned by ListObjectsWithOptions in the returned order.
+type ListResult struct {
+	Objects        []ListedObject
+	CommonPrefixes []string
+	// PageSizes is the number of the objects and the common prefixes in each page.
+	PageSizes []int
+}
+
+// ListObjectsWithOptions lists the objects with `opts` following all pages.
+func (s *S3Client) ListObjectsWithOptions(ctx context.Context, bucketName string, opts *ListOptions) (*ListResult, error) {
+	if opts.UseV1 {
+		return s.listObjectsV1(ctx, bucketName, opts)
+	}
+	res := &ListResult{}
+	var continuationToken *string
+	for {
+		input := &s3.ListObjectsV2Input{
+			Bucket:            &bucketName,
+			ContinuationToken: continuationToken,
+			Prefix:            nonEmptyString(opts.Prefix),
+			Delimiter:         nonEmptyString(opts.Delimiter),
+			StartAfter:        nonEmptyString(opts.StartAfter),
+		}
+		if opts.MaxKeys != 0 {
+			input.MaxKeys = &opts.MaxKeys
+		}
+		listRes, err := s.client.ListObjectsV2(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		res.addPage(listRes.Contents, listRes.CommonPrefixes)
+		if !aws.ToBool(listRes.IsTruncated) {
+			break
+		}
+		if listRes.NextContinuationToken == nil {
+			return nil, errors.New("the continuation token was not returned despite the truncated result")
+		}
+		continuationToken = listRes.NextContinuationToken
+	}
+	return res, nil
+}
+
+func (s *S3Client) listObjectsV1(ctx context.Context, bucketName string, opts *ListOptions) (*ListResult, error) {
+	res := &ListResult{}
+	marker := opts.StartAfter
+	for {
+		input := &s3.ListObjectsInput{
+			Bucket:    &bucketName,
+			Marker:    nonEmptyString(marker),
+			Prefix:    nonEmptyString(opts.Prefix),
+			Delimiter: nonEmptyString(opts.Delimiter),
+		}
+		if opts.MaxKeys != 0 {
+			input.MaxKeys = &opts.MaxKeys
+		}
+		listRes, err := s.client.ListObjects(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		res.addPage(listRes.Contents, listRes.CommonPrefixes)
+		if !aws.ToBool(listRes.IsTruncated) {
+			break
+		}
+		// NextMarker is returned only if the delimiter is specified.
+		// Otherwise, the last key should be used as the next marker.
+		nextMarker := aws.ToString(listRes.NextMarker)
+		if nextMarker == "" && len(listRes.Contents) != 0 {
+			nextMarker = aws.ToString(listRes.Contents[len(listRes.Contents)-1].Key)
+		}
+		if nextMarker <= marker {
+			return nil, fmt.Errorf("the marker did not advance despite the truncated result. (marker = %s, nextMarker = %s)",
+				marker, nextMarker)
+		}
+		marker = nextMarker
+	}
+	return res, nil
+}
+
+func (r *ListResult) addPage(contents []types.Object, commonPrefixes []types.CommonPrefix) {
+	for _, obj := range contents {
+		r.Objects = append(r.Objects, ListedObject{
+			Key:  aws.ToString(obj.Key),
+			Size: aws.ToInt64(obj.Size),
+			ETag: aws.ToString(obj.ETag),
+		})
+	}
+	for _, cp := range commonPrefixes {
+		r.CommonPrefixes = append(r.CommonPrefixes, aws.ToString(cp.Prefix))
+	}
+	r.PageSizes = append(r.PageSizes, len(contents)+len(commonPrefixes))
+}
+
+func (s *S3Client) CopyObject(ctx context.Context, srcBucketName, srcKey, dstBucketName, dstKey string) (*WriteResult, error) {
+	source := copySource(srcBucketName, srcKey)
+	input := &s3.CopyObjectInput{
+		Bucket:     &dstBucketName,
+		Key:        &dstKey,
+		CopySource: &source,
+	}
+	if s.checksumConfig.Algorithm != "" {
+		input.ChecksumAlgorithm = types.ChecksumAlgorithm(s.checksumConfig.Algorithm)
+	}
+	coOutput, err := s.client.CopyObject(ctx, input)
+	if err != nil {
+		var nsk *types.NoSuchKey
+		if errors.As(err, &nsk) {
+			err = errors.Join(err, ErrNoSuchKey)
+		}
+		return nil, err
+	}
+	return &WriteResult{
+		PartCount: 1,
+		VersionID: aws.ToString(coOutput.VersionId),
+		ETag:      aws.ToString(coOutput.CopyObjectResult.ETag),
+	}, nil
+}
+
+// copySource returns the value of the x-amz-copy-source header.
+// Each path segment of the key should be URL-encoded.
+func copySource(bucketName, key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = strings.ReplaceAll(url.QueryEscape(segment), "+", "%20")
+	}
+	return bucketName + "/" + strings.Join(segments, "/")
+}
+
+// DeleteObject deletes the object.
+// If the bucket is versioning-enabled, the version ID of the created delete marker is returned.
+func (s *S3Client) DeleteObject(ctx context.Context, bucketName, key string) (string, error) {
+	doOutput, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
 		Bucket: &bucketName,
 		Key:    &key,
 	})
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(doOutput.VersionId), nil
+}
+
+// DeletedObject is an entry of the successfully deleted objects returned by DeleteObjects.
+type DeletedObject struct {
+	Key string
+	// DeleteMarkerVersionID is the version ID of the delete marker created by the deletion.
+	// It is empty if the bucket is not versioning-enabled.
+	DeleteMarkerVersionID string
+}
+
+// DeleteError is an entry of the objects which failed to be deleted by DeleteObjects.
+type DeleteError struct {
+	Key     string
+	Code    string
+	Message string
+}
+
+// DeleteObjectsResult holds the per-key results of DeleteObjects.
+type DeleteObjectsResult struct {
+	Deleted []DeletedObject
+	Errors  []DeleteError
+}
+
+// DeleteObjects deletes the objects with `keys` by a single request.
+// The number of the keys must be less than or equal to 1000.
+func (s *S3Client) DeleteObjects(ctx context.Context, bucketName string, keys []string) (*DeleteObjectsResult, error) {
+	if len(keys) > maxDeleteObjectsKeys {
+		return nil, fmt.Errorf("too many keys to delete at once. (count = %d)", len(keys))
+	}
+	ids := make([]types.ObjectIdentifier, len(keys))
+	for i := range keys {
+		ids[i] = types.ObjectIdentifier{
+			Key: &keys[i],
+		}
+	}
+	return s.deleteObjects(ctx, bucketName, ids)
+}
+
+func (s *S3Client) deleteObjects(ctx context.Context, bucketName string, ids []types.ObjectIdentifier) (*DeleteObjectsResult, error) {
+	dosOutput, err := s.client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+		Bucket: &bucketName,
+		Delete: &types.Delete{
+			Objects: ids,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	res := &DeleteObjectsResult{
+		Deleted: make([]DeletedObject, 0, len(dosOutput.Deleted)),
+		Errors:  make([]DeleteError, 0, len(dosOutput.Errors)),
+	}
+	for _, d := range dosOutput.Deleted {
+		res.Deleted = append(res.Deleted, DeletedObject{
+			Key:                   aws.ToString(d.Key),
+			DeleteMarkerVersionID: aws.ToString(d.DeleteMarkerVersionId),
+		})
+	}
+	for _, e := range dosOutput.Errors {
+		res.Errors = append(res.Errors, DeleteError{
+			Key:     aws.ToString(e.Key),
+			Code:    aws.ToString(e.Code),
+			Message: aws.ToString(e.Message),
+		})
+	}
+	return res, nil
+}
+
+// DeleteObjectVersion permanently deletes the specified version of the object.
+func (s *S3Client) DeleteObjectVersion(ctx context.Context, bucketName, key, versionID string) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket:    &bucketName,
+		Key:       &key,
+		VersionId: &versionID,
+	})
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+func (s *S3Client) GetObjectVersion(ctx context.Context, bucketName, key, versionID string) (*ObjectReader, error) {
+	input := &s3.GetObjectInput{
+		Bucket:    &bucketName,
+		Key:       &key,
+		VersionId: &versionID,
+	}
+	if s.checksumConfig.Algorithm != "" {
+		input.ChecksumMode = types.ChecksumModeEnabled
+	}
+	res, err := s.client.GetObject(ctx, input)
+	if err != nil {
+		var nsk *types.NoSuchKey
+		var ae smithy.APIError
+		if errors.As(err, &nsk) {
+			err = errors.Join(err, ErrNoSuchKey)
+		} else if errors.As(err, &ae) && ae.ErrorCode() == "NoSuchVersion" {
+			err = errors.Join(err, ErrNoSuchVersion)
+		}
+		return nil, err
+	}
+	return newObjectReader(res), nil
+}
+
+// ListObjectVersions lists all versions and delete markers of the objects with `prefix`.
+func (s *S3Client) ListObjectVersions(ctx context.Context, bucketName, prefix string) ([]ObjectVersion, error) {
+	var keyMarker, versionIDMarker *string
+	versions := make([]ObjectVersion, 0)
+	for {
+		listRes, err := s.client.ListObjectVersions(ctx, &s3.ListObjectVersionsInput{
+			Bucket:          &bucketName,
+			KeyMarker:       keyMarker,
+			VersionIdMarker: versionIDMarker,
+			Prefix:          &prefix,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range listRes.Versions {
+			versions = append(versions, ObjectVersion{
+				Key:       aws.ToString(v.Key),
+				VersionID: aws.ToString(v.VersionId),
+				IsLatest:  aws.ToBool(v.IsLatest),
+			})
+		}
+		for _, dm := range listRes.DeleteMarkers {
+			versions = append(versions, ObjectVersion{
+				Key:          aws.ToString(dm.Key),
+				VersionID:    aws.ToString(dm.VersionId),
+				IsLatest:     aws.ToBool(dm.IsLatest),
+				DeleteMarker: true,
+			})
+		}
+
+		if !aws.ToBool(listRes.IsTruncated) {
+			break
+		}
+		keyMarker = listRes.NextKeyMarker
+		versionIDMarker = listRes.NextVersionIdMarker
+	}
+	return versions, nil
+}
+
+func (s *S3Client) EnableVersioning(ctx context.Context, bucketName string) error {
+	_, err := s.client.PutBucketVersioning(ctx, &s3.PutBucketVersioningInput{
+		Bucket: &bucketName,
+		VersioningConfiguration: &types.VersioningConfiguration{
+			Status: types.BucketVersioningStatusEnabled,
+		},
+	})
+	return err
+}
+
 func (s *S3Client) HeadBucket(ctx context.Context, bucketName string) error {
 	_, err := s.client.HeadBucket(ctx, &s3.HeadBucketInput{
 		Bucket: &bucketName,
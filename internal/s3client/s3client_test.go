@@ -1,7 +1,9 @@
 package s3client
 
 import (
+	"bytes"
 	"context"
+	"crypto/md5"
 	"fmt"
 	"io"
 	"os"
@@ -56,7 +58,10 @@ func TestSuccessCase(t *testing.T) {
 	startMinIO(t)
 	defer stopMinIO(t)
 
-	client := NewS3Client("http://localhost:9000", "", 1024*1024)
+	client := NewS3Client("http://localhost:9000", "", MultipartConfig{
+		Thresh:          1024 * 1024,
+		PartConcurrency: 1,
+	}, ChecksumConfig{})
 	require.NotNil(t, client)
 
 	ctx := context.Background()
@@ -67,9 +72,10 @@ func TestSuccessCase(t *testing.T) {
 	require.NoError(t, err)
 
 	key := "test-key1"
-	partCount, err := client.PutObject(ctx, bucketName, key, []byte("test-data"))
+	res, err := client.PutObject(ctx, bucketName, key, strings.NewReader("test-data"), 9)
 	require.NoError(t, err)
-	assert.Equal(t, 1, partCount)
+	assert.Equal(t, 1, res.PartCount)
+	assert.Empty(t, res.VersionID)
 
 	data, err := client.GetObject(ctx, bucketName, key)
 	require.NoError(t, err)
@@ -77,22 +83,124 @@ func TestSuccessCase(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, []byte("test-data"), dataStr)
 
+	rangeData, err := client.GetObjectRange(ctx, bucketName, key, "bytes=2-5")
+	require.NoError(t, err)
+	dataStr, err = io.ReadAll(rangeData)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("st-d"), dataStr)
+
+	rangeData, err = client.GetObjectRange(ctx, bucketName, key, "bytes=-4")
+	require.NoError(t, err)
+	dataStr, err = io.ReadAll(rangeData)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("data"), dataStr)
+
+	copiedKey := "test-key2"
+	_, err = client.CopyObject(ctx, bucketName, key, bucketName, copiedKey)
+	require.NoError(t, err)
+	data, err = client.GetObject(ctx, bucketName, copiedKey)
+	require.NoError(t, err)
+	dataStr, err = io.ReadAll(data)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("test-data"), dataStr)
+
+	composedKey := "test-key3"
+	_, err = client.ComposeObject(ctx, bucketName, composedKey, []PartSource{
+		{
+			SrcBucketName: bucketName,
+			SrcKey:        key,
+			SrcStart:      5,
+			Length:        4,
+		},
+	})
+	require.NoError(t, err)
+	data, err = client.GetObject(ctx, bucketName, composedKey)
+	require.NoError(t, err)
+	dataStr, err = io.ReadAll(data)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("data"), dataStr)
+
 	objectNames, err := client.ListObjects(ctx, bucketName, "test")
 	require.NoError(t, err)
-	assert.ElementsMatch(t, []string{key}, objectNames)
+	assert.ElementsMatch(t, []string{key, copiedKey, composedKey}, objectNames)
+
+	_, err = client.DeleteObject(ctx, bucketName, composedKey)
+	require.NoError(t, err)
+
+	_, err = client.DeleteObject(ctx, bucketName, copiedKey)
+	require.NoError(t, err)
+
+	_, err = client.DeleteObject(ctx, bucketName, key)
+	require.NoError(t, err)
+
+	_, err = client.DeleteObject(ctx, bucketName, key)
+	require.NoError(t, err)
+}
+
+func TestVersioning(t *testing.T) {
+	startMinIO(t)
+	defer stopMinIO(t)
+
+	client := NewS3Client("http://localhost:9000", "", MultipartConfig{
+		Thresh:          1024 * 1024,
+		PartConcurrency: 1,
+	}, ChecksumConfig{})
+	require.NotNil(t, client)
+
+	ctx := context.Background()
+	bucketName := "bucket1"
+	err := client.CreateBucket(ctx, bucketName)
+	require.NoError(t, err)
+	err = client.EnableVersioning(ctx, bucketName)
+	require.NoError(t, err)
+
+	key := "test-key1"
+	res1, err := client.PutObject(ctx, bucketName, key, strings.NewReader("test-data1"), 10)
+	require.NoError(t, err)
+	require.NotEmpty(t, res1.VersionID)
+	res2, err := client.PutObject(ctx, bucketName, key, strings.NewReader("test-data2"), 10)
+	require.NoError(t, err)
+	require.NotEmpty(t, res2.VersionID)
+	deleteMarkerVersionID, err := client.DeleteObject(ctx, bucketName, key)
+	require.NoError(t, err)
+	require.NotEmpty(t, deleteMarkerVersionID)
+
+	_, err = client.GetObject(ctx, bucketName, key)
+	assert.ErrorIs(t, err, ErrNoSuchKey)
+	data, err := client.GetObjectVersion(ctx, bucketName, key, res1.VersionID)
+	require.NoError(t, err)
+	dataStr, err := io.ReadAll(data)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("test-data1"), dataStr)
 
-	err = client.DeleteObject(ctx, bucketName, key)
+	versions, err := client.ListObjectVersions(ctx, bucketName, "test")
 	require.NoError(t, err)
+	assert.ElementsMatch(t, []ObjectVersion{
+		{Key: key, VersionID: res1.VersionID},
+		{Key: key, VersionID: res2.VersionID},
+		{Key: key, VersionID: deleteMarkerVersionID, IsLatest: true, DeleteMarker: true},
+	}, versions)
 
-	err = client.DeleteObject(ctx, bucketName, key)
+	err = client.DeleteObjectVersion(ctx, bucketName, key, res1.VersionID)
 	require.NoError(t, err)
+	_, err = client.GetObjectVersion(ctx, bucketName, key, res1.VersionID)
+	assert.Error(t, err)
+
+	err = client.ClearBucketVersions(ctx, bucketName, "test")
+	require.NoError(t, err)
+	versions, err = client.ListObjectVersions(ctx, bucketName, "test")
+	require.NoError(t, err)
+	assert.Empty(t, versions)
 }
 
 func TestFailureCase(t *testing.T) {
 	startMinIO(t)
 	defer stopMinIO(t)
 
-	client := NewS3Client("http://localhost:9000", "", 1024*1024)
+	client := NewS3Client("http://localhost:9000", "", MultipartConfig{
+		Thresh:          1024 * 1024,
+		PartConcurrency: 1,
+	}, ChecksumConfig{})
 	require.NotNil(t, client)
 
 	ctx := context.Background()
@@ -110,3 +218,445 @@ func TestFailureCase(t *testing.T) {
 	_, err = client.GetObject(ctx, bucketName, key)
 	assert.ErrorIs(t, err, ErrNoSuchKey)
 }
+
+func TestConcurrentMultipartUpload(t *testing.T) {
+	startMinIO(t)
+	defer stopMinIO(t)
+
+	partSize := 5 * 1024 * 1024
+	client := NewS3Client("http://localhost:9000", "", MultipartConfig{
+		Thresh:          partSize,
+		PartConcurrency: 3,
+	}, ChecksumConfig{})
+	require.NotNil(t, client)
+
+	ctx := context.Background()
+	bucketName := "bucket1"
+	err := client.CreateBucket(ctx, bucketName)
+	require.NoError(t, err)
+
+	body := make([]byte, 3*partSize+100)
+	for i := range body {
+		body[i] = byte(i / partSize)
+	}
+	key := "test-key1"
+	res, err := client.PutObject(ctx, bucketName, key, bytes.NewReader(body), int64(len(body)))
+	require.NoError(t, err)
+	assert.Equal(t, 4, res.PartCount)
+
+	data, err := client.GetObject(ctx, bucketName, key)
+	require.NoError(t, err)
+	actual, err := io.ReadAll(data)
+	require.NoError(t, err)
+	assert.Equal(t, body, actual)
+}
+
+func TestPartNumbers(t *testing.T) {
+	client := &S3Client{}
+	assert.Equal(t, []int32{1, 2, 3}, client.partNumbers(3))
+
+	client.multipartConfig.SparsePartNumber = true
+	partNumbers := client.partNumbers(100)
+	require.Len(t, partNumbers, 100)
+	assert.GreaterOrEqual(t, partNumbers[0], int32(1))
+	for i := 1; i < len(partNumbers); i++ {
+		assert.Greater(t, partNumbers[i], partNumbers[i-1])
+		assert.LessOrEqual(t, partNumbers[i]-partNumbers[i-1], int32(maxPartNumberGap+1))
+	}
+
+	// No part number can be skipped if all of them are used.
+	partNumbers = client.partNumbers(maxPartNumber)
+	assert.Equal(t, int32(maxPartNumber), partNumbers[maxPartNumber-1])
+}
+
+func TestMultipartUploadLifecycle(t *testing.T) {
+	startMinIO(t)
+	defer stopMinIO(t)
+
+	client := NewS3Client("http://localhost:9000", "", MultipartConfig{
+		Thresh:          1024 * 1024,
+		PartConcurrency: 1,
+	}, ChecksumConfig{})
+	require.NotNil(t, client)
+
+	ctx := context.Background()
+	bucketName := "bucket1"
+	err := client.CreateBucket(ctx, bucketName)
+	require.NoError(t, err)
+
+	key := "test-key1"
+	abortedUploadID, err := client.CreateMultipartUpload(ctx, bucketName, key)
+	require.NoError(t, err)
+	_, err = client.UploadPart(ctx, bucketName, key, abortedUploadID, 1, strings.NewReader("aborted"), 7)
+	require.NoError(t, err)
+
+	uploadID, err := client.CreateMultipartUpload(ctx, bucketName, key)
+	require.NoError(t, err)
+	uploads, err := client.ListMultipartUploads(ctx, bucketName, key)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []MultipartUpload{
+		{Key: key, UploadID: abortedUploadID},
+		{Key: key, UploadID: uploadID},
+	}, uploads)
+
+	err = client.AbortMultipartUpload(ctx, bucketName, key, abortedUploadID)
+	require.NoError(t, err)
+	uploads, err = client.ListMultipartUploads(ctx, bucketName, key)
+	require.NoError(t, err)
+	assert.Equal(t, []MultipartUpload{{Key: key, UploadID: uploadID}}, uploads)
+
+	// Only the data uploaded last should be visible.
+	_, err = client.UploadPart(ctx, bucketName, key, uploadID, 1, strings.NewReader("stale-data"), 10)
+	require.NoError(t, err)
+	part, err := client.UploadPart(ctx, bucketName, key, uploadID, 1, strings.NewReader("test-data"), 9)
+	require.NoError(t, err)
+	_, err = client.CompleteMultipartUpload(ctx, bucketName, key, uploadID, []CompletedPart{*part})
+	require.NoError(t, err)
+	data, err := client.GetObject(ctx, bucketName, key)
+	require.NoError(t, err)
+	dataStr, err := io.ReadAll(data)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("test-data"), dataStr)
+
+	uploads, err = client.ListMultipartUploads(ctx, bucketName, key)
+	require.NoError(t, err)
+	assert.Empty(t, uploads)
+
+	// ClearBucket aborts the in-progress uploads as well.
+	_, err = client.CreateMultipartUpload(ctx, bucketName, key)
+	require.NoError(t, err)
+	err = client.ClearBucket(ctx, bucketName, "test")
+	require.NoError(t, err)
+	uploads, err = client.ListMultipartUploads(ctx, bucketName, key)
+	require.NoError(t, err)
+	assert.Empty(t, uploads)
+}
+
+func TestComputeChecksum(t *testing.T) {
+	type testCase struct {
+		algorithm        string
+		expectedChecksum string
+	}
+	// The check values of "123456789" for each algorithm.
+	testCases := []testCase{
+		{
+			algorithm:        ChecksumCRC32,
+			expectedChecksum: "cbf43926",
+		},
+		{
+			algorithm:        ChecksumCRC32C,
+			expectedChecksum: "e3069283",
+		},
+		{
+			algorithm:        ChecksumCRC64NVME,
+			expectedChecksum: "ae8b14860a799888",
+		},
+		{
+			algorithm:        ChecksumSHA1,
+			expectedChecksum: "f7c3bc1d808e04732adf679965ccc34ca7ae3441",
+		},
+		{
+			algorithm:        ChecksumSHA256,
+			expectedChecksum: "15e2b0d3c33891ebb0f1ef609ec419420c20e320ce94c65fbc8c3312448eb225",
+		},
+	}
+
+	for _, tc := range testCases {
+		digest, err := computeChecksum(tc.algorithm, strings.NewReader("123456789"))
+		require.NoError(t, err)
+		assert.Equalf(t, tc.expectedChecksum, fmt.Sprintf("%x", digest), "tc.algorithm: %s", tc.algorithm)
+	}
+
+	part1, err := computeChecksum(ChecksumCRC32, strings.NewReader("1234"))
+	require.NoError(t, err)
+	part2, err := computeChecksum(ChecksumCRC32, strings.NewReader("56789"))
+	require.NoError(t, err)
+	expected, err := computeChecksum(ChecksumCRC32, bytes.NewReader(append(part1, part2...)))
+	require.NoError(t, err)
+	assert.Equal(t, encodeChecksum(expected)+"-2", compositeChecksum(ChecksumCRC32, [][]byte{part1, part2}))
+}
+
+func TestMultipartETag(t *testing.T) {
+	part1 := md5.Sum([]byte("1234"))
+	part2 := md5.Sum([]byte("56789"))
+	assert.Equal(t, `"d8399932ce6023ac3365f50fbbcde132-2"`, multipartETag([][]byte{part1[:], part2[:]}))
+	// The ETag is unknown if the data of some of the parts is unknown.
+	assert.Empty(t, multipartETag([][]byte{part1[:], nil}))
+	assert.Empty(t, multipartETag(nil))
+
+	etag, err := singlePartETag(strings.NewReader("123456789"))
+	require.NoError(t, err)
+	assert.Equal(t, `"25f9e794323b453885f5181f1b624d0b"`, etag)
+}
+
+func TestChecksum(t *testing.T) {
+	startMinIO(t)
+	defer stopMinIO(t)
+
+	partSize := 5 * 1024 * 1024
+	client := NewS3Client("http://localhost:9000", "", MultipartConfig{
+		Thresh:          partSize,
+		PartConcurrency: 2,
+	}, ChecksumConfig{
+		Algorithm: ChecksumCRC32C,
+		Type:      ChecksumTypeComposite,
+	})
+	require.NotNil(t, client)
+
+	ctx := context.Background()
+	bucketName := "bucket1"
+	err := client.CreateBucket(ctx, bucketName)
+	require.NoError(t, err)
+
+	key := "test-key1"
+	res, err := client.PutObject(ctx, bucketName, key, strings.NewReader("test-data"), 9)
+	require.NoError(t, err)
+	assert.Equal(t, ChecksumCRC32C, res.ChecksumAlgorithm)
+	data, err := client.GetObject(ctx, bucketName, key)
+	require.NoError(t, err)
+	_, err = io.ReadAll(data)
+	require.NoError(t, err)
+	assert.Equal(t, ChecksumCRC32C, data.ChecksumAlgorithm)
+	assert.Equal(t, res.Checksum, data.Checksum)
+
+	body := make([]byte, 2*partSize+100)
+	res, err = client.PutObject(ctx, bucketName, key, bytes.NewReader(body), int64(len(body)))
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(res.Checksum, "-3"))
+	data, err = client.GetObject(ctx, bucketName, key)
+	require.NoError(t, err)
+	_, err = io.ReadAll(data)
+	require.NoError(t, err)
+	assert.Equal(t, res.Checksum, data.Checksum)
+}
+
+func TestConditionalPut(t *testing.T) {
+	startMinIO(t)
+	defer stopMinIO(t)
+
+	partSize := 5 * 1024 * 1024
+	client := NewS3Client("http://localhost:9000", "", MultipartConfig{
+		Thresh:          partSize,
+		PartConcurrency: 1,
+	}, ChecksumConfig{})
+	require.NotNil(t, client)
+
+	ctx := context.Background()
+	bucketName := "bucket1"
+	err := client.CreateBucket(ctx, bucketName)
+	require.NoError(t, err)
+
+	key := "test-key1"
+	_, err = client.PutObjectWithOptions(ctx, bucketName, key, strings.NewReader("test-data"), 9,
+		&PutOptions{Condition: &WriteCondition{IfMatch: `"00000000000000000000000000000000"`}})
+	assert.Error(t, err)
+	res, err := client.PutObjectWithOptions(ctx, bucketName, key, strings.NewReader("test-data"), 9,
+		&PutOptions{Condition: &WriteCondition{IfNoneMatch: "*"}})
+	require.NoError(t, err)
+	require.NotEmpty(t, res.ETag)
+	_, err = client.PutObjectWithOptions(ctx, bucketName, key, strings.NewReader("test-data"), 9,
+		&PutOptions{Condition: &WriteCondition{IfNoneMatch: "*"}})
+	assert.ErrorIs(t, err, ErrPreconditionFailed)
+
+	// The multipart upload is conditional at the completion.
+	staleETag := res.ETag
+	largeData := strings.Repeat("a", partSize+100)
+	res, err = client.PutObjectWithOptions(ctx, bucketName, key, strings.NewReader(largeData), int64(len(largeData)),
+		&PutOptions{Condition: &WriteCondition{IfMatch: staleETag}})
+	require.NoError(t, err)
+	assert.Equal(t, 2, res.PartCount)
+	assert.NotEqual(t, staleETag, res.ETag)
+	_, err = client.PutObjectWithOptions(ctx, bucketName, key, strings.NewReader("test-data"), 9,
+		&PutOptions{Condition: &WriteCondition{IfMatch: staleETag}})
+	assert.ErrorIs(t, err, ErrPreconditionFailed)
+
+	data, err := client.GetObject(ctx, bucketName, key)
+	require.NoError(t, err)
+	dataStr, err := io.ReadAll(data)
+	require.NoError(t, err)
+	assert.Equal(t, []byte(largeData), dataStr)
+	uploads, err := client.ListMultipartUploads(ctx, bucketName, key)
+	require.NoError(t, err)
+	assert.Empty(t, uploads)
+}
+
+func TestMetadata(t *testing.T) {
+	startMinIO(t)
+	defer stopMinIO(t)
+
+	partSize := 5 * 1024 * 1024
+	client := NewS3Client("http://localhost:9000", "", MultipartConfig{
+		Thresh:          partSize,
+		PartConcurrency: 1,
+	}, ChecksumConfig{})
+	require.NotNil(t, client)
+
+	ctx := context.Background()
+	bucketName := "bucket1"
+	err := client.CreateBucket(ctx, bucketName)
+	require.NoError(t, err)
+
+	md := &ObjectMetadata{
+		ContentType:  "application/x-test; key=test-key1",
+		UserMetadata: map[string]string{"test-meta": "value1"},
+		Tags:         map[string]string{"test-tag1": "value1", "test-tag2": "value 2"},
+	}
+	for _, size := range []int{9, partSize + 100} {
+		key := "test-key1"
+		_, err = client.PutObjectWithOptions(ctx, bucketName, key, strings.NewReader(strings.Repeat("a", size)),
+			int64(size), &PutOptions{Metadata: md})
+		require.NoError(t, err)
+
+		data, err := client.GetObject(ctx, bucketName, key)
+		require.NoError(t, err)
+		data.Close()
+		assert.Equal(t, md.ContentType, data.ContentType)
+		assert.Equal(t, md.UserMetadata, data.UserMetadata)
+		assert.Equal(t, len(md.Tags), data.TagCount)
+		tags, err := client.GetObjectTagging(ctx, bucketName, key, "")
+		require.NoError(t, err)
+		assert.Equal(t, md.Tags, tags)
+
+		// The metadata and the tags are copied as well.
+		_, err = client.CopyObject(ctx, bucketName, key, bucketName, "test-key2")
+		require.NoError(t, err)
+		tags, err = client.GetObjectTagging(ctx, bucketName, "test-key2", "")
+		require.NoError(t, err)
+		assert.Equal(t, md.Tags, tags)
+	}
+}
+
+func TestHeadObject(t *testing.T) {
+	startMinIO(t)
+	defer stopMinIO(t)
+
+	partSize := 5 * 1024 * 1024
+	client := NewS3Client("http://localhost:9000", "", MultipartConfig{
+		Thresh:          partSize,
+		PartConcurrency: 1,
+	}, ChecksumConfig{})
+	require.NotNil(t, client)
+
+	ctx := context.Background()
+	bucketName := "bucket1"
+	err := client.CreateBucket(ctx, bucketName)
+	require.NoError(t, err)
+
+	key := "test-key1"
+	_, err = client.HeadObject(ctx, bucketName, key)
+	assert.ErrorIs(t, err, ErrNoSuchKey)
+
+	for _, size := range []int{9, 2*partSize + 100} {
+		res, err := client.PutObject(ctx, bucketName, key, strings.NewReader(strings.Repeat("a", size)), int64(size))
+		require.NoError(t, err)
+		assert.Equal(t, res.ExpectedETag, res.ETag)
+
+		info, err := client.HeadObject(ctx, bucketName, key)
+		require.NoError(t, err)
+		assert.Equal(t, int64(size), info.Size)
+		assert.Equal(t, res.ExpectedETag, info.ETag)
+		assert.False(t, info.LastModified.IsZero())
+	}
+}
+
+func TestDeleteObjects(t *testing.T) {
+	startMinIO(t)
+	defer stopMinIO(t)
+
+	client := NewS3Client("http://localhost:9000", "", MultipartConfig{
+		Thresh:          1024 * 1024,
+		PartConcurrency: 1,
+	}, ChecksumConfig{})
+	require.NotNil(t, client)
+
+	ctx := context.Background()
+	bucketName := "bucket1"
+	err := client.CreateBucket(ctx, bucketName)
+	require.NoError(t, err)
+
+	keys := []string{"test-key1", "test-key2"}
+	for _, key := range keys {
+		_, err = client.PutObject(ctx, bucketName, key, strings.NewReader("test-data"), 9)
+		require.NoError(t, err)
+	}
+	// Deleting a nonexistent key also succeeds.
+	keys = append(keys, "test-key3")
+	res, err := client.DeleteObjects(ctx, bucketName, keys)
+	require.NoError(t, err)
+	assert.Empty(t, res.Errors)
+	deletedKeys := make([]string, 0, len(res.Deleted))
+	for _, d := range res.Deleted {
+		deletedKeys = append(deletedKeys, d.Key)
+		assert.Empty(t, d.DeleteMarkerVersionID)
+	}
+	assert.ElementsMatch(t, keys, deletedKeys)
+	for _, key := range keys {
+		_, err = client.GetObject(ctx, bucketName, key)
+		assert.ErrorIs(t, err, ErrNoSuchKey)
+	}
+}
+
+func TestListObjectsWithOptions(t *testing.T) {
+	startMinIO(t)
+	defer stopMinIO(t)
+
+	client := NewS3Client("http://localhost:9000", "", MultipartConfig{
+		Thresh:          1024 * 1024,
+		PartConcurrency: 1,
+	}, ChecksumConfig{})
+	require.NotNil(t, client)
+
+	ctx := context.Background()
+	bucketName := "bucket1"
+	err := client.CreateBucket(ctx, bucketName)
+	require.NoError(t, err)
+
+	keys := []string{"test-a1", "test-a2", "test-b1", "test-c", "test-d1"}
+	for _, key := range keys {
+		_, err = client.PutObject(ctx, bucketName, key, strings.NewReader("test-data"), 9)
+		require.NoError(t, err)
+	}
+
+	for _, useV1 := range []bool{false, true} {
+		res, err := client.ListObjectsWithOptions(ctx, bucketName, &ListOptions{
+			Prefix:  "test-",
+			MaxKeys: 2,
+			UseV1:   useV1,
+		})
+		require.NoError(t, err)
+		listedKeys := make([]string, 0, len(res.Objects))
+		for _, o := range res.Objects {
+			listedKeys = append(listedKeys, o.Key)
+			assert.Equal(t, int64(9), o.Size)
+		}
+		assert.Equal(t, keys, listedKeys)
+		assert.Equal(t, []int{2, 2, 1}, res.PageSizes)
+
+		res, err = client.ListObjectsWithOptions(ctx, bucketName, &ListOptions{
+			Prefix:     "test-",
+			StartAfter: "test-a2",
+			MaxKeys:    2,
+			UseV1:      useV1,
+		})
+		require.NoError(t, err)
+		listedKeys = listedKeys[:0]
+		for _, o := range res.Objects {
+			listedKeys = append(listedKeys, o.Key)
+		}
+		assert.Equal(t, keys[2:], listedKeys)
+
+		res, err = client.ListObjectsWithOptions(ctx, bucketName, &ListOptions{
+			Prefix:    "test-",
+			Delimiter: "1",
+			MaxKeys:   1,
+			UseV1:     useV1,
+		})
+		require.NoError(t, err)
+		listedKeys = listedKeys[:0]
+		for _, o := range res.Objects {
+			listedKeys = append(listedKeys, o.Key)
+		}
+		assert.Equal(t, []string{"test-a2", "test-c"}, listedKeys)
+		assert.Equal(t, []string{"test-a1", "test-b1", "test-d1"}, res.CommonPrefixes)
+	}
+}
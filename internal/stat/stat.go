@@ -6,12 +6,26 @@ import (
 )
 
 type Stat struct {
-	putCount          int64
-	uploadedPartCount int64
-	getCount          int64
-	getForValidCount  int64
-	listCount         int64
-	deleteCount       int64
+	putCount                int64
+	uploadedPartCount       int64
+	getCount                int64
+	getForValidCount        int64
+	rangeGetCount           int64
+	getVersionCount         int64
+	copyCount               int64
+	composeCount            int64
+	copiedPartCount         int64
+	abortUploadCount        int64
+	abandonUploadCount      int64
+	overwritePartCount      int64
+	conditionalPutCount     int64
+	preconditionFailedCount int64
+	listCount               int64
+	headCount               int64
+	deleteCount             int64
+	batchDeleteCount        int64
+	batchDeletedKeyCount    int64
+	deleteVersionCount      int64
 }
 
 func (st *Stat) AddPutCount() {
@@ -30,22 +44,92 @@ func (st *Stat) AddGetForValidCount() {
 	atomic.AddInt64(&st.getForValidCount, 1)
 }
 
+func (st *Stat) AddRangeGetCount() {
+	atomic.AddInt64(&st.rangeGetCount, 1)
+}
+
+func (st *Stat) AddGetVersionCount() {
+	atomic.AddInt64(&st.getVersionCount, 1)
+}
+
+func (st *Stat) AddCopyCount() {
+	atomic.AddInt64(&st.copyCount, 1)
+}
+
+func (st *Stat) AddComposeCount() {
+	atomic.AddInt64(&st.composeCount, 1)
+}
+
+func (st *Stat) AddCopiedPartCount(partCount int64) {
+	atomic.AddInt64(&st.copiedPartCount, partCount)
+}
+
+func (st *Stat) AddAbortUploadCount() {
+	atomic.AddInt64(&st.abortUploadCount, 1)
+}
+
+func (st *Stat) AddAbandonUploadCount() {
+	atomic.AddInt64(&st.abandonUploadCount, 1)
+}
+
+func (st *Stat) AddOverwritePartCount() {
+	atomic.AddInt64(&st.overwritePartCount, 1)
+}
+
+func (st *Stat) AddConditionalPutCount() {
+	atomic.AddInt64(&st.conditionalPutCount, 1)
+}
+
+func (st *Stat) AddPreconditionFailedCount() {
+	atomic.AddInt64(&st.preconditionFailedCount, 1)
+}
+
 func (st *Stat) AddListCount() {
 	atomic.AddInt64(&st.listCount, 1)
 }
 
+func (st *Stat) AddHeadCount() {
+	atomic.AddInt64(&st.headCount, 1)
+}
+
 func (st *Stat) AddDeleteCount() {
 	atomic.AddInt64(&st.deleteCount, 1)
 }
 
+func (st *Stat) AddBatchDeleteCount() {
+	atomic.AddInt64(&st.batchDeleteCount, 1)
+}
+
+func (st *Stat) AddBatchDeletedKeyCount(keyCount int64) {
+	atomic.AddInt64(&st.batchDeletedKeyCount, keyCount)
+}
+
+func (st *Stat) AddDeleteVersionCount() {
+	atomic.AddInt64(&st.deleteVersionCount, 1)
+}
+
 func (st *Stat) Report() {
 	slog.Info("Statistics report.",
 		slog.Group("report", "putCount", st.putCount,
 			"numUploadedParts", st.uploadedPartCount,
 			"getCount", st.getCount,
 			"getForValidationCount", st.getForValidCount,
+			"rangeGetCount", st.rangeGetCount,
+			"getVersionCount", st.getVersionCount,
+			"copyCount", st.copyCount,
+			"composeCount", st.composeCount,
+			"numCopiedParts", st.copiedPartCount,
+			"abortUploadCount", st.abortUploadCount,
+			"abandonUploadCount", st.abandonUploadCount,
+			"overwritePartCount", st.overwritePartCount,
+			"conditionalPutCount", st.conditionalPutCount,
+			"preconditionFailedCount", st.preconditionFailedCount,
 			"listCount", st.listCount,
+			"headCount", st.headCount,
 			"deleteCount", st.deleteCount,
+			"batchDeleteCount", st.batchDeleteCount,
+			"numBatchDeletedKeys", st.batchDeletedKeyCount,
+			"deleteVersionCount", st.deleteVersionCount,
 		),
 	)
 }